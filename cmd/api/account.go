@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/mailer"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+	Confirm     string `json:"confirm" binding:"required,eqfield=NewPassword"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// @Summary Request a password reset
+// @Description Email a single-use password reset link valid for one hour. The response is the same whether or not the account exists.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param email body forgotPasswordRequest true "Account email"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/password/forgot [post]
+func (app *application) forgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(req.Email)
+	if err != nil {
+		log.Printf("forgotPassword: db error: %v", err)
+	}
+	if user != nil && !user.Disabled() {
+		// Mail in the background so response timing does not reveal whether
+		// the account exists
+		app.background(func() {
+			app.sendUserTokenEmail(user, database.TokenPurposePasswordReset)
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for that email, a password reset link has been sent"})
+}
+
+// @Summary Reset password
+// @Description Set a new password using a token from a password reset email. Revokes all existing tokens and sessions and clears any forced reset.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param reset body resetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/password/reset [post]
+func (app *application) resetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	userID, ok := app.consumeUserToken(c, database.TokenPurposePasswordReset, req.Token)
+	if !ok {
+		return
+	}
+
+	if _, err := app.models.Users.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		log.Printf("resetPassword: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	// The reset link was delivered to the account's address, which proves
+	// ownership of it
+	if _, err := app.models.Users.MarkEmailVerified(userID); err != nil {
+		log.Printf("resetPassword: mark email verified: %v", err)
+	}
+	if err := app.models.Tokens.RevokeUserSessions(userID, database.RevokeReasonCredentialsChange); err != nil {
+		log.Printf("resetPassword: revoke sessions: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// @Summary Verify email address
+// @Description Confirm an email address using a token from a verification email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token body verifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/auth/verify-email [post]
+func (app *application) verifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	userID, ok := app.consumeUserToken(c, database.TokenPurposeEmailVerification, req.Token)
+	if !ok {
+		return
+	}
+
+	if _, err := app.models.Users.MarkEmailVerified(userID); err != nil {
+		log.Printf("verifyEmail: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// @Summary Resend verification email
+// @Description Email a new verification link to the authenticated user's address
+// @Tags Auth
+// @Produce json
+// @Success 202 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/verify-email/resend [post]
+func (app *application) resendVerificationEmail(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.EmailVerified() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	app.background(func() {
+		app.sendUserTokenEmail(user, database.TokenPurposeEmailVerification)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// consumeUserToken redeems a mailed token, writing an error response and
+// returning ok=false if it is invalid, expired or already used.
+func (app *application) consumeUserToken(c *gin.Context, purpose, token string) (int, bool) {
+	userID, err := app.models.Tokens.ConsumeUserToken(purpose, hashToken(token))
+	if err != nil {
+		if err == database.ErrUserTokenInvalid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return 0, false
+		}
+		log.Printf("consumeUserToken: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// sendUserTokenEmail issues a single-use token for purpose and mails the
+// matching link to user. Failures are logged since it runs in the background.
+func (app *application) sendUserTokenEmail(user *database.User, purpose string) {
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		log.Printf("[MAIL] generate %s token for user %d: %v", purpose, user.ID, err)
+		return
+	}
+
+	var msg mailer.Message
+	switch purpose {
+	case database.TokenPurposePasswordReset:
+		err = app.models.Tokens.CreateUserToken(user.ID, purpose, hash, time.Now().Add(passwordResetTTL))
+		msg = mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your EventHub password",
+			Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in one hour and can be used once.\n\n%s\n\nIf you did not request a password reset you can ignore this email.\n",
+				user.Name, app.appLink("/reset-password", token)),
+		}
+	case database.TokenPurposeEmailVerification:
+		err = app.models.Tokens.CreateUserToken(user.ID, purpose, hash, time.Now().Add(emailVerificationTTL))
+		msg = mailer.Message{
+			To:      user.Email,
+			Subject: "Verify your EventHub email address",
+			Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below. It expires in 48 hours.\n\n%s\n",
+				user.Name, app.appLink("/verify-email", token)),
+		}
+	default:
+		err = fmt.Errorf("unknown token purpose")
+	}
+	if err != nil {
+		log.Printf("[MAIL] store %s token for user %d: %v", purpose, user.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := app.mailer.Send(ctx, msg); err != nil {
+		log.Printf("[MAIL] send %s email to user %d: %v", purpose, user.ID, err)
+	}
+}
+
+// appLink builds a frontend URL carrying token as a query parameter.
+func (app *application) appLink(path, token string) string {
+	return app.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// background runs fn in a goroutine that graceful shutdown waits for,
+// logging instead of crashing if it panics.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("background task panic: %v", err)
+			}
+		}()
+		fn()
+	}()
+}
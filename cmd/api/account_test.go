@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/mailer"
+)
+
+// testMailer captures outgoing mail for assertions.
+type testMailer struct {
+	sent chan mailer.Message
+}
+
+func newTestMailer() *testMailer {
+	return &testMailer{sent: make(chan mailer.Message, 16)}
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	select {
+	case m.sent <- msg:
+	default:
+	}
+	return nil
+}
+
+var mailTokenRe = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// nextMailToken waits for the next message sent to "to" and returns the
+// token carried by its link.
+func nextMailToken(t *testing.T, app *application, to string) string {
+	t.Helper()
+	m := app.mailer.(*testMailer)
+	for {
+		select {
+		case msg := <-m.sent:
+			if msg.To != to {
+				continue
+			}
+			match := mailTokenRe.FindStringSubmatch(msg.Body)
+			if match == nil {
+				t.Fatalf("no token in mail body: %s", msg.Body)
+			}
+			return match[1]
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no mail sent to %s", to)
+		}
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := insertUserWithPassword(t, app, "forgot@example.com", "old-password")
+	if _, err := app.models.Users.SetPasswordResetRequired(u.ID, true); err != nil {
+		t.Fatalf("force reset: %v", err)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	// Unknown accounts get the same response and no mail
+	code, _ := api.do("POST", "/api/v1/auth/password/forgot", "", map[string]string{"email": "nobody@example.com"})
+	if code != http.StatusAccepted {
+		t.Fatalf("forgot unknown email: expected 202, got %d", code)
+	}
+
+	code, _ = api.do("POST", "/api/v1/auth/password/forgot", "", map[string]string{"email": u.Email})
+	if code != http.StatusAccepted {
+		t.Fatalf("forgot: expected 202, got %d", code)
+	}
+	token := nextMailToken(t, app, u.Email)
+
+	code, _ = api.do("POST", "/api/v1/auth/password/reset", "", map[string]string{"token": "bogus", "new_password": "new-password", "confirm": "new-password"})
+	if code != http.StatusBadRequest {
+		t.Fatalf("reset with bogus token: expected 400, got %d", code)
+	}
+
+	reset := map[string]string{"token": token, "new_password": "new-password", "confirm": "new-password"}
+	if code, body := api.do("POST", "/api/v1/auth/password/reset", "", reset); code != http.StatusOK {
+		t.Fatalf("reset: %d %s", code, body)
+	}
+	if code, _ := api.do("POST", "/api/v1/auth/password/reset", "", reset); code != http.StatusBadRequest {
+		t.Fatalf("reused reset token: expected 400, got %d", code)
+	}
+
+	if code, _ := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": u.Email, "password": "old-password"}); code != http.StatusUnauthorized {
+		t.Fatalf("login with old password: expected 401, got %d", code)
+	}
+	if code, body := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": u.Email, "password": "new-password"}); code != http.StatusOK {
+		t.Fatalf("login after reset: %d %s", code, body)
+	}
+
+	got, _ := app.models.Users.Get(u.ID)
+	if got.PasswordResetRequired || !got.EmailVerified() {
+		t.Fatalf("expected reset flag cleared and email verified, got %+v", got)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+	app.requireVerifiedEmail = true
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	register := map[string]string{"email": "new@example.com", "password": "password123", "confirm": "password123", "name": "New User"}
+	if code, body := api.do("POST", "/api/v1/auth/register", "", register); code != http.StatusCreated {
+		t.Fatalf("register: %d %s", code, body)
+	}
+	token := nextMailToken(t, app, "new@example.com")
+
+	_, body := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "new@example.com", "password": "password123"})
+	var login loginResponse
+	if err := json.Unmarshal(body, &login); err != nil || login.Token == "" {
+		t.Fatalf("login: %s", body)
+	}
+
+	event := map[string]string{"title": "Launch", "description": "Launch party", "start_time": "2030-01-01T10:00:00Z", "end_time": "2030-01-01T12:00:00Z"}
+	if code, _ := api.do("POST", "/api/v1/events", login.Token, event); code != http.StatusForbidden {
+		t.Fatalf("create event unverified: expected 403, got %d", code)
+	}
+
+	if code, body := api.do("POST", "/api/v1/auth/verify-email", "", map[string]string{"token": token}); code != http.StatusOK {
+		t.Fatalf("verify: %d %s", code, body)
+	}
+	if code, body := api.do("POST", "/api/v1/events", login.Token, event); code != http.StatusCreated {
+		t.Fatalf("create event verified: %d %s", code, body)
+	}
+	if code, _ := api.do("POST", "/api/v1/auth/verify-email/resend", login.Token, nil); code != http.StatusConflict {
+		t.Fatalf("resend when verified: expected 409, got %d", code)
+	}
+
+	// Changing the email requires verifying the new address
+	change := map[string]string{"email": "moved@example.com", "current_password": "password123"}
+	code, body := api.do("PATCH", "/api/v1/auth/me", login.Token, change)
+	if code != http.StatusOK {
+		t.Fatalf("change email: %d %s", code, body)
+	}
+	json.Unmarshal(body, &login)
+	if login.User.EmailVerified() {
+		t.Fatal("expected new email to be unverified")
+	}
+	nextMailToken(t, app, "moved@example.com")
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit log actions recorded by the admin API
+const (
+	auditRoleChanged         = "user.role_changed"
+	auditUserDisabled        = "user.disabled"
+	auditUserEnabled         = "user.enabled"
+	auditPasswordResetForced = "user.password_reset_forced"
+	auditUserUnlocked        = "user.unlocked"
+	auditMFAReset            = "user.mfa_reset"
+	auditUserDeleted         = "user.deleted"
+	auditUserRestored        = "user.restored"
+	auditMFAPolicyChanged    = "mfa.policy_changed"
+)
+
+type updateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// @Summary List users
+// @Description List and search user accounts (admin only)
+// @Tags Admin
+// @Produce json
+// @Param search query string false "Case-insensitive match on name or email"
+// @Param role query string false "Only users with this role"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users [get]
+func (app *application) adminListUsers(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	role := c.Query("role")
+	if role != "" && !database.ValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	users, total, err := app.models.Users.List(c.Query("search"), role, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("adminListUsers: db list error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+	if users == nil {
+		users = []*database.User{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       users,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// @Summary Get a user
+// @Description Get a user together with the events they own and attend (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id} [get]
+func (app *application) adminGetUser(c *gin.Context) {
+	user, ok := app.loadTargetUser(c)
+	if !ok {
+		return
+	}
+
+	owned, ownedTotal, err := app.models.Events.List(database.EventFilter{OwnerID: user.ID, Limit: 100})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+	if owned == nil {
+		owned = []*database.Event{}
+	}
+
+	attending, err := app.models.Attendees.GetEventsForUser(user.ID, app.viewer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendances"})
+		return
+	}
+	if attending == nil {
+		attending = []*database.UserEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":         user,
+		"events":       owned,
+		"events_total": ownedTotal,
+		"attending":    attending,
+	})
+}
+
+// @Summary Change a user's role
+// @Description Set the role of a user (admin only). Admins cannot change their own role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body updateRoleRequest true "New role"
+// @Success 200 {object} main.UserDoc
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/role [patch]
+func (app *application) adminUpdateUserRole(c *gin.Context) {
+	var req updateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !database.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, ok := app.loadTargetUser(c)
+	if !ok || !app.rejectSelfTarget(c, user) {
+		return
+	}
+
+	if user.Role != req.Role {
+		if _, err := app.models.Users.UpdateRole(user.ID, req.Role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+		app.audit(c, auditRoleChanged, user.ID, gin.H{"from": user.Role, "to": req.Role})
+		user.Role = req.Role
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary Disable a user
+// @Description Disable an account; outstanding tokens stop working immediately (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/disable [post]
+func (app *application) adminDisableUser(c *gin.Context) {
+	app.setUserDisabled(c, true)
+}
+
+// @Summary Re-enable a user
+// @Description Re-enable a disabled account (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/enable [post]
+func (app *application) adminEnableUser(c *gin.Context) {
+	app.setUserDisabled(c, false)
+}
+
+func (app *application) setUserDisabled(c *gin.Context, disabled bool) {
+	user, ok := app.loadTargetUser(c)
+	if !ok || !app.rejectSelfTarget(c, user) {
+		return
+	}
+
+	if _, err := app.models.Users.SetDisabled(user.ID, disabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if disabled {
+		app.audit(c, auditUserDisabled, user.ID, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "User disabled"})
+		return
+	}
+	app.audit(c, auditUserEnabled, user.ID, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled"})
+}
+
+// @Summary Force a password reset
+// @Description Require a user to reset their password; outstanding tokens stop working and login is refused until the password is reset (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/force-password-reset [post]
+func (app *application) adminForcePasswordReset(c *gin.Context) {
+	user, ok := app.loadTargetUser(c)
+	if !ok || !app.rejectSelfTarget(c, user) {
+		return
+	}
+
+	if _, err := app.models.Users.SetPasswordResetRequired(user.ID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	app.audit(c, auditPasswordResetForced, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset required"})
+}
+
+// @Summary Delete a user
+// @Description Delete an account together with the events it owns; outstanding tokens stop working immediately. Both can be restored until the retention period ends, after which they are purged (admin only).
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id} [delete]
+func (app *application) adminDeleteUser(c *gin.Context) {
+	user, ok := app.loadTargetUser(c)
+	if !ok || !app.rejectSelfTarget(c, user) {
+		return
+	}
+
+	if _, err := app.models.Users.Delete(user.ID); err != nil {
+		log.Printf("adminDeleteUser: db delete error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+	app.audit(c, auditUserDeleted, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// @Summary Restore a deleted user
+// @Description Restore a deleted account and the events deleted with it, except those whose venue has been booked in the meantime (admin only). Fails with 409 if another account has registered its email address since.
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/restore [post]
+func (app *application) adminRestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	restored, err := app.models.Users.Restore(id)
+	if err == database.ErrEmailTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another account has registered this user's email address since"})
+		return
+	}
+	if err != nil {
+		log.Printf("adminRestoreUser: db restore error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+		return
+	}
+	app.audit(c, auditUserRestored, id, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User restored"})
+}
+
+// @Summary Admin audit log
+// @Description List recorded admin actions, newest first (admin only)
+// @Tags Admin
+// @Produce json
+// @Param user_id query int false "Only actions targeting this user"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {array} main.AuditEntryDoc
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/audit-log [get]
+func (app *application) adminListAuditLog(c *gin.Context) {
+	page, limit := parsePagination(c)
+	targetID, _ := strconv.Atoi(c.Query("user_id"))
+
+	entries, err := app.models.Audit.List(targetID, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	if entries == nil {
+		entries = []*database.AuditEntry{}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// loadTargetUser loads the user named by the :id path parameter, writing an
+// error response and returning ok=false if it is invalid or missing.
+func (app *application) loadTargetUser(c *gin.Context) (*database.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	user.Password = ""
+	return user, true
+}
+
+// rejectSelfTarget stops admins from locking themselves out of the admin API.
+func (app *application) rejectSelfTarget(c *gin.Context, target *database.User) bool {
+	admin, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	if admin.ID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins cannot perform this action on their own account"})
+		return false
+	}
+	return true
+}
+
+// audit records an admin action performed by the authenticated user. Failures
+// are logged rather than surfaced since the action itself already succeeded.
+func (app *application) audit(c *gin.Context, action string, targetUserID int, details interface{}) {
+	admin, err := app.getUserFromContext(c)
+	if err != nil {
+		log.Printf("audit: %s on user %d: no admin in context", action, targetUserID)
+		return
+	}
+	if err := app.models.Audit.Insert(admin.ID, action, &targetUserID, details); err != nil {
+		log.Printf("audit: failed to record %s by %d on user %d: %v", action, admin.ID, targetUserID, err)
+	}
+}
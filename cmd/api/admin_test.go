@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	alice := &database.User{Email: "alice@example.com", Name: "Alice", Password: "x"}
+	bob := &database.User{Email: "bob@example.com", Name: "Bob", Password: "x"}
+	for _, u := range []*database.User{admin, alice, bob} {
+		if err := app.models.Users.Insert(u); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	adminToken, _ := jwtForUser(app, admin.ID)
+	aliceToken, _ := jwtForUser(app, alice.ID)
+
+	do := func(method, path, token string, body interface{}) (int, []byte) {
+		t.Helper()
+		var reader *bytes.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			reader = bytes.NewReader(b)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, ts.URL+path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+
+	if code, _ := do("GET", "/api/v1/admin/users", aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin, got %d", code)
+	}
+
+	code, body := do("GET", "/api/v1/admin/users?search=ALI", adminToken, nil)
+	var list struct {
+		Data []database.User `json:"data"`
+	}
+	json.Unmarshal(body, &list)
+	if code != http.StatusOK || len(list.Data) != 1 || list.Data[0].ID != alice.ID {
+		t.Fatalf("search users: %d %s", code, body)
+	}
+
+	code, body = do("GET", fmt.Sprintf("/api/v1/admin/users/%d", alice.ID), adminToken, nil)
+	if code != http.StatusOK || !bytes.Contains(body, []byte(`"attending":[]`)) {
+		t.Fatalf("get user: %d %s", code, body)
+	}
+
+	if code, body = do("PATCH", fmt.Sprintf("/api/v1/admin/users/%d/role", bob.ID), adminToken, map[string]string{"role": "superuser"}); code != http.StatusBadRequest {
+		t.Fatalf("invalid role: %d %s", code, body)
+	}
+	if code, body = do("PATCH", fmt.Sprintf("/api/v1/admin/users/%d/role", bob.ID), adminToken, map[string]string{"role": "admin"}); code != http.StatusOK {
+		t.Fatalf("change role: %d %s", code, body)
+	}
+	if code, _ = do("POST", fmt.Sprintf("/api/v1/admin/users/%d/disable", admin.ID), adminToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected admins to be unable to disable themselves, got %d", code)
+	}
+
+	// Disabling rejects a still-valid token
+	if code, body = do("POST", fmt.Sprintf("/api/v1/admin/users/%d/disable", alice.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("disable: %d %s", code, body)
+	}
+	if code, _ = do("GET", "/api/v1/attendees/1/events", aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected disabled user's token to be rejected, got %d", code)
+	}
+	if code, body = do("POST", fmt.Sprintf("/api/v1/admin/users/%d/enable", alice.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("enable: %d %s", code, body)
+	}
+	if code, _ = do("GET", "/api/v1/attendees/1/events", aliceToken, nil); code != http.StatusOK {
+		t.Fatalf("expected re-enabled user's token to work, got %d", code)
+	}
+
+	if code, body = do("POST", fmt.Sprintf("/api/v1/admin/users/%d/force-password-reset", alice.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("force reset: %d %s", code, body)
+	}
+	if code, _ = do("GET", "/api/v1/attendees/1/events", aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("expected token to be rejected after forced reset, got %d", code)
+	}
+
+	code, body = do("GET", "/api/v1/admin/audit-log", adminToken, nil)
+	var entries []database.AuditEntry
+	json.Unmarshal(body, &entries)
+	if code != http.StatusOK || len(entries) != 4 {
+		t.Fatalf("audit log: %d %s", code, body)
+	}
+	want := []string{auditPasswordResetForced, auditUserEnabled, auditUserDisabled, auditRoleChanged}
+	for i, e := range entries {
+		if e.Action != want[i] || e.AdminID != admin.ID {
+			t.Fatalf("audit entry %d: got %+v, want action %s by %d", i, e, want[i], admin.ID)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scopes an API key can be granted. Routes outside these scopes, such as
+// account and admin management, are only reachable with a login session.
+const (
+	scopeEventsRead     = "events:read"
+	scopeEventsWrite    = "events:write"
+	scopeAttendeesWrite = "attendees:write"
+)
+
+var apiKeyScopes = map[string]bool{
+	scopeEventsRead:     true,
+	scopeEventsWrite:    true,
+	scopeAttendeesWrite: true,
+}
+
+const (
+	// apiKeyPrefix makes keys recognisable, e.g. to secret scanners.
+	apiKeyPrefix        = "ehk_"
+	apiKeyDisplayLength = 12
+	maxActiveAPIKeys    = 25
+)
+
+type createAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+type createAPIKeyResponse struct {
+	*database.APIKey
+	// Key is the secret itself. It is returned only once.
+	Key string `json:"key"`
+}
+
+// newAPIKey returns a random API key and the hash to store.
+func newAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, hashToken(key), nil
+}
+
+// authenticateAPIKey authenticates a request presenting "Authorization:
+// ApiKey <key>". It is the API key counterpart of the JWT checks in
+// jwtAuthMiddleware and stores the same context values, plus the key so
+// requireScope can check its scopes.
+func (app *application) authenticateAPIKey(c *gin.Context, presented string) {
+	key, err := app.models.APIKeys.GetActive(hashToken(presented))
+	if err != nil {
+		log.Printf("Error loading API key: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to validate API key",
+			"message": "An error occurred while validating your API key",
+		})
+		return
+	}
+	if key == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   "invalid API key",
+			"message": "API key is invalid, expired or revoked",
+		})
+		return
+	}
+
+	user, err := app.models.Users.Get(key.UserID)
+	if err != nil {
+		log.Printf("Error loading user %d: %v", key.UserID, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "failed to load user",
+			"message": "An error occurred while validating your account",
+		})
+		return
+	}
+	if user == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   "invalid API key",
+			"message": "API key is invalid, expired or revoked",
+		})
+		return
+	}
+	if user.Disabled() {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "account disabled",
+			"message": "This account has been disabled by an administrator",
+		})
+		return
+	}
+	if user.PasswordResetRequired {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "password reset required",
+			"message": "An administrator requires you to reset your password",
+		})
+		return
+	}
+
+	if err := app.models.APIKeys.TouchLastUsed(key.ID); err != nil {
+		log.Printf("Error recording use of API key %d: %v", key.ID, err)
+	}
+
+	c.Set("user", user)
+	c.Set("user_id", user.ID)
+	c.Set("api_key", key)
+	c.Next()
+}
+
+// apiKeyFromContext returns the API key the request authenticated with, or
+// nil for a login session.
+func apiKeyFromContext(c *gin.Context) *database.APIKey {
+	if k, ok := c.Get("api_key"); ok {
+		return k.(*database.APIKey)
+	}
+	return nil
+}
+
+// requireScope lets through login sessions and API keys granted scope. It
+// must run after jwtAuthMiddleware.
+func (app *application) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := apiKeyFromContext(c); key != nil && !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "insufficient scope",
+				"message": "This API key lacks the " + scope + " scope",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// sessionOnly refuses API keys, for routes that manage the account itself.
+// It must run after jwtAuthMiddleware.
+func (app *application) sessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKeyFromContext(c) != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "session required",
+				"message": "This endpoint cannot be used with an API key",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// @Summary Create an API key
+// @Description Create a named API key with scopes (events:read, events:write, attendees:write) for use as "Authorization: ApiKey <key>". The key is returned only once.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param key body createAPIKeyRequest true "Key name, scopes and optional lifetime"
+// @Success 201 {object} createAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/api-keys [post]
+func (app *application) createAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	seen := make(map[string]bool, len(req.Scopes))
+	var scopes []string
+	for _, s := range req.Scopes {
+		if !apiKeyScopes[s] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + s})
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	active, err := app.models.APIKeys.CountActive(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	if active >= maxActiveAPIKeys {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many active API keys; revoke one first"})
+		return
+	}
+
+	secret, hash, err := newAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	key := &database.APIKey{
+		UserID: user.ID,
+		Name:   req.Name,
+		Prefix: secret[:apiKeyDisplayLength],
+		Scopes: scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour).UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	if err := app.models.APIKeys.Insert(key, hash); err != nil {
+		log.Printf("createAPIKey: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createAPIKeyResponse{APIKey: key, Key: secret})
+}
+
+// @Summary List API keys
+// @Description List the authenticated user's API keys, including revoked and expired ones. Keys themselves are never shown again.
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} database.APIKey
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/api-keys [get]
+func (app *application) listAPIKeys(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	keys, err := app.models.APIKeys.ListForUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+	if keys == nil {
+		keys = []*database.APIKey{}
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Revoke an API key
+// @Description Revoke one of the authenticated user's API keys; it stops working immediately
+// @Tags Auth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/api-keys/{id} [delete]
+func (app *application) revokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	revoked, err := app.models.APIKeys.Revoke(user.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
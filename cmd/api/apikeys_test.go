@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	user := insertUserWithPassword(t, app, "bot-owner@example.com", "password123")
+	session, err := jwtForUser(app, user.ID)
+	if err != nil {
+		t.Fatalf("jwt: %v", err)
+	}
+
+	withKey := func(method, path, key string, body interface{}) (int, []byte) {
+		t.Helper()
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, ts.URL+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+
+	if code, body := api.do("POST", "/api/v1/auth/api-keys", session, map[string]interface{}{"name": "ci", "scopes": []string{"events:admin"}}); code != http.StatusBadRequest {
+		t.Fatalf("unknown scope: %d %s", code, body)
+	}
+
+	code, body := api.do("POST", "/api/v1/auth/api-keys", session, map[string]interface{}{
+		"name": "ci", "scopes": []string{"events:write", "events:read"}, "expires_in_days": 30,
+	})
+	var created createAPIKeyResponse
+	if code != http.StatusCreated || json.Unmarshal(body, &created) != nil || !strings.HasPrefix(created.Key, apiKeyPrefix) || created.ExpiresAt == nil {
+		t.Fatalf("create key: %d %s", code, body)
+	}
+	key := created.Key
+
+	// The key itself is never shown again
+	code, body = api.do("GET", "/api/v1/auth/api-keys", session, nil)
+	if code != http.StatusOK || strings.Contains(string(body), key) || !strings.Contains(string(body), created.Prefix) {
+		t.Fatalf("list keys: %d %s", code, body)
+	}
+
+	event := map[string]string{"title": "Nightly build", "description": "Created by CI", "start_time": "2030-01-01T10:00:00Z", "end_time": "2030-01-01T12:00:00Z"}
+	code, body = withKey("POST", "/api/v1/events", key, event)
+	var ev struct {
+		ID int `json:"id"`
+	}
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+		t.Fatalf("create event with key: %d %s", code, body)
+	}
+	if code, body = withKey("GET", "/api/v1/events/"+strconv.Itoa(ev.ID)+"/attendees", key, nil); code != http.StatusOK {
+		t.Fatalf("list attendees with key: %d %s", code, body)
+	}
+	if code, body = withKey("GET", "/api/v1/auth/me", key, nil); code != http.StatusOK {
+		t.Fatalf("me with key: %d %s", code, body)
+	}
+
+	// Scopes are enforced per route group
+	if code, body = withKey("POST", "/api/v1/events/"+strconv.Itoa(ev.ID)+"/attendees", key, map[string]int{"user_id": user.ID}); code != http.StatusForbidden || !strings.Contains(string(body), "insufficient scope") {
+		t.Fatalf("missing scope: %d %s", code, body)
+	}
+	// Keys cannot manage the account, including minting more keys
+	if code, body = withKey("POST", "/api/v1/auth/api-keys", key, map[string]interface{}{"name": "x", "scopes": []string{"events:read"}}); code != http.StatusForbidden {
+		t.Fatalf("key creating key: %d %s", code, body)
+	}
+	if code, body = withKey("POST", "/api/v1/auth/me/password", key, map[string]string{"current_password": "password123", "new_password": "password456"}); code != http.StatusForbidden {
+		t.Fatalf("key changing password: %d %s", code, body)
+	}
+
+	if code, body = withKey("GET", "/api/v1/events/1/attendees", "ehk_not-a-key", nil); code != http.StatusUnauthorized {
+		t.Fatalf("invalid key: %d %s", code, body)
+	}
+
+	if code, body = api.do("DELETE", "/api/v1/auth/api-keys/"+strconv.Itoa(created.ID), session, nil); code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", code, body)
+	}
+	if code, body = withKey("GET", "/api/v1/auth/me", key, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: %d %s", code, body)
+	}
+	if code, _ = api.do("DELETE", "/api/v1/auth/api-keys/"+strconv.Itoa(created.ID), session, nil); code != http.StatusNotFound {
+		t.Fatalf("revoke twice: expected 404, got %d", code)
+	}
+}
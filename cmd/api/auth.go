@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -26,19 +25,40 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token string        `json:"token"`
-	User  *database.User `json:"user"`
+	Token        string         `json:"token"`
+	RefreshToken string         `json:"refresh_token"`
+	ExpiresIn    int            `json:"expires_in"`
+	User         *database.User `json:"user"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type updateProfileRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=2,max=100"`
+	Email           *string `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+	Confirm         string `json:"confirm" binding:"required,eqfield=NewPassword"`
 }
 
 // @Summary Login user
-// @Description Authenticate a user and return a JWT token
+// @Description Authenticate a user and return a short-lived JWT access token and a refresh token. Accounts with two-factor authentication instead get 202 with mfa_required and an mfa_token to exchange at /auth/mfa/verify. Repeated failures lock the account and the client IP with exponential backoff; locked logins get 429 with a Retry-After header.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param credentials body loginRequest true "Login credentials"
 // @Success 200 {object} loginResponse
+// @Success 202 {object} mfaChallengeResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
 // @Router /api/v1/auth/login [post]
 func (app *application) loginUser(c *gin.Context) {
 	var req loginRequest
@@ -47,29 +67,71 @@ func (app *application) loginUser(c *gin.Context) {
 		return
 	}
 
+	if !app.checkLoginLock(c, req.Email) {
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
 		return
 	}
-	if user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+
+	// Always run bcrypt so unknown emails fail as slowly as wrong passwords
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(req.Password))
+	if err != nil || user == nil {
+		app.recordLoginFailure(c, req.Email, user, "Invalid email or password")
 		return
 	}
+	if err := app.models.LoginThrottle.Clear(accountThrottleKey(req.Email)); err != nil {
+		log.Printf("loginUser: clear failed attempts: %v", err)
+	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	app.completeLogin(c, user)
+}
+
+// completeLogin finishes a login whose first factor succeeded: it refuses
+// disabled accounts and pending password resets, returns an MFA challenge if
+// the account has two-factor authentication enabled, and otherwise starts a
+// session.
+func (app *application) completeLogin(c *gin.Context, user *database.User) {
+	if user.Disabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-	})
+	if user.PasswordResetRequired {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required"})
+		return
+	}
+
+	if user.MFAEnabled {
+		challenge, err := app.issueMFAChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusAccepted, mfaChallengeResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+			ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	// Generate access and refresh tokens
+	app.respondWithSession(c, user)
+}
 
-	// Generate JWT token
-	tokenString, err := token.SignedString([]byte(app.jwtSecret))
+// respondWithSession starts a new login session for user and writes its
+// token pair together with the user.
+func (app *application) respondWithSession(c *gin.Context, user *database.User) {
+	pair, err := app.startSession(user)
 	if err != nil {
+		log.Printf("startSession: user %d: %v", user.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
@@ -78,8 +140,10 @@ func (app *application) loginUser(c *gin.Context) {
 	user.Password = ""
 
 	c.JSON(http.StatusOK, loginResponse{
-		Token: tokenString,
-		User:  user,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+		User:         user,
 	})
 }
 
@@ -124,6 +188,10 @@ func (app *application) createUser(c *gin.Context) {
 		return
 	}
 
+	app.background(func() {
+		app.sendUserTokenEmail(user, database.TokenPurposeEmailVerification)
+	})
+
 	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
@@ -147,3 +215,223 @@ func (app *application) getCurrentUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// @Summary Update current user
+// @Description Change the authenticated user's name and/or email. Changing the email requires current_password and revokes existing tokens and sessions; a fresh token pair is returned.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param profile body updateProfileRequest true "Fields to change"
+// @Success 200 {object} loginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/me [patch]
+func (app *application) updateCurrentUser(c *gin.Context) {
+	var req updateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Name == nil && req.Email == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Email != nil && !strings.EqualFold(*req.Email, user.Email) {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)) != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		user.Email = *req.Email
+	}
+
+	oldVersion := user.TokenVersion
+	if err := app.models.Users.UpdateProfile(user); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+			return
+		}
+		log.Printf("updateCurrentUser: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if user.TokenVersion != oldVersion {
+		if err := app.models.Tokens.RevokeUserSessions(user.ID, database.RevokeReasonCredentialsChange); err != nil {
+			log.Printf("updateCurrentUser: revoke sessions: %v", err)
+		}
+		// Links mailed to the old address must not act on the new one
+		if err := app.models.Tokens.DeleteUserTokens(user.ID, ""); err != nil {
+			log.Printf("updateCurrentUser: delete mailed tokens: %v", err)
+		}
+		verifyUser := *user
+		app.background(func() {
+			app.sendUserTokenEmail(&verifyUser, database.TokenPurposeEmailVerification)
+		})
+	}
+
+	app.respondWithSession(c, user)
+}
+
+// @Summary Change password
+// @Description Change the authenticated user's password. Requires the current password and revokes all existing tokens and sessions; a fresh token pair is returned.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param passwords body changePasswordRequest true "Current and new password"
+// @Success 200 {object} loginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/me/password [post]
+func (app *application) changePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user.TokenVersion, err = app.models.Users.UpdatePassword(user.ID, string(hashedPassword))
+	if err != nil {
+		log.Printf("changePassword: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := app.models.Tokens.RevokeUserSessions(user.ID, database.RevokeReasonCredentialsChange); err != nil {
+		log.Printf("changePassword: revoke sessions: %v", err)
+	}
+	if err := app.models.Tokens.DeleteUserTokens(user.ID, database.TokenPurposePasswordReset); err != nil {
+		log.Printf("changePassword: delete reset tokens: %v", err)
+	}
+
+	app.respondWithSession(c, user)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; presenting a used one revokes the whole session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token body refreshRequest true "Refresh token"
+// @Success 200 {object} loginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/auth/refresh [post]
+func (app *application) refreshToken(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	newToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	sessionID, userID, err := app.models.Tokens.RotateRefreshToken(hashToken(req.RefreshToken), newHash, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		switch err {
+		case database.ErrRefreshTokenReused:
+			log.Printf("refreshToken: reuse detected from %s, session revoked", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		case database.ErrRefreshTokenInvalid:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		default:
+			log.Printf("refreshToken: db error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	user, err := app.models.Users.Get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if user == nil || user.Disabled() || user.PasswordResetRequired {
+		app.models.Tokens.RevokeSession(sessionID, database.RevokeReasonCredentialsChange)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	accessToken, err := app.issueAccessToken(user, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, loginResponse{
+		Token:        accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		User:         user,
+	})
+}
+
+// @Summary Logout
+// @Description Revoke the current access token and its session, including the session's refresh tokens. With all=true every session of the user is revoked.
+// @Tags Auth
+// @Produce json
+// @Param all query bool false "Revoke every session of the user"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/logout [post]
+func (app *application) logout(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if jti := c.GetString("token_jti"); jti != "" {
+		expiresAt := time.Now().Add(accessTokenTTL)
+		if exp, ok := c.Get("token_exp"); ok {
+			expiresAt = exp.(time.Time)
+		}
+		if err := app.models.Tokens.RevokeAccessToken(jti, expiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+
+	if c.Query("all") == "true" {
+		err = app.models.Tokens.RevokeUserSessions(user.ID, database.RevokeReasonLogout)
+	} else if sid := c.GetString("token_sid"); sid != "" {
+		err = app.models.Tokens.RevokeSession(sid, database.RevokeReasonLogout)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiClient is a small helper for JSON requests against a test server.
+type apiClient struct {
+	t  *testing.T
+	ts *httptest.Server
+}
+
+func (a apiClient) do(method, path, token string, body interface{}) (int, []byte) {
+	a.t.Helper()
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, a.ts.URL+path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes()
+}
+
+func insertUserWithPassword(t *testing.T, app *application, email, password string) *database.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	u := &database.User{Email: email, Name: "Test User", Password: string(hash)}
+	if err := app.models.Users.Insert(u); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return u
+}
+
+func TestCurrentUserProfile(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := insertUserWithPassword(t, app, "me@example.com", "password123")
+	insertUserWithPassword(t, app, "taken@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	token, _ := jwtForUser(app, u.ID)
+
+	code, body := api.do("GET", "/api/v1/auth/me", token, nil)
+	if code != http.StatusOK || !bytes.Contains(body, []byte(`"email":"me@example.com"`)) {
+		t.Fatalf("GET /auth/me: %d %s", code, body)
+	}
+
+	// Renaming keeps existing tokens valid
+	if code, body = api.do("PATCH", "/api/v1/auth/me", token, map[string]string{"name": "Renamed"}); code != http.StatusOK {
+		t.Fatalf("rename: %d %s", code, body)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", token, nil); code != http.StatusOK {
+		t.Fatalf("expected token to survive rename, got %d", code)
+	}
+
+	if code, _ = api.do("PATCH", "/api/v1/auth/me", token, map[string]string{"email": "new@example.com"}); code != http.StatusUnauthorized {
+		t.Fatalf("expected email change without password to fail, got %d", code)
+	}
+	if code, _ = api.do("PATCH", "/api/v1/auth/me", token, map[string]string{"email": "taken@example.com", "current_password": "password123"}); code != http.StatusConflict {
+		t.Fatalf("expected 409 for taken email, got %d", code)
+	}
+
+	code, body = api.do("PATCH", "/api/v1/auth/me", token, map[string]string{"email": "new@example.com", "current_password": "password123"})
+	var res loginResponse
+	json.Unmarshal(body, &res)
+	if code != http.StatusOK || res.Token == "" || res.User.Email != "new@example.com" {
+		t.Fatalf("change email: %d %s", code, body)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected old token to be revoked after email change, got %d", code)
+	}
+	token = res.Token
+
+	if code, _ = api.do("POST", "/api/v1/auth/me/password", token, map[string]string{"current_password": "wrong-password", "new_password": "newpassword1", "confirm": "newpassword1"}); code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong current password to fail, got %d", code)
+	}
+	code, body = api.do("POST", "/api/v1/auth/me/password", token, map[string]string{"current_password": "password123", "new_password": "newpassword1", "confirm": "newpassword1"})
+	json.Unmarshal(body, &res)
+	if code != http.StatusOK || res.Token == "" {
+		t.Fatalf("change password: %d %s", code, body)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected old token to be revoked after password change, got %d", code)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", res.Token, nil); code != http.StatusOK {
+		t.Fatalf("expected new token to work, got %d", code)
+	}
+
+	if code, _ = api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "new@example.com", "password": "newpassword1"}); code != http.StatusOK {
+		t.Fatalf("expected login with new credentials, got %d", code)
+	}
+}
+
+func TestRefreshTokenRotationAndLogout(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	insertUserWithPassword(t, app, "r@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	login := func() loginResponse {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": "r@example.com", "password": "password123"})
+		var res loginResponse
+		json.Unmarshal(body, &res)
+		if code != http.StatusOK || res.Token == "" || res.RefreshToken == "" || res.ExpiresIn != int(accessTokenTTL.Seconds()) {
+			t.Fatalf("login: %d %s", code, body)
+		}
+		return res
+	}
+	refresh := func(token string) (int, loginResponse) {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/auth/refresh", "", map[string]string{"refresh_token": token})
+		var res loginResponse
+		json.Unmarshal(body, &res)
+		return code, res
+	}
+
+	first := login()
+	code, second := refresh(first.RefreshToken)
+	if code != http.StatusOK || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh: %d %+v", code, second)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", second.Token, nil); code != http.StatusOK {
+		t.Fatalf("expected refreshed access token to work, got %d", code)
+	}
+
+	// Replaying the rotated token revokes the whole family
+	if code, _ = refresh(first.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected reuse to be rejected, got %d", code)
+	}
+	if code, _ = refresh(second.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected sibling refresh token to be revoked after reuse, got %d", code)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", second.Token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected access token of revoked session to be rejected, got %d", code)
+	}
+
+	// Logout revokes the access token and the session's refresh token,
+	// but leaves other sessions alone
+	a, b := login(), login()
+	if code, _ = api.do("POST", "/api/v1/auth/logout", a.Token, nil); code != http.StatusOK {
+		t.Fatalf("logout: %d", code)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", a.Token, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected logged out token to be rejected, got %d", code)
+	}
+	if code, _ = refresh(a.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected logged out refresh token to be rejected, got %d", code)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", b.Token, nil); code != http.StatusOK {
+		t.Fatalf("expected other session to survive logout, got %d", code)
+	}
+
+	if code, _ = api.do("POST", "/api/v1/auth/logout?all=true", b.Token, nil); code != http.StatusOK {
+		t.Fatalf("logout all: %d", code)
+	}
+	if code, _ = refresh(b.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected all sessions to be revoked, got %d", code)
+	}
+
+	if code, _ = refresh("not-a-real-token"); code != http.StatusUnauthorized {
+		t.Fatalf("expected unknown refresh token to be rejected, got %d", code)
+	}
+
+	// Revoked sessions are purged once the retention period has passed,
+	// live ones are kept
+	live := login()
+	if n, err := app.models.Tokens.PurgeSessions(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("purge within the retention period: %d %v", n, err)
+	}
+	if n, err := app.models.Tokens.PurgeSessions(time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Fatalf("purge revoked sessions: %d %v", n, err)
+	}
+	var tokens int
+	if err := app.models.Tokens.DB.QueryRow(`SELECT COUNT(*) FROM refresh_tokens`).Scan(&tokens); err != nil || tokens != 1 {
+		t.Fatalf("refresh tokens left after the purge: %d %v", tokens, err)
+	}
+	if code, _ = refresh(live.RefreshToken); code != http.StatusOK {
+		t.Fatalf("expected live session to survive the purge, got %d", code)
+	}
+}
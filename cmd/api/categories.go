@@ -0,0 +1,267 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"rest-api-in-gin/internal/database"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit log actions recorded by the category admin API
+const (
+	auditCategoryCreated = "category.created"
+	auditCategoryUpdated = "category.updated"
+	auditCategoryDeleted = "category.deleted"
+)
+
+// categorySlug is the form of category slugs: lower-case words joined by
+// hyphens.
+var categorySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// normalizeTags trims, lower-cases and sorts ev's tags and drops
+// duplicates. Tags may not contain commas, which separate them in the tags
+// filter.
+func normalizeTags(ev *database.Event) fieldErrors {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, tag := range ev.Tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		switch {
+		case tag == "":
+			return fieldErrors{"tags": "must not be blank"}
+		case strings.Contains(tag, ","):
+			return fieldErrors{"tags": "must not contain commas"}
+		case !seen[tag]:
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	ev.Tags = tags
+	return nil
+}
+
+// checkEventCategory checks that the category ev links to exists and fills
+// in its slug. It responds with 400 and returns false otherwise.
+func (app *application) checkEventCategory(c *gin.Context, ev *database.Event) bool {
+	ev.Category = ""
+	if ev.CategoryID == nil {
+		return true
+	}
+	category, err := app.models.Categories.Get(*ev.CategoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve category"})
+		return false
+	}
+	if category == nil {
+		invalidRequest(c, fieldErrors{"category_id": "does not exist"})
+		return false
+	}
+	ev.Category = category.Slug
+	return true
+}
+
+// splitList splits a comma-separated query parameter, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTopicFilter reads the category, tags and tags_match query
+// parameters into f, responding with 400 if they are invalid.
+func parseTopicFilter(c *gin.Context, f *database.EventFilter) bool {
+	f.Categories = splitList(c.Query("category"))
+	seen := map[string]bool{}
+	for _, tag := range splitList(c.Query("tags")) {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if !seen[tag] {
+			seen[tag] = true
+			f.Tags = append(f.Tags, tag)
+		}
+	}
+
+	switch c.DefaultQuery("tags_match", "any") {
+	case "any":
+	case "all":
+		f.AllTags = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags_match, must be any or all"})
+		return false
+	}
+	return true
+}
+
+// @Summary List categories
+// @Description List the categories events can be grouped by, ordered by name
+// @Tags Categories
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/categories [get]
+func (app *application) listCategories(c *gin.Context) {
+	categories, err := app.models.Categories.List()
+	if err != nil {
+		log.Printf("listCategories: db list error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
+		return
+	}
+	if categories == nil {
+		categories = []*database.Category{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": categories})
+}
+
+// @Summary Create a category
+// @Description Create an event category (admin only). The slug is what event listings filter by.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param category body database.Category true "Category payload"
+// @Success 201 {object} database.Category
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/categories [post]
+func (app *application) adminCreateCategory(c *gin.Context) {
+	var category database.Category
+	if !bindCategory(c, &category) {
+		return
+	}
+
+	if err := app.models.Categories.Insert(&category); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this slug already exists"})
+			return
+		}
+		log.Printf("adminCreateCategory: db insert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+	app.auditCategory(c, auditCategoryCreated, &category)
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// @Summary Update a category
+// @Description Rename a category or change its slug (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param category body database.Category true "Category payload"
+// @Success 200 {object} database.Category
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/categories/{id} [put]
+func (app *application) adminUpdateCategory(c *gin.Context) {
+	existing := app.loadCategory(c)
+	if existing == nil {
+		return
+	}
+
+	var category database.Category
+	if !bindCategory(c, &category) {
+		return
+	}
+
+	category.ID = existing.ID
+	if err := app.models.Categories.Update(&category); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this slug already exists"})
+			return
+		}
+		log.Printf("adminUpdateCategory: db update error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+	app.auditCategory(c, auditCategoryUpdated, &category)
+
+	c.JSON(http.StatusOK, category)
+}
+
+// @Summary Delete a category
+// @Description Delete a category (admin only). Its events are left without a category.
+// @Tags Admin
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/categories/{id} [delete]
+func (app *application) adminDeleteCategory(c *gin.Context) {
+	category := app.loadCategory(c)
+	if category == nil {
+		return
+	}
+
+	if err := app.models.Categories.Delete(category.ID); err != nil {
+		log.Printf("adminDeleteCategory: db delete error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	app.auditCategory(c, auditCategoryDeleted, category)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// bindCategory binds and validates a category payload, responding with 400
+// if it is invalid.
+func bindCategory(c *gin.Context, category *database.Category) bool {
+	if err := c.ShouldBindJSON(category); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return false
+	}
+	if !categorySlug.MatchString(category.Slug) {
+		invalidRequest(c, fieldErrors{"slug": "must be lower-case letters and digits separated by hyphens"})
+		return false
+	}
+	return true
+}
+
+// loadCategory loads the category named by the id path parameter,
+// responding with 400 or 404 if there is none.
+func (app *application) loadCategory(c *gin.Context) *database.Category {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return nil
+	}
+	category, err := app.models.Categories.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve category"})
+		return nil
+	}
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return nil
+	}
+	return category
+}
+
+// auditCategory records a change to a category in the audit log.
+func (app *application) auditCategory(c *gin.Context, action string, category *database.Category) {
+	admin, err := app.getUserFromContext(c)
+	if err != nil {
+		return
+	}
+	details := gin.H{"category_id": category.ID, "slug": category.Slug}
+	if err := app.models.Audit.Insert(admin.ID, action, nil, details); err != nil {
+		log.Printf("audit: failed to record %s by %d: %v", action, admin.ID, err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestCategoriesAndTags(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	if err := app.models.Users.Insert(admin); err != nil {
+		t.Fatalf("insert admin: %v", err)
+	}
+	adminToken, _ := jwtForUser(app, admin.ID)
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	token, _ := jwtForUser(app, owner.ID)
+
+	// Only admins manage categories
+	if code, _ := api.do("POST", "/api/v1/admin/categories", token, map[string]interface{}{"slug": "workshops", "name": "Workshops"}); code != http.StatusForbidden {
+		t.Fatalf("create category as user: expected 403, got %d", code)
+	}
+	if code, body := api.do("POST", "/api/v1/admin/categories", adminToken, map[string]interface{}{"slug": "Work Shops", "name": "Workshops"}); code != http.StatusBadRequest || !strings.Contains(string(body), `"slug"`) {
+		t.Fatalf("invalid slug: %d %s", code, body)
+	}
+	category := func(slug, name string) database.Category {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/admin/categories", adminToken, map[string]interface{}{"slug": slug, "name": name})
+		var cat database.Category
+		if code != http.StatusCreated || json.Unmarshal(body, &cat) != nil {
+			t.Fatalf("create category %s: %d %s", slug, code, body)
+		}
+		return cat
+	}
+	workshops := category("workshops", "Workshops")
+	meetups := category("meetups", "Meetups")
+	if code, _ := api.do("POST", "/api/v1/admin/categories", adminToken, map[string]interface{}{"slug": "meetups", "name": "Other"}); code != http.StatusConflict {
+		t.Fatalf("duplicate slug: expected 409, got %d", code)
+	}
+	code, body := api.do("GET", "/api/v1/categories", "", nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"meetups"`) {
+		t.Fatalf("list categories: %d %s", code, body)
+	}
+
+	create := func(title string, categoryID int, tags ...string) database.Event {
+		t.Helper()
+		event := map[string]interface{}{"title": title, "description": "Something worth attending", "start_time": "2030-03-01T10:00:00Z", "end_time": "2030-03-01T12:00:00Z", "tags": tags}
+		if categoryID > 0 {
+			event["category_id"] = categoryID
+		}
+		code, body := api.do("POST", "/api/v1/events", token, event)
+		var ev database.Event
+		if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+			t.Fatalf("create event %s: %d %s", title, code, body)
+		}
+		return ev
+	}
+
+	if code, body := api.do("POST", "/api/v1/events", token, map[string]interface{}{"title": "Broken", "description": "Something worth attending", "start_time": "2030-03-01T10:00:00Z", "end_time": "2030-03-01T12:00:00Z", "category_id": 999}); code != http.StatusBadRequest || !strings.Contains(string(body), `"category_id"`) {
+		t.Fatalf("unknown category: %d %s", code, body)
+	}
+	if code, body := api.do("POST", "/api/v1/events", token, map[string]interface{}{"title": "Broken", "description": "Something worth attending", "start_time": "2030-03-01T10:00:00Z", "end_time": "2030-03-01T12:00:00Z", "tags": []string{"a,b"}}); code != http.StatusBadRequest || !strings.Contains(string(body), `"tags"`) {
+		t.Fatalf("tag with a comma: %d %s", code, body)
+	}
+
+	// Tags are normalized and returned sorted
+	goWorkshop := create("Go workshop", workshops.ID, " Go ", "Beginner", "go")
+	if goWorkshop.Category != "workshops" || !reflect.DeepEqual(goWorkshop.Tags, []string{"beginner", "go"}) {
+		t.Fatalf("created event: %q %v", goWorkshop.Category, goWorkshop.Tags)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d", goWorkshop.ID), "", nil)
+	var fetched database.Event
+	if code != http.StatusOK || json.Unmarshal(body, &fetched) != nil || !reflect.DeepEqual(fetched.Tags, []string{"beginner", "go"}) || *fetched.CategoryID != workshops.ID {
+		t.Fatalf("get event: %d %s", code, body)
+	}
+	create("Docker workshop", workshops.ID, "docker", "beginner")
+	create("Gophers meetup", meetups.ID, "go")
+	create("Untagged", 0)
+
+	type listing struct {
+		Data   []database.Event     `json:"data"`
+		Facets database.EventFacets `json:"facets"`
+	}
+	list := func(query string) listing {
+		t.Helper()
+		code, body := api.do("GET", "/api/v1/events?"+query, "", nil)
+		var resp listing
+		if code != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("list ?%s: %d %s", query, code, body)
+		}
+		return resp
+	}
+	titles := func(l listing) []string {
+		var out []string
+		for _, ev := range l.Data {
+			out = append(out, ev.Title)
+		}
+		return out
+	}
+
+	// Facets count every event matching the filter
+	all := list("")
+	if len(all.Facets.Categories) != 2 || all.Facets.Categories[0].Slug != "workshops" || all.Facets.Categories[0].Count != 2 || all.Facets.Categories[1].Count != 1 {
+		t.Fatalf("category facets: %+v", all.Facets.Categories)
+	}
+	if len(all.Facets.Tags) != 3 || all.Facets.Tags[0].Tag != "beginner" || all.Facets.Tags[0].Count != 2 || all.Facets.Tags[2].Tag != "docker" {
+		t.Fatalf("tag facets: %+v", all.Facets.Tags)
+	}
+
+	if got := titles(list("category=meetups")); !reflect.DeepEqual(got, []string{"Gophers meetup"}) {
+		t.Fatalf("category=meetups: %v", got)
+	}
+	if got := titles(list("tags=go,docker")); len(got) != 3 {
+		t.Fatalf("tags=go,docker (any): %v", got)
+	}
+	filtered := list("tags=GO,beginner,go&tags_match=all")
+	if got := titles(filtered); !reflect.DeepEqual(got, []string{"Go workshop"}) {
+		t.Fatalf("tags=go,beginner (all): %v", got)
+	}
+	if len(filtered.Facets.Categories) != 1 || filtered.Facets.Categories[0].Count != 1 {
+		t.Fatalf("facets follow the filter: %+v", filtered.Facets.Categories)
+	}
+	if code, _ := api.do("GET", "/api/v1/events?tags=go&tags_match=some", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid tags_match: expected 400, got %d", code)
+	}
+
+	// Updates that omit tags keep them
+	update := map[string]interface{}{"user_id": owner.ID, "title": "Go workshop", "description": "Something worth attending", "start_time": "2030-03-01T10:00:00Z", "end_time": "2030-03-01T13:00:00Z", "category_id": meetups.ID}
+	code, body = api.do("PUT", fmt.Sprintf("/api/v1/events/%d", goWorkshop.ID), token, update)
+	var updated database.Event
+	if code != http.StatusOK || json.Unmarshal(body, &updated) != nil || updated.Category != "meetups" || !reflect.DeepEqual(updated.Tags, []string{"beginner", "go"}) {
+		t.Fatalf("update event: %d %s", code, body)
+	}
+	update["tags"] = []string{}
+	if code, _ := api.do("PUT", fmt.Sprintf("/api/v1/events/%d", goWorkshop.ID), token, update); code != http.StatusOK {
+		t.Fatalf("clear tags: %d", code)
+	}
+	if got := titles(list("tags=beginner")); !reflect.DeepEqual(got, []string{"Docker workshop"}) {
+		t.Fatalf("after clearing tags: %v", got)
+	}
+
+	// Occurrences of recurring events get the series' category and tags
+	start := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Hour)
+	code, body = api.do("POST", "/api/v1/events", token, map[string]interface{}{
+		"title": "Daily study group", "description": "Reading the Go spec together",
+		"start_time": start.Format(time.RFC3339), "end_time": start.Add(time.Hour).Format(time.RFC3339),
+		"recurrence": "FREQ=DAILY;COUNT=3", "category_id": meetups.ID, "tags": []string{"study"},
+	})
+	var first database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &first) != nil {
+		t.Fatalf("create series: %d %s", code, body)
+	}
+	if got := list("tags=study"); len(got.Data) != 3 || got.Data[2].Category != "meetups" {
+		t.Fatalf("series occurrences: %v", titles(got))
+	}
+	seriesUpdate := map[string]interface{}{"user_id": owner.ID, "title": "Daily study group", "description": "Reading the Go spec together", "start_time": first.StartTime, "end_time": first.EndTime, "tags": []string{"reading"}}
+	if code, body := api.do("PUT", fmt.Sprintf("/api/v1/events/%d?scope=all", first.ID), token, seriesUpdate); code != http.StatusOK {
+		t.Fatalf("update series tags: %d %s", code, body)
+	}
+	if got := list("tags=reading"); len(got.Data) != 3 || got.Data[0].Category != "" {
+		t.Fatalf("after series update: %v", titles(got))
+	}
+
+	// Deleting a category leaves its events without one
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/categories/%d", meetups.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("delete category: %d", code)
+	}
+	if got := list("category=meetups"); len(got.Data) != 0 {
+		t.Fatalf("events in a deleted category: %v", titles(got))
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"rest-api-in-gin/internal/database"
 
 	"github.com/gin-gonic/gin"
@@ -20,3 +21,34 @@ func (app *application) getUserFromContext(c *gin.Context) (*database.User, erro
 
 	return user, nil
 }
+
+// viewer describes the authenticated user for event visibility checks, or
+// an anonymous visitor if the request carries no credentials.
+func (app *application) viewer(c *gin.Context) database.Viewer {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		return database.Viewer{}
+	}
+
+	v := database.Viewer{UserID: user.ID, Admin: user.Role == database.RoleAdmin}
+	if user.EmailVerified() {
+		v.Email = user.Email
+	}
+	return v
+}
+
+// requireEventVisible checks that the requester may see ev. Private events
+// the requester has no access to are answered with 404 so their existence
+// is not revealed.
+func (app *application) requireEventVisible(c *gin.Context, ev *database.Event) bool {
+	visible, err := app.models.Events.VisibleTo(ev, app.viewer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errInvalidCursor = errors.New("invalid cursor")
+	errCursorScope   = errors.New("cursor issued for another listing")
+)
+
+// cursorPayload is the signed content of an opaque pagination cursor.
+type cursorPayload struct {
+	Key    string `json:"k,omitempty"`
+	ID     int    `json:"i"`
+	Before bool   `json:"b,omitempty"`
+	Scope  string `json:"s"`
+}
+
+// cursorKey derives the HMAC key used to sign cursors so a leaked cursor
+// signature can never be used to forge tokens.
+func (app *application) cursorKey() []byte {
+	sum := sha256.Sum256([]byte("eventhub-cursor:" + app.jwtSecret))
+	return sum[:]
+}
+
+// cursorScope identifies the listing a request pages through: its path and
+// filters, i.e. every non-empty query parameter but the pagination ones. A
+// cursor only continues the listing it was issued for, since the same
+// position means different rows under other filters.
+func cursorScope(c *gin.Context) string {
+	filters := c.Request.URL.Query()
+	for name, values := range filters {
+		kept := values[:0]
+		for _, v := range values {
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		switch {
+		case name == "cursor" || name == "limit" || name == "page" || len(kept) == 0:
+			filters.Del(name)
+		default:
+			sort.Strings(kept)
+			filters[name] = kept
+		}
+	}
+	sum := sha256.Sum256([]byte(c.Request.URL.Path + "?" + filters.Encode()))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// encodeCursor returns an opaque, tamper-proof token for cur in the listing
+// requested by c.
+func (app *application) encodeCursor(c *gin.Context, cur database.Cursor) string {
+	body, _ := json.Marshal(cursorPayload{Key: cur.Key, ID: cur.ID, Before: cur.Before, Scope: cursorScope(c)})
+
+	mac := hmac.New(sha256.New, app.cursorKey())
+	mac.Write(body)
+
+	return base64.RawURLEncoding.EncodeToString(body) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// decodeCursor verifies and decodes a token produced by encodeCursor. It
+// returns errCursorScope if the token belongs to a listing other than the
+// one requested by c.
+func (app *application) decodeCursor(c *gin.Context, token string) (*database.Cursor, error) {
+	bodyPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	body, err := base64.RawURLEncoding.DecodeString(bodyPart)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, app.cursorKey())
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, errInvalidCursor
+	}
+	if p.Scope != cursorScope(c) {
+		return nil, errCursorScope
+	}
+	return &database.Cursor{Key: p.Key, ID: p.ID, Before: p.Before}, nil
+}
+
+// keysetPage trims a result fetched with limit+1 rows from cur down to limit
+// and reports whether pages exist after and before it. Backward pages carry
+// their extra row at the front.
+func keysetPage[T any](rows []T, limit int, cur *database.Cursor) (page []T, hasNext, hasPrev bool) {
+	hasMore := len(rows) > limit
+	if cur == nil || !cur.Before {
+		if hasMore {
+			rows = rows[:limit]
+		}
+		return rows, hasMore, cur != nil
+	}
+	if hasMore {
+		rows = rows[len(rows)-limit:]
+	}
+	return rows, true, hasMore
+}
+
+// pageCursors builds the next/prev cursor tokens for a page of rows ordered
+// by keyOf. A nil token means there is no page in that direction.
+func pageCursors[T any](app *application, c *gin.Context, rows []T, hasNext, hasPrev bool, keyOf func(T) (string, int)) (next, prev *string) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	if hasNext {
+		k, id := keyOf(rows[len(rows)-1])
+		token := app.encodeCursor(c, database.Cursor{Key: k, ID: id})
+		next = &token
+	}
+	if hasPrev {
+		k, id := keyOf(rows[0])
+		token := app.encodeCursor(c, database.Cursor{Key: k, ID: id, Before: true})
+		prev = &token
+	}
+	return next, prev
+}
+
+// wantsKeysetPage reports whether the client opted into cursor pagination on
+// a listing that returns a bare array by default.
+func (app *application) wantsKeysetPage(c *gin.Context) bool {
+	return c.Query("cursor") != "" || c.Query("limit") != ""
+}
+
+// parseKeysetParams reads limit and cursor for a keyset-paginated listing,
+// writing a 400 response and returning ok=false if the cursor is invalid.
+func (app *application) parseKeysetParams(c *gin.Context) (limit int, cursor *database.Cursor, ok bool) {
+	_, limit = parsePagination(c)
+	if token := c.Query("cursor"); token != "" {
+		var err error
+		if cursor, err = app.decodeCursor(c, token); err != nil {
+			respondCursorError(c, err)
+			return 0, nil, false
+		}
+	}
+	return limit, cursor, true
+}
+
+// respondCursorError writes the 400 response for a cursor rejected by
+// decodeCursor.
+func respondCursorError(c *gin.Context, err error) {
+	if err == errCursorScope {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor belongs to another listing or filter set"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+}
+
+// respondKeysetPage writes one page of rows fetched with limit+1 from cursor
+// together with its next/prev cursor tokens.
+func respondKeysetPage[T any](app *application, c *gin.Context, rows []T, limit int, cursor *database.Cursor, keyOf func(T) (string, int)) {
+	rows, hasNext, hasPrev := keysetPage(rows, limit, cursor)
+	if rows == nil {
+		rows = []T{}
+	}
+	next, prev := pageCursors(app, c, rows, hasNext, hasPrev, keyOf)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rows,
+		"pagination": gin.H{
+			"limit":       limit,
+			"next_cursor": next,
+			"prev_cursor": prev,
+		},
+	})
+}
+
+func eventCursorKey(ev *database.Event) (string, int) {
+	return ev.StartTime, ev.ID
+}
+
+func nearbyCursorKey(r *database.EventNearbyResult) (string, int) {
+	return r.SortKey(), r.ID
+}
+
+func userEventCursorKey(ev *database.UserEvent) (string, int) {
+	return ev.StartTime, ev.ID
+}
+
+func attendeeCursorKey(a *database.EventAttendee) (string, int) {
+	return "", a.ID
+}
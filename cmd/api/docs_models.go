@@ -11,3 +11,4 @@ import "rest-api-in-gin/internal/database"
 type EventDoc = database.Event
 type UserDoc = database.User
 type AttendeeDoc = database.Attendee
+type AuditEntryDoc = database.AuditEntry
@@ -6,12 +6,13 @@ import (
 	"rest-api-in-gin/internal/database"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // @Summary Create an event
-// @Description Create a new event for the authenticated user
+// @Description Create a new event for the authenticated user, published straight away or as a draft only the owner sees. With a recurrence rule (RRULE subset: FREQ=DAILY, WEEKLY or MONTHLY, INTERVAL, BYDAY, COUNT, UNTIL) a recurring event is created and its first occurrence returned; start_time and end_time are those of the first occurrence.
 // @Tags Events
 // @Accept json
 // @Produce json
@@ -19,6 +20,7 @@ import (
 // @Success 201 {object} main.EventDoc
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/v1/events [post]
@@ -28,8 +30,18 @@ func (app *application) createEvent(c *gin.Context) {
 	if err := c.ShouldBindJSON(&event); err != nil {
 		// Log detailed validation/binding error for server-side debugging
 		log.Printf("createEvent: bind error: %v", err)
-		// Return a generic message to the client (avoid leaking internal details)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if fields := normalizeEventTimes(&event); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+	if fields := normalizeTags(&event); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+	if !app.checkEventVenue(c, &event) || !app.checkEventCategory(c, &event) {
 		return
 	}
 
@@ -39,12 +51,26 @@ func (app *application) createEvent(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
+	if app.requireVerifiedEmail && !user.EmailVerified() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email verification required"})
+		return
+	}
 	event.User_id = user.ID
 
+	if event.Recurrence != "" {
+		app.createSeries(c, &event)
+		return
+	}
+
 	err = app.models.Events.Insert(&event)
 	if err != nil {
 		log.Printf("createEvent: db insert error: %v", err)
 
+		if err == database.ErrVenueBooked {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue is already booked at that time"})
+			return
+		}
+
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") || strings.Contains(err.Error(), "constraint failed") {
 			c.JSON(http.StatusConflict, gin.H{"error": "Conflict: constraint violation"})
 			return
@@ -63,39 +89,83 @@ func (app *application) createEvent(c *gin.Context) {
 }
 
 // @Summary Get all events
-// @Description Retrieve a list of all events with pagination and filtering
+// @Description Retrieve a list of events with pagination and filtering. Anonymous callers see public events; signed-in users also see unlisted and private events they own, attend or are invited to. The response includes facets: the number of events matching the filter in each category and with each tag.
 // @Tags Events
 // @Accept json
 // @Produce json
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Items per page (default: 10, max: 100)"
 // @Param search query string false "Search in event name and description"
+// @Param start_date query string false "Only events starting on or after this date (YYYY-MM-DD or RFC 3339)"
+// @Param end_date query string false "Only events starting on or before this date (YYYY-MM-DD or RFC 3339)"
+// @Param user_id query int false "Only events owned by this user"
+// @Param series_id query int false "Only occurrences of this recurring event"
+// @Param category query string false "Only events in one of these comma-separated category slugs"
+// @Param tags query string false "Only events with these comma-separated tags"
+// @Param tags_match query string false "any (default) or all of tags must match"
+// @Param sort query string false "Sort field: start_time, end_time, title, created_at (default: start_time)"
+// @Param order query string false "Sort direction: asc or desc (default: asc)"
+// @Param cursor query string false "Opaque next_cursor/prev_cursor token from a previous response; replaces page"
+// @Param tz query string false "IANA time zone to render start and end times in, or event for each event's own (default: UTC)"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/events [get]
 func (app *application) getAllEvets(c *gin.Context) {
-	// Parse pagination parameters
-	page := 1
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
+	filter, page, limit, ok := app.parseEventFilter(c)
+	if !ok {
+		return
+	}
+	tz, ok := renderTimezone(c)
+	if !ok {
+		return
+	}
+
+	// Occurrences of recurring events are materialized seriesHorizon ahead;
+	// windows reaching further extend them on demand.
+	if to, err := time.Parse(time.RFC3339, filter.StartTo); err == nil && to.After(time.Now().Add(seriesHorizon)) {
+		horizon := to
+		if limit := time.Now().Add(maxSeriesHorizon); horizon.After(limit) {
+			horizon = limit
+		}
+		if err := app.models.Series.MaterializeUntil(horizon.Add(time.Second)); err != nil {
+			log.Printf("getAllEvets: materialize series: %v", err)
 		}
 	}
 
-	limit := 10
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-			if parsed > 100 {
-				parsed = 100 // Max limit
-			}
-			limit = parsed
+	if s := c.Query("sort"); s != "" {
+		if !database.ValidEventSort(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field"})
+			return
 		}
+		filter.SortBy = s
 	}
 
-	offset := (page - 1) * limit
-	search := c.Query("search")
+	switch strings.ToLower(c.Query("order")) {
+	case "", "asc":
+	case "desc":
+		filter.SortDesc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order, must be asc or desc"})
+		return
+	}
+
+	// Cursors are keyset positions on (start_time, id), so they only make
+	// sense with the default ordering.
+	defaultOrder := (filter.SortBy == "" || filter.SortBy == "start_time") && !filter.SortDesc
+	if filter.Cursor != nil && !defaultOrder {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor pagination only supports sort=start_time&order=asc"})
+		return
+	}
 
-	events, err := app.models.Events.GetAll()
+	events, total, err := app.models.Events.List(filter)
+	if err != nil {
+		log.Printf("getAllEvets: db list error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+	facets, err := app.models.Events.Facets(filter)
 	if err != nil {
+		log.Printf("getAllEvets: db facets error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
 		return
 	}
@@ -103,56 +173,290 @@ func (app *application) getAllEvets(c *gin.Context) {
 	if events == nil {
 		events = []*database.Event{}
 	}
+	events, pagination := eventListPagination(app, c, events, filter, page, limit, total, defaultOrder, eventCursorKey)
 
-	// Apply search filter
-	if search != "" {
-		filtered := make([]*database.Event, 0)
-		searchLower := strings.ToLower(search)
-		for _, event := range events {
-			if strings.Contains(strings.ToLower(event.Title), searchLower) ||
-				strings.Contains(strings.ToLower(event.Description), searchLower) {
-				filtered = append(filtered, event)
-			}
+	// Cursors hold UTC positions, so times are converted last
+	renderEventTimes(tz, events...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       events,
+		"pagination": pagination,
+		"facets":     facets,
+	})
+}
+
+// @Summary Full-text search events
+// @Description Relevance-ranked search over event titles and descriptions. Supports prefix terms (conf*) and quoted phrases ("go workshop"); all terms must match. Searches the events the caller would see in the event listing.
+// @Tags Events
+// @Produce json
+// @Param q query string true "Search query"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param tz query string false "IANA time zone to render start and end times in, or event for each event's own (default: UTC)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/events/search [get]
+func (app *application) searchEvents(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if database.BuildFTSQuery(q) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q must contain at least one search term"})
+		return
+	}
+
+	page, limit := parsePagination(c)
+	tz, ok := renderTimezone(c)
+	if !ok {
+		return
+	}
+
+	results, total, err := app.models.Events.Search(q, app.viewer(c), limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("searchEvents: db search error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search events"})
+		return
+	}
+	if results == nil {
+		results = []*database.EventSearchResult{}
+	}
+	for _, r := range results {
+		renderEventTimes(tz, &r.Event)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":      q,
+		"data":       results,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+const (
+	// defaultNearbyRadiusKm is the radius of nearby searches that give none.
+	defaultNearbyRadiusKm = 10.0
+
+	// maxNearbyRadiusKm bounds the radius of nearby searches.
+	maxNearbyRadiusKm = 500.0
+)
+
+// @Summary Events near a location
+// @Description List the events whose venue lies within radius_km of a point, nearest first, with the distance to each. Combines with the filters and pagination of the event listing.
+// @Tags Events
+// @Produce json
+// @Param lat query number true "Latitude of the point (-90 to 90)"
+// @Param lng query number true "Longitude of the point (-180 to 180)"
+// @Param radius_km query number false "Search radius in kilometres (default: 10, max: 500)"
+// @Param search query string false "Search in event name and description"
+// @Param start_date query string false "Only events starting on or after this date (YYYY-MM-DD or RFC 3339)"
+// @Param end_date query string false "Only events starting on or before this date (YYYY-MM-DD or RFC 3339)"
+// @Param user_id query int false "Only events owned by this user"
+// @Param series_id query int false "Only occurrences of this recurring event"
+// @Param category query string false "Only events in one of these comma-separated category slugs"
+// @Param tags query string false "Only events with these comma-separated tags"
+// @Param tags_match query string false "any (default) or all of tags must match"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param cursor query string false "Opaque next_cursor/prev_cursor token from a previous response; replaces page"
+// @Param tz query string false "IANA time zone to render start and end times in, or event for each event's own (default: UTC)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/events/nearby [get]
+func (app *application) nearbyEvents(c *gin.Context) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lat, must be between -90 and 90"})
+		return
+	}
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lng, must be between -180 and 180"})
+		return
+	}
+	radius := defaultNearbyRadiusKm
+	if r := c.Query("radius_km"); r != "" {
+		radius, err = strconv.ParseFloat(r, 64)
+		if err != nil || !(radius > 0 && radius <= maxNearbyRadiusKm) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius_km, must be greater than 0 and at most 500"})
+			return
 		}
-		events = filtered
 	}
 
-	total := len(events)
+	filter, page, limit, ok := app.parseEventFilter(c)
+	if !ok {
+		return
+	}
+	tz, ok := renderTimezone(c)
+	if !ok {
+		return
+	}
 
-	// Apply pagination
-	if offset >= total {
-		events = []*database.Event{}
-	} else {
-		end := offset + limit
-		if end > total {
-			end = total
+	results, total, err := app.models.Events.Nearby(filter, lat, lng, radius)
+	if err != nil {
+		log.Printf("nearbyEvents: db nearby error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+	if results == nil {
+		results = []*database.EventNearbyResult{}
+	}
+	results, pagination := eventListPagination(app, c, results, filter, page, limit, total, true, nearbyCursorKey)
+
+	// Cursors hold UTC positions, so times are converted last
+	for _, r := range results {
+		renderEventTimes(tz, &r.Event)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       results,
+		"pagination": pagination,
+	})
+}
+
+// parseEventFilter reads the filters and pagination shared by the event
+// listings: search, date range, owner, series, categories and tags, page
+// and limit, and cursor. It writes a 400 response and returns ok=false if
+// one is invalid. With a cursor, filter.Limit is one more than limit so the
+// listing can tell whether another page follows.
+func (app *application) parseEventFilter(c *gin.Context) (filter database.EventFilter, page, limit int, ok bool) {
+	page, limit = parsePagination(c)
+	filter = database.EventFilter{
+		Viewer: app.viewer(c),
+		Search: c.Query("search"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if filter.StartFrom, ok = parseDateParam(c.Query("start_date"), false); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date"})
+		return filter, 0, 0, false
+	}
+	if filter.StartTo, ok = parseDateParam(c.Query("end_date"), true); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date"})
+		return filter, 0, 0, false
+	}
+
+	if o := c.Query("user_id"); o != "" {
+		ownerID, err := strconv.Atoi(o)
+		if err != nil || ownerID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return filter, 0, 0, false
+		}
+		filter.OwnerID = ownerID
+	}
+
+	if s := c.Query("series_id"); s != "" {
+		seriesID, err := strconv.Atoi(s)
+		if err != nil || seriesID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series_id"})
+			return filter, 0, 0, false
+		}
+		filter.SeriesID = seriesID
+	}
+
+	if !parseTopicFilter(c, &filter) {
+		return filter, 0, 0, false
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := app.decodeCursor(c, token)
+		if err != nil {
+			respondCursorError(c, err)
+			return filter, 0, 0, false
+		}
+		filter.Cursor = cursor
+		filter.Limit = limit + 1
+	}
+	return filter, page, limit, true
+}
+
+// eventListPagination trims rows listed with filter to one page and builds
+// its pagination object: keyset-based if filter has a cursor, page-based
+// otherwise. Page-based listings also carry cursors when withCursors is
+// set, so clients can switch to them from any page.
+func eventListPagination[T any](app *application, c *gin.Context, rows []T, filter database.EventFilter, page, limit, total int, withCursors bool, keyOf func(T) (string, int)) ([]T, gin.H) {
+	if filter.Cursor != nil {
+		rows, hasNext, hasPrev := keysetPage(rows, limit, filter.Cursor)
+		next, prev := pageCursors(app, c, rows, hasNext, hasPrev, keyOf)
+		return rows, gin.H{
+			"limit":       limit,
+			"total":       total,
+			"next_cursor": next,
+			"prev_cursor": prev,
+		}
+	}
+
+	pagination := paginationMeta(page, limit, total)
+	if withCursors {
+		hasNext := filter.Offset+len(rows) < total
+		hasPrev := filter.Offset > 0
+		pagination["next_cursor"], pagination["prev_cursor"] = pageCursors(app, c, rows, hasNext, hasPrev, keyOf)
+	}
+	return rows, pagination
+}
+
+// parsePagination reads the page and limit query parameters, falling back to
+// page 1 and 10 items and capping limit at 100.
+func parsePagination(c *gin.Context) (page, limit int) {
+	page = 1
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	limit = 10
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			if parsed > 100 {
+				parsed = 100 // Max limit
+			}
+			limit = parsed
 		}
-		events = events[offset:end]
 	}
 
-	// Calculate pagination metadata
+	return page, limit
+}
+
+// paginationMeta builds the pagination object returned by list endpoints.
+func paginationMeta(page, limit, total int) gin.H {
 	totalPages := (total + limit - 1) / limit
 	if totalPages == 0 {
 		totalPages = 1
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": events,
-		"pagination": gin.H{
-			"page":        page,
-			"limit":       limit,
-			"total":       total,
-			"total_pages": totalPages,
-		},
-	})
+	return gin.H{
+		"page":        page,
+		"limit":       limit,
+		"total":       total,
+		"total_pages": totalPages,
+	}
+}
+
+// parseDateParam validates a date query parameter given either as YYYY-MM-DD
+// or RFC 3339 and returns it in the RFC 3339 form events are stored in. A bare
+// date used as an upper bound is extended to the end of that day.
+func parseDateParam(value string, endOfDay bool) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format(time.RFC3339), true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", false
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t.Format(time.RFC3339), true
 }
 
 // @Summary Get a single event
-// @Description Retrieve details for a single event by ID
+// @Description Retrieve details for a single event by ID. Private events are only shown to their owner, admins, attendees and invitees.
 // @Tags Events
 // @Accept json
 // @Produce json
 // @Param id path int true "Event ID"
+// @Param tz query string false "IANA time zone to render start and end times in, or event for the event's own (default: UTC)"
 // @Success 200 {object} main.EventDoc
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -161,6 +465,7 @@ func (app *application) getEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
 	}
 
 	event, err := app.models.Events.Get(id)
@@ -172,28 +477,39 @@ func (app *application) getEvent(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
+	if !app.requireEventVisible(c, event) {
+		return
+	}
+	tz, ok := renderTimezone(c)
+	if !ok {
+		return
+	}
+	renderEventTimes(tz, event)
 
 	c.JSON(http.StatusOK, event)
 }
 
 // @Summary Update an event
-// @Description Update an existing event (owner only)
+// @Description Update an existing event (owner, co-organizer or admin). Ownership changes through the transfer endpoint. Cancelled and completed events cannot be changed, and the status is changed through its own endpoint. Capacity cannot drop below the current number of attendees. An omitted visibility is left unchanged. For an occurrence of a recurring event, scope=following or scope=all applies the change to later or all occurrences; their start time moves by the same amount and may only change the time of day.
 // @Tags Events
 // @Accept json
 // @Produce json
 // @Param id path int true "Event ID"
+// @Param scope query string false "this (default), following or all"
 // @Param event body main.EventDoc true "Updated event payload"
 // @Success 200 {object} main.EventDoc
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/v1/events/{id} [put]
 func (app *application) updateEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
 	}
 
 	// Fetch existing
@@ -206,36 +522,87 @@ func (app *application) updateEvent(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
-	// Ensure the requesting user is the owner
-	user, err := app.getUserFromContext(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	if !app.requireEventPermission(c, existing, permEditEvent) {
 		return
 	}
-	if existing.User_id != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to update this event"})
+	if existing.Status == database.EventStatusCancelled || existing.Status == database.EventStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "A " + existing.Status + " event cannot be changed"})
+		return
+	}
+	scope, ok := seriesScope(c)
+	if !ok {
+		return
+	}
+	if scope != database.SeriesScopeThis && existing.SeriesID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only occurrences of recurring events can be updated with scope following or all"})
 		return
 	}
 
 	var updated database.Event
 	if err := c.ShouldBindJSON(&updated); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if updated.Timezone == "" {
+		updated.Timezone = existing.Timezone
+	}
+	if fields := normalizeEventTimes(&updated); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+	if updated.Tags == nil {
+		updated.Tags = existing.Tags
+	}
+	if fields := normalizeTags(&updated); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+	if !app.checkEventVenue(c, &updated) || !app.checkEventCategory(c, &updated) {
 		return
 	}
 
 	updated.ID = id
+	updated.User_id = existing.User_id
+	updated.Status = existing.Status
+	if updated.Visibility == "" {
+		updated.Visibility = existing.Visibility
+	}
+	if updated.Recurrence != "" && updated.Recurrence != existing.Recurrence {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The recurrence of an event cannot be changed"})
+		return
+	}
+	if scope != database.SeriesScopeThis {
+		app.updateOccurrences(c, existing, &updated, scope)
+		return
+	}
 	if err := app.models.Events.Update(&updated); err != nil {
+		if err == database.ErrEventNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
+		if err == database.ErrCapacityBelowAttendance {
+			c.JSON(http.StatusConflict, gin.H{"error": "Capacity is below the current number of attendees"})
+			return
+		}
+		if err == database.ErrVenueBooked {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue is already booked at that time"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
 		return
 	}
+	// A larger capacity may free seats for the waitlist
+	app.promoteWaitlist(&updated)
 
+	updated.Recurrence, updated.SeriesID, updated.OccurrenceStart = existing.Recurrence, existing.SeriesID, existing.OccurrenceStart
 	c.JSON(http.StatusOK, updated)
 }
 
 // @Summary Delete an event
-// @Description Delete an event by ID (owner only)
+// @Description Delete an event by ID (owner or admin). Deleted events can be restored until the retention period ends, after which they are purged. For an occurrence of a recurring event, scope=following or scope=all also deletes the later or all occurrences and ends the series.
 // @Tags Events
 // @Param id path int true "Event ID"
+// @Param scope query string false "this (default), following or all"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -246,6 +613,7 @@ func (app *application) deleteEvent(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
 	}
 
 	// Fetch existing
@@ -259,7 +627,27 @@ func (app *application) deleteEvent(c *gin.Context) {
 		return
 	}
 
-	// Check if the user is the owner of the event
+	if !app.requireEventPermission(c, existing, permOwnEvent) {
+		return
+	}
+
+	scope, ok := seriesScope(c)
+	if !ok {
+		return
+	}
+	if scope != database.SeriesScopeThis {
+		if existing.SeriesID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only occurrences of recurring events can be deleted with scope following or all"})
+			return
+		}
+		if err := app.models.Series.CancelOccurrences(existing, scope); err != nil {
+			log.Printf("deleteEvent: db cancel series error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Events deleted successfully"})
+		return
+	}
 
 	err = app.models.Events.Delete(id)
 	if err != nil {
@@ -270,15 +658,85 @@ func (app *application) deleteEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
 }
 
+// @Summary Restore a deleted event
+// @Description Undo the deletion of an event that has not been purged yet (owner or admin). Occurrences of a recurring event are restored one at a time.
+// @Tags Events
+// @Produce json
+// @Param id path int true "Event ID"
+// @Success 200 {object} main.EventDoc
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/restore [post]
+func (app *application) restoreEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	ev, err := app.models.Events.GetDeleted(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	if ev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted event not found"})
+		return
+	}
+	if !app.requireEventPermission(c, ev, permOwnEvent) {
+		return
+	}
+	owner, err := app.models.Users.Get(ev.User_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if owner == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The event's owner has been deleted; restore the account first"})
+		return
+	}
+
+	if err := app.models.Events.Restore(ev); err != nil {
+		if err == database.ErrEventNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deleted event not found"})
+			return
+		}
+		if err == database.ErrVenueBooked {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue has been booked for this time in the meantime"})
+			return
+		}
+		log.Printf("restoreEvent: db restore error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore event"})
+		return
+	}
+
+	restored, err := app.models.Events.Get(id)
+	if err != nil || restored == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	c.JSON(http.StatusOK, restored)
+}
+
 // @Summary Get attendees for an event
-// @Description List users attending a specific event
+// @Description List users attending a specific event with their RSVP status (event team except viewers, or admin). Waitlisted users are only listed with status=waitlisted.
 // @Tags Attendees
 // @Accept json
 // @Produce json
 // @Param id path int true "Event ID"
-// @Success 200 {array} main.UserDoc
+// @Param status query string false "Only attendees with this status: pending, confirmed, declined, rejected, waitlisted, offered"
+// @Param limit query int false "Page size (max 100); switches the response to a cursor-paginated envelope"
+// @Param cursor query string false "Opaque next_cursor/prev_cursor token from a previous response"
+// @Success 200 {array} database.EventAttendee
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Security BearerAuth
 // @Router /api/v1/events/{id}/attendees [get]
 func (app *application) getEventAttendees(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -287,21 +745,54 @@ func (app *application) getEventAttendees(c *gin.Context) {
 		return
 	}
 
-	users, err := app.models.Attendees.GetEventAttendees(id)
+	ev, err := app.models.Events.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	if ev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if !app.requireEventVisible(c, ev) || !app.requireEventPermission(c, ev, permViewAttendees) {
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && !database.ValidAttendeeStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	if app.wantsKeysetPage(c) {
+		limit, cursor, ok := app.parseKeysetParams(c)
+		if !ok {
+			return
+		}
+		attendees, err := app.models.Attendees.GetEventAttendeesPage(id, status, cursor, limit+1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendees"})
+			return
+		}
+		respondKeysetPage(app, c, attendees, limit, cursor, attendeeCursorKey)
+		return
+	}
+
+	attendees, err := app.models.Attendees.GetEventAttendees(id, status)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendees"})
 		return
 	}
 
-	if users == nil {
-		users = []*database.User{}
+	if attendees == nil {
+		attendees = []*database.EventAttendee{}
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, attendees)
 }
 
 // @Summary Remove an attendee from an event
-// @Description Remove a user from an event's attendee list (self or owner/admin)
+// @Description Remove a user from an event's attendee list or waitlist (self, owner, co-organizer or admin). A freed seat goes to the next person on the waitlist.
 // @Tags Attendees
 // @Param id path int true "Event ID"
 // @Param userId path int true "User ID"
@@ -336,16 +827,14 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 		return
 	}
 
-	// Allow self-removal or owner/admin
+	// Allow self-removal or organizers
 	tokenUser, err := app.getUserFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	if tokenUser.ID != userID {
-		if !requireOwnerOrAdmin(app, c, ev.User_id) {
-			return
-		}
+	if tokenUser.ID != userID && !app.requireEventPermission(c, ev, permManageAttendees) {
+		return
 	}
 
 	deleted, err := app.models.Attendees.Delete(eventID, userID)
@@ -357,15 +846,18 @@ func (app *application) deleteAttendeeFromEvent(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Attendee not found"})
 		return
 	}
+	app.promoteWaitlist(ev)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Attendee removed"})
 }
 
 // @Summary Get events for a user
-// @Description Retrieve events a user is attending
+// @Description Retrieve events a user is attending or waitlisted for, with their status and waitlist position. Only events listed to the requester are included, so unlisted, private and draft events show up only for those with access to them.
 // @Tags Attendees
 // @Param id path int true "User ID"
-// @Success 200 {array} main.EventDoc
+// @Param limit query int false "Page size (max 100); switches the response to a cursor-paginated envelope"
+// @Param cursor query string false "Opaque next_cursor/prev_cursor token from a previous response"
+// @Success 200 {array} database.UserEvent
 // @Failure 400 {object} map[string]string
 // @Router /api/v1/attendees/{id}/events [get]
 func (app *application) getUserEvents(c *gin.Context) {
@@ -375,22 +867,37 @@ func (app *application) getUserEvents(c *gin.Context) {
 		return
 	}
 
-	events, err := app.models.Attendees.GetEventsForUser(userID)
+	if app.wantsKeysetPage(c) {
+		limit, cursor, ok := app.parseKeysetParams(c)
+		if !ok {
+			return
+		}
+		events, err := app.models.Attendees.GetEventsForUserPage(userID, app.viewer(c), cursor, limit+1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events for user"})
+			return
+		}
+		respondKeysetPage(app, c, events, limit, cursor, userEventCursorKey)
+		return
+	}
+
+	events, err := app.models.Attendees.GetEventsForUser(userID, app.viewer(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events for user"})
 		return
 	}
 	if events == nil {
-		events = []*database.Event{}
+		events = []*database.UserEvent{}
 	}
 	c.JSON(http.StatusOK, events)
 }
 
 // @Summary Add attendee to event
-// @Description Add a user as attendee to an event (self or owner/admin)
+// @Description Add a user as attendee to an event (self, or owner, co-organizer or admin). Private events can only be joined by invitees, and only published events can be joined. Once the event reaches its capacity users join its waitlist, or get 409 "event full" with waitlist=false.
 // @Tags Attendees
 // @Param id path int true "Event ID"
 // @Param user_id query int true "User ID"
+// @Param waitlist query bool false "Join the waitlist if the event is full (default: true)"
 // @Success 201 {object} main.AttendeeDoc
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -422,16 +929,17 @@ func (app *application) addEventAttendee(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
+	if !app.requireEventVisible(c, ev) {
+		return
+	}
 
 	tokenUser, err := app.getUserFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	if tokenUser.ID != userId {
-		if !requireOwnerOrAdmin(app, c, ev.User_id) {
-			return
-		}
+	if tokenUser.ID != userId && !app.requireEventPermission(c, ev, permManageAttendees) {
+		return
 	}
 	userToAdd, err := app.models.Users.Get(userId)
 	if err != nil {
@@ -457,14 +965,43 @@ func (app *application) addEventAttendee(c *gin.Context) {
 		EventID: eventID,
 		UserID:  userId,
 	}
-	id, err := app.models.Attendees.Insert(attendee)
+	insert := app.models.Attendees.Join
+	if c.Query("waitlist") == "false" {
+		insert = app.models.Attendees.Insert
+	}
+	id, err := insert(attendee)
 	if err != nil {
+		if err == database.ErrEventFull {
+			c.JSON(http.StatusConflict, gin.H{"error": "event full", "message": "This event has reached its capacity"})
+			return
+		}
+		if err == database.ErrEventClosed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is not open for registration"})
+			return
+		}
+		if err == database.ErrAlreadyAttending {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already an attendee of this event"})
+			return
+		}
+		if err == database.ErrEventNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+			return
+		}
 		log.Printf("addEventAttendee: db insert error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attendee"})
 		return
 	}
 	attendee.ID = id
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Attendee added successfully", "attendee": userToAdd})
+	if attendee.Status == database.AttendeeStatusWaitlisted {
+		c.JSON(http.StatusCreated, gin.H{
+			"message":           "Event is full; added to the waitlist",
+			"attendee":          userToAdd,
+			"status":            attendee.Status,
+			"waitlist_position": attendee.WaitlistPosition,
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Attendee added successfully", "attendee": userToAdd, "status": attendee.Status})
 
 }
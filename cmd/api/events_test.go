@@ -0,0 +1,478 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/rrule"
+)
+
+func TestListEventsFiltering(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u1 := &database.User{Email: "a@example.com", Name: "A", Password: "x"}
+	u2 := &database.User{Email: "b@example.com", Name: "B", Password: "x"}
+	for _, u := range []*database.User{u1, u2} {
+		if err := app.models.Users.Insert(u); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	seed := []database.Event{
+		{User_id: u1.ID, Title: "Go Workshop", Description: "Learn Go basics", StartTime: "2025-01-10T10:00:00Z", EndTime: "2025-01-10T12:00:00Z"},
+		{User_id: u1.ID, Title: "Rust Meetup", Description: "Monthly rust meetup", StartTime: "2025-02-10T10:00:00Z", EndTime: "2025-02-10T12:00:00Z"},
+		{User_id: u2.ID, Title: "Advanced Go", Description: "Concurrency 100% explained", StartTime: "2025-03-10T10:00:00Z", EndTime: "2025-03-10T12:00:00Z"},
+		{User_id: u2.ID, Title: "Design Review", Description: "Architecture session", StartTime: "2025-04-10T10:00:00Z", EndTime: "2025-04-10T12:00:00Z"},
+	}
+	for i := range seed {
+		if err := app.models.Events.Insert(&seed[i]); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	type listResponse struct {
+		Data       []database.Event `json:"data"`
+		Pagination struct {
+			Page       int `json:"page"`
+			Limit      int `json:"limit"`
+			Total      int `json:"total"`
+			TotalPages int `json:"total_pages"`
+		} `json:"pagination"`
+	}
+
+	get := func(query string, wantStatus int) listResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/v1/events" + query)
+		if err != nil {
+			t.Fatalf("GET %s: %v", query, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("GET %s: expected %d, got %d", query, wantStatus, resp.StatusCode)
+		}
+		var out listResponse
+		if wantStatus == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode %s: %v", query, err)
+			}
+		}
+		return out
+	}
+
+	out := get("?search=go", http.StatusOK)
+	if out.Pagination.Total != 2 || len(out.Data) != 2 || out.Data[0].Title != "Go Workshop" {
+		t.Fatalf("search=go: unexpected result %+v", out)
+	}
+
+	// LIKE wildcards in the search term must match literally
+	out = get("?search=100%25", http.StatusOK)
+	if out.Pagination.Total != 1 || out.Data[0].Title != "Advanced Go" {
+		t.Fatalf("search=100%%: unexpected result %+v", out)
+	}
+
+	out = get("?start_date=2025-02-01&end_date=2025-03-10", http.StatusOK)
+	if out.Pagination.Total != 2 {
+		t.Fatalf("date range: expected 2 events, got %+v", out)
+	}
+
+	out = get("?user_id=2&sort=title&order=desc", http.StatusOK)
+	if out.Pagination.Total != 2 || out.Data[0].Title != "Design Review" {
+		t.Fatalf("owner+sort: unexpected result %+v", out)
+	}
+
+	out = get("?limit=3&page=2", http.StatusOK)
+	if out.Pagination.Total != 4 || out.Pagination.TotalPages != 2 || len(out.Data) != 1 || out.Data[0].Title != "Design Review" {
+		t.Fatalf("pagination: unexpected result %+v", out)
+	}
+
+	get("?sort=password", http.StatusBadRequest)
+	get("?order=sideways", http.StatusBadRequest)
+	get("?start_date=yesterday", http.StatusBadRequest)
+}
+
+func TestCursorPagination(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := &database.User{Email: "c@example.com", Name: "C", Password: "x"}
+	if err := app.models.Users.Insert(u); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	insert := func(day int) *database.Event {
+		ev := &database.Event{
+			User_id:     u.ID,
+			Title:       fmt.Sprintf("Event %02d", day),
+			Description: "Cursor pagination test",
+			StartTime:   fmt.Sprintf("2025-01-%02dT10:00:00Z", day),
+			EndTime:     fmt.Sprintf("2025-01-%02dT12:00:00Z", day),
+		}
+		if err := app.models.Events.Insert(ev); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+		return ev
+	}
+	for day := 2; day <= 10; day += 2 {
+		insert(day)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	type cursorResponse struct {
+		Data       []database.Event `json:"data"`
+		Pagination struct {
+			Total      int     `json:"total"`
+			NextCursor *string `json:"next_cursor"`
+			PrevCursor *string `json:"prev_cursor"`
+		} `json:"pagination"`
+	}
+	get := func(path string, wantStatus int) cursorResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("GET %s: expected %d, got %d", path, wantStatus, resp.StatusCode)
+		}
+		var out cursorResponse
+		if wantStatus == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return out
+	}
+	titles := func(out cursorResponse) string {
+		var s []string
+		for _, ev := range out.Data {
+			s = append(s, ev.Title)
+		}
+		return strings.Join(s, ",")
+	}
+
+	// Offset clients get a cursor to switch over
+	first := get("/api/v1/events?limit=2", http.StatusOK)
+	if first.Pagination.NextCursor == nil || first.Pagination.PrevCursor != nil {
+		t.Fatalf("first page: unexpected cursors %+v", first.Pagination)
+	}
+
+	// An event inserted before the cursor position must not shift the next page
+	insert(1)
+	second := get("/api/v1/events?limit=2&cursor="+url.QueryEscape(*first.Pagination.NextCursor), http.StatusOK)
+	if got := titles(second); got != "Event 06,Event 08" {
+		t.Fatalf("second page: got %s", got)
+	}
+	third := get("/api/v1/events?limit=2&cursor="+url.QueryEscape(*second.Pagination.NextCursor), http.StatusOK)
+	if got := titles(third); got != "Event 10" || third.Pagination.NextCursor != nil {
+		t.Fatalf("third page: got %s next=%v", got, third.Pagination.NextCursor)
+	}
+
+	back := get("/api/v1/events?limit=2&cursor="+url.QueryEscape(*third.Pagination.PrevCursor), http.StatusOK)
+	if got := titles(back); got != "Event 06,Event 08" || back.Pagination.NextCursor == nil {
+		t.Fatalf("previous page: got %s", got)
+	}
+	back = get("/api/v1/events?limit=2&cursor="+url.QueryEscape(*back.Pagination.PrevCursor), http.StatusOK)
+	if got := titles(back); got != "Event 02,Event 04" || back.Pagination.PrevCursor == nil {
+		t.Fatalf("previous page 2: got %s", got)
+	}
+	back = get("/api/v1/events?limit=2&cursor="+url.QueryEscape(*back.Pagination.PrevCursor), http.StatusOK)
+	if got := titles(back); got != "Event 01" || back.Pagination.PrevCursor != nil {
+		t.Fatalf("previous page 3: got %s", got)
+	}
+
+	// Tampered cursors and cursors with a custom sort are rejected
+	tampered := strings.Replace(*first.Pagination.NextCursor, ".", "x.", 1)
+	get("/api/v1/events?cursor="+url.QueryEscape(tampered), http.StatusBadRequest)
+	get("/api/v1/events?sort=title&cursor="+url.QueryEscape(*first.Pagination.NextCursor), http.StatusBadRequest)
+
+	// Cursors only continue the listing and filters they were issued for
+	get("/api/v1/events?search=Event&cursor="+url.QueryEscape(*first.Pagination.NextCursor), http.StatusBadRequest)
+	get("/api/v1/events/nearby?lat=0&lng=0&cursor="+url.QueryEscape(*first.Pagination.NextCursor), http.StatusBadRequest)
+	filtered := get("/api/v1/events?search=Event&limit=2", http.StatusOK)
+	get("/api/v1/events?search=Event&limit=3&tz=&cursor="+url.QueryEscape(*filtered.Pagination.NextCursor), http.StatusOK)
+	get("/api/v1/events?cursor="+url.QueryEscape(*filtered.Pagination.NextCursor), http.StatusBadRequest)
+
+	// Attendee listings stay bare arrays unless a page is requested
+	for _, email := range []string{"a1@example.com", "a2@example.com", "a3@example.com"} {
+		att := &database.User{Email: email, Name: "Att", Password: "x"}
+		if err := app.models.Users.Insert(att); err != nil {
+			t.Fatalf("insert attendee user: %v", err)
+		}
+		if _, err := app.models.Attendees.Insert(&database.Attendee{EventID: 1, UserID: att.ID}); err != nil {
+			t.Fatalf("insert attendee: %v", err)
+		}
+	}
+	token, _ := jwtForUser(app, u.ID)
+	authGet := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		return resp
+	}
+
+	resp := authGet("/api/v1/events/1/attendees?limit=2")
+	var page struct {
+		Data       []database.User `json:"data"`
+		Pagination struct {
+			NextCursor *string `json:"next_cursor"`
+		} `json:"pagination"`
+	}
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if len(page.Data) != 2 || page.Pagination.NextCursor == nil {
+		t.Fatalf("attendee page 1: unexpected %+v", page)
+	}
+	resp = authGet("/api/v1/events/1/attendees?limit=2&cursor=" + url.QueryEscape(*page.Pagination.NextCursor))
+	page.Pagination.NextCursor = nil
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if len(page.Data) != 1 || page.Pagination.NextCursor != nil {
+		t.Fatalf("attendee page 2: unexpected %+v", page)
+	}
+
+	resp = authGet("/api/v1/events/1/attendees")
+	var all []database.User
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil || len(all) != 3 {
+		t.Fatalf("legacy attendee listing: err=%v users=%+v", err, all)
+	}
+	resp.Body.Close()
+}
+
+func TestInvalidEventID(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+
+	// The handler stops after the 400, leaving a single JSON error
+	update := map[string]interface{}{"title": "Workshop", "description": "Hands-on workshop", "start_time": "2030-01-01T10:00:00Z", "end_time": "2030-01-01T12:00:00Z"}
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		code, body := api.do(method, "/api/v1/events/abc", ownerToken, update)
+		var res map[string]string
+		if code != http.StatusBadRequest || json.Unmarshal(body, &res) != nil || res["error"] != "Invalid event ID" {
+			t.Fatalf("%s invalid ID: %d %s", method, code, body)
+		}
+	}
+}
+
+func TestEventCapacity(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+
+	event := map[string]interface{}{"title": "Workshop", "description": "Hands-on workshop", "start_time": "2030-01-01T10:00:00Z", "end_time": "2030-01-01T12:00:00Z", "capacity": 0}
+	if code, body := api.do("POST", "/api/v1/events", ownerToken, event); code != http.StatusBadRequest {
+		t.Fatalf("zero capacity: %d %s", code, body)
+	}
+	event["capacity"] = 3
+	code, body := api.do("POST", "/api/v1/events", ownerToken, event)
+	var ev database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil || ev.Capacity == nil || *ev.Capacity != 3 {
+		t.Fatalf("create: %d %s", code, body)
+	}
+	attendees := fmt.Sprintf("/api/v1/events/%d/attendees", ev.ID)
+
+	// Fill all but the last seat
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		u := insertUserWithPassword(t, app, email, "password123")
+		token, _ := jwtForUser(app, u.ID)
+		if code, body := api.do("POST", fmt.Sprintf("%s?user_id=%d", attendees, u.ID), token, nil); code != http.StatusCreated {
+			t.Fatalf("rsvp %s: %d %s", email, code, body)
+		}
+	}
+
+	event["capacity"] = 1
+	if code, body := api.do("PUT", fmt.Sprintf("/api/v1/events/%d", ev.ID), ownerToken, event); code != http.StatusConflict {
+		t.Fatalf("capacity below attendance: %d %s", code, body)
+	}
+
+	// Many users race for the last seat; exactly one may get it
+	const racers = 20
+	tokens := make([]string, racers)
+	paths := make([]string, racers)
+	for i := range tokens {
+		u := insertUserWithPassword(t, app, fmt.Sprintf("racer%d@example.com", i), "password123")
+		tokens[i], _ = jwtForUser(app, u.ID)
+		paths[i] = fmt.Sprintf("%s?user_id=%d&waitlist=false", attendees, u.ID)
+	}
+	codes := make(chan int, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			code, _ := api.do("POST", paths[i], tokens[i], nil)
+			codes <- code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != racers-1 {
+		t.Fatalf("expected 1 created and %d conflicts, got %v", racers-1, counts)
+	}
+
+	list, err := app.models.Attendees.GetEventAttendees(ev.ID, "")
+	if err != nil || len(list) != 3 {
+		t.Fatalf("expected 3 attendees, got %d (%v)", len(list), err)
+	}
+
+	// A user holds one RSVP per event, however their joins interleave
+	delete(event, "capacity")
+	code, body = api.do("POST", "/api/v1/events", ownerToken, event)
+	var open database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &open) != nil {
+		t.Fatalf("create: %d %s", code, body)
+	}
+	codes = make(chan int, racers)
+	start = make(chan struct{})
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", open.ID, owner.ID), ownerToken, nil)
+			codes <- code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+	counts = map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != racers-1 {
+		t.Fatalf("expected 1 created and %d conflicts, got %v", racers-1, counts)
+	}
+	if _, err := app.models.Attendees.Insert(&database.Attendee{EventID: open.ID, UserID: owner.ID}); err != database.ErrAlreadyAttending {
+		t.Fatalf("second RSVP: expected ErrAlreadyAttending, got %v", err)
+	}
+}
+
+func TestEventTimesAndTimezones(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	token, _ := jwtForUser(app, owner.ID)
+
+	create := func(event map[string]interface{}) (int, map[string]interface{}) {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/events", token, event)
+		var resp map[string]interface{}
+		json.Unmarshal(body, &resp)
+		return code, resp
+	}
+	fields := func(resp map[string]interface{}) map[string]interface{} {
+		f, _ := resp["fields"].(map[string]interface{})
+		return f
+	}
+
+	code, resp := create(map[string]interface{}{"title": "Go", "description": "short", "start_time": "tomorrow", "end_time": "2030-01-01T10:00:00Z"})
+	if code != http.StatusBadRequest || fields(resp)["title"] == nil || fields(resp)["description"] == nil {
+		t.Fatalf("binding errors: %d %v", code, resp)
+	}
+	code, resp = create(map[string]interface{}{"title": "Go night", "description": "Talks and pizza", "start_time": "tomorrow", "end_time": "2030-01-01 10:00", "timezone": "Mars/Olympus"})
+	if f := fields(resp); code != http.StatusBadRequest || f["start_time"] == nil || f["end_time"] == nil || f["timezone"] == nil {
+		t.Fatalf("time errors: %d %v", code, resp)
+	}
+	code, resp = create(map[string]interface{}{"title": "Go night", "description": "Talks and pizza", "start_time": "2030-01-01T20:00:00+01:00", "end_time": "2030-01-01T19:00:00+01:00"})
+	if code != http.StatusBadRequest || fields(resp)["end_time"] != "must be after start_time" {
+		t.Fatalf("end before start: %d %v", code, resp)
+	}
+
+	code, resp = create(map[string]interface{}{"title": "Go night", "description": "Talks and pizza", "start_time": "2030-01-01T19:00:00+01:00", "end_time": "2030-01-01T22:00:00+01:00", "timezone": "Europe/Berlin"})
+	if code != http.StatusCreated || resp["start_time"] != "2030-01-01T18:00:00Z" || resp["end_time"] != "2030-01-01T21:00:00Z" || resp["timezone"] != "Europe/Berlin" {
+		t.Fatalf("times should be stored in UTC: %d %v", code, resp)
+	}
+	id := int(resp["id"].(float64))
+
+	get := func(query string) (int, database.Event) {
+		t.Helper()
+		code, body := api.do("GET", fmt.Sprintf("/api/v1/events/%d%s", id, query), "", nil)
+		var ev database.Event
+		json.Unmarshal(body, &ev)
+		return code, ev
+	}
+	if _, ev := get(""); ev.StartTime != "2030-01-01T18:00:00Z" {
+		t.Fatalf("default rendering is UTC: %+v", ev)
+	}
+	if _, ev := get("?tz=America/New_York"); ev.StartTime != "2030-01-01T13:00:00-05:00" || ev.EndTime != "2030-01-01T16:00:00-05:00" {
+		t.Fatalf("render in requested zone: %+v", ev)
+	}
+	if _, ev := get("?tz=event"); ev.StartTime != "2030-01-01T19:00:00+01:00" {
+		t.Fatalf("render in event zone: %+v", ev)
+	}
+	if code, _ := get("?tz=Nowhere/City"); code != http.StatusBadRequest {
+		t.Fatalf("invalid tz: expected 400, got %d", code)
+	}
+
+	code, body := api.do("GET", "/api/v1/events?tz=Asia/Tokyo", "", nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"start_time":"2030-01-02T03:00:00+09:00"`) {
+		t.Fatalf("list in requested zone: %d %s", code, body)
+	}
+
+	// Updates are validated the same way; an omitted timezone is kept
+	update := map[string]interface{}{"user_id": owner.ID, "title": "Go night", "description": "Talks and pizza", "start_time": "2030-01-01T19:00:00+01:00", "end_time": "2030-01-01T18:00:00+01:00"}
+	code, body = api.do("PUT", fmt.Sprintf("/api/v1/events/%d", id), token, update)
+	if code != http.StatusBadRequest || !strings.Contains(string(body), `"end_time":"must be after start_time"`) {
+		t.Fatalf("update end before start: %d %s", code, body)
+	}
+	update["end_time"] = "2030-01-01T23:00:00+01:00"
+	code, body = api.do("PUT", fmt.Sprintf("/api/v1/events/%d", id), token, update)
+	if code != http.StatusOK || !strings.Contains(string(body), `"end_time":"2030-01-01T22:00:00Z"`) || !strings.Contains(string(body), `"timezone":"Europe/Berlin"`) {
+		t.Fatalf("update: %d %s", code, body)
+	}
+
+	// Recurring events keep their local time across daylight saving changes
+	ev := database.Event{User_id: owner.ID, Title: "Choir", Description: "Weekly rehearsal", StartTime: "2030-03-25T17:00:00Z", EndTime: "2030-03-25T19:00:00Z",
+		Timezone: "Europe/Berlin", Recurrence: "FREQ=WEEKLY;COUNT=2"}
+	rule, _ := rrule.Parse(ev.Recurrence)
+	if err := app.models.Series.Insert(&ev, rule, time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	occ, _, err := app.models.Events.List(database.EventFilter{SeriesID: *ev.SeriesID, Limit: 10})
+	if err != nil || len(occ) != 2 || occ[1].StartTime != "2030-04-01T16:00:00Z" || occ[1].EndTime != "2030-04-01T18:00:00Z" {
+		t.Fatalf("occurrence after DST change: %v %+v", err, occ)
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/mailer"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxInviteLinkLifetimeHours caps how long an invite link stays valid.
+const maxInviteLinkLifetimeHours = 24 * 365
+
+type createInvitationRequest struct {
+	UserID *int   `json:"user_id" example:"42"`
+	Email  string `json:"email" binding:"omitempty,email" example:"guest@example.com"`
+}
+
+type createInviteLinkRequest struct {
+	// MaxUses of 1 makes a single-use link; omit it for unlimited uses
+	MaxUses        *int `json:"max_uses" binding:"omitempty,min=1,max=10000" example:"1"`
+	ExpiresInHours int  `json:"expires_in_hours" binding:"omitempty,min=1,max=8760" example:"72"`
+}
+
+// inviteLinkResponse is an invite link with the signed token that redeems it.
+type inviteLinkResponse struct {
+	*database.InviteLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// inviteLinkKey derives the HMAC key used to sign invite link tokens, so
+// they cannot be forged from cursor or access token signatures.
+func (app *application) inviteLinkKey() []byte {
+	sum := sha256.Sum256([]byte("eventhub-invite-link:" + app.jwtSecret))
+	return sum[:]
+}
+
+// inviteLinkToken returns the signed token redeeming invite link id.
+func (app *application) inviteLinkToken(id int) string {
+	body := strconv.Itoa(id)
+
+	mac := hmac.New(sha256.New, app.inviteLinkKey())
+	mac.Write([]byte(body))
+
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseInviteLinkToken verifies a token produced by inviteLinkToken and
+// returns the link ID it was issued for.
+func (app *application) parseInviteLinkToken(token string) (int, bool) {
+	body, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return 0, false
+	}
+
+	mac := hmac.New(sha256.New, app.inviteLinkKey())
+	mac.Write([]byte(body))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(body)
+	return id, err == nil
+}
+
+func (app *application) newInviteLinkResponse(link *database.InviteLink) inviteLinkResponse {
+	token := app.inviteLinkToken(link.ID)
+	return inviteLinkResponse{InviteLink: link, Token: token, URL: app.appLink("/invite", token)}
+}
+
+// loadOrganizedEvent loads the event named by the :id path parameter and
+// checks that the authenticated user has permission perm on it, writing an
+// error response and returning nil otherwise.
+func (app *application) loadOrganizedEvent(c *gin.Context, perm string) *database.Event {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return nil
+	}
+
+	ev, err := app.models.Events.Get(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return nil
+	}
+	if ev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil
+	}
+	if !app.requireEventPermission(c, ev, perm) {
+		return nil
+	}
+	return ev
+}
+
+// @Summary Invite someone to an event
+// @Description Invite a user by user_id or email (event owner, co-organizer or admin). Invitees can see the event even if it is private, and accept the invitation to attend it. Invitations by email are claimed by whoever verifies that address.
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param invitation body createInvitationRequest true "Exactly one of user_id and email"
+// @Success 201 {object} database.Invitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invitations [post]
+func (app *application) createInvitation(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+
+	var req createInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.UserID == nil) == (req.Email == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide exactly one of user_id or email"})
+		return
+	}
+
+	var invitee *database.User
+	var err error
+	if req.UserID != nil {
+		invitee, err = app.models.Users.Get(*req.UserID)
+	} else {
+		invitee, err = app.models.Users.GetByEmail(strings.TrimSpace(req.Email))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if invitee == nil && req.UserID != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	inviter, _ := app.getUserFromContext(c)
+	inv := &database.Invitation{EventID: ev.ID, InvitedBy: &inviter.ID, Email: strings.TrimSpace(req.Email)}
+	// An address only stands for an account once its owner verified it
+	if invitee != nil && (req.UserID != nil || invitee.EmailVerified()) {
+		inv.UserID = &invitee.ID
+		inv.Email = invitee.Email
+	}
+
+	if err := app.models.Invitations.Insert(inv); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "This person already has a pending invitation to the event"})
+			return
+		}
+		log.Printf("createInvitation: db insert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	app.background(func() { app.sendInvitationEmail(ev, inviter, inv) })
+
+	c.JSON(http.StatusCreated, inv)
+}
+
+func (app *application) sendInvitationEmail(ev *database.Event, inviter *database.User, inv *database.Invitation) {
+	msg := mailer.Message{
+		To:      inv.Email,
+		Subject: "You are invited to " + ev.Title,
+		Body: fmt.Sprintf("Hi,\n\n%s invited you to %s on %s.\n\nAccept or decline the invitation at %s/invitations\n",
+			inviter.Name, ev.Title, ev.StartTime, app.appURL),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := app.mailer.Send(ctx, msg); err != nil {
+		log.Printf("[MAIL] send invitation %d email: %v", inv.ID, err)
+	}
+}
+
+// @Summary List an event's invitations
+// @Description List every invitation to an event, newest first (event owner, co-organizer or admin)
+// @Tags Invitations
+// @Produce json
+// @Param id path int true "Event ID"
+// @Success 200 {array} database.Invitation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invitations [get]
+func (app *application) listEventInvitations(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+
+	invitations, err := app.models.Invitations.ForEvent(ev.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations"})
+		return
+	}
+	if invitations == nil {
+		invitations = []*database.Invitation{}
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// @Summary Revoke an invitation
+// @Description Withdraw a pending invitation (event owner, co-organizer or admin). Attendees who already accepted keep their RSVP.
+// @Tags Invitations
+// @Param id path int true "Event ID"
+// @Param invitationId path int true "Invitation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invitations/{invitationId} [delete]
+func (app *application) revokeInvitation(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	revoked, err := app.models.Invitations.Revoke(ev.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// @Summary Create an invite link
+// @Description Create a signed link that adds whoever redeems it to the event (event owner, co-organizer or admin). Use max_uses 1 for a single-use link; omit it for unlimited uses.
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param link body createInviteLinkRequest false "Use limit and lifetime"
+// @Success 201 {object} inviteLinkResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invite-links [post]
+func (app *application) createInviteLink(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+
+	var req createInviteLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+	}
+
+	creator, _ := app.getUserFromContext(c)
+	link := &database.InviteLink{EventID: ev.ID, CreatedBy: &creator.ID, MaxUses: req.MaxUses}
+	if req.ExpiresInHours > 0 {
+		expires := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour).UTC()
+		link.ExpiresAt = &expires
+	}
+
+	if err := app.models.Invitations.InsertLink(link); err != nil {
+		log.Printf("createInviteLink: db insert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, app.newInviteLinkResponse(link))
+}
+
+// @Summary List an event's invite links
+// @Description List every invite link of an event with its token and use count, newest first (event owner, co-organizer or admin)
+// @Tags Invitations
+// @Produce json
+// @Param id path int true "Event ID"
+// @Success 200 {array} inviteLinkResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invite-links [get]
+func (app *application) listInviteLinks(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+
+	links, err := app.models.Invitations.LinksForEvent(ev.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invite links"})
+		return
+	}
+
+	resp := make([]inviteLinkResponse, 0, len(links))
+	for _, l := range links {
+		resp = append(resp, app.newInviteLinkResponse(l))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Revoke an invite link
+// @Description Disable an invite link (event owner, co-organizer or admin). Users who already joined through it keep their RSVP.
+// @Tags Invitations
+// @Param id path int true "Event ID"
+// @Param linkId path int true "Invite link ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/invite-links/{linkId} [delete]
+func (app *application) revokeInviteLink(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageAttendees)
+	if ev == nil {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("linkId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite link ID"})
+		return
+	}
+
+	revoked, err := app.models.Invitations.RevokeLink(ev.ID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite link"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active invite link not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite link revoked"})
+}
+
+// @Summary List my invitations
+// @Description List the pending invitations addressed to the authenticated user or their verified email address
+// @Tags Invitations
+// @Produce json
+// @Success 200 {array} database.ReceivedInvitation
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/invitations [get]
+func (app *application) listMyInvitations(c *gin.Context) {
+	v := app.viewer(c)
+
+	invitations, err := app.models.Invitations.Pending(v.UserID, v.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations"})
+		return
+	}
+	if invitations == nil {
+		invitations = []*database.ReceivedInvitation{}
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// @Summary Accept an invitation
+// @Description Accept a pending invitation and join the event, on its waitlist if it is full
+// @Tags Invitations
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/invitations/{id}/accept [post]
+func (app *application) acceptInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	v := app.viewer(c)
+	attendee, err := app.models.Invitations.Accept(id, v.UserID, v.Email)
+	app.respondInvitationJoin(c, attendee, err)
+}
+
+// @Summary Decline an invitation
+// @Description Decline a pending invitation
+// @Tags Invitations
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/invitations/{id}/decline [post]
+func (app *application) declineInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	v := app.viewer(c)
+	declined, err := app.models.Invitations.Decline(id, v.UserID, v.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invitation"})
+		return
+	}
+	if !declined {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+// @Summary Redeem an invite link
+// @Description Join the event of an invite link, on its waitlist if it is full. Users who already attend keep their RSVP without using up the link.
+// @Tags Invitations
+// @Produce json
+// @Param token path string true "Invite link token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/invite-links/{token}/accept [post]
+func (app *application) acceptInviteLink(c *gin.Context) {
+	id, ok := app.parseInviteLinkToken(c.Param("token"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite link is invalid, expired or used up"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	attendee, err := app.models.Invitations.UseLink(id, user.ID)
+	app.respondInvitationJoin(c, attendee, err)
+}
+
+// respondInvitationJoin writes the response for accepting an invitation or
+// redeeming an invite link, which yields attendee or fails with err.
+func (app *application) respondInvitationJoin(c *gin.Context, attendee *database.Attendee, err error) {
+	switch err {
+	case nil:
+	case database.ErrInvitationInvalid:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation is invalid, expired or used up"})
+		return
+	case database.ErrEventNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	case database.ErrEventClosed:
+		c.JSON(http.StatusConflict, gin.H{"error": "Event is not open for registration"})
+		return
+	case database.ErrAlreadyAttending:
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already an attendee of this event"})
+		return
+	default:
+		log.Printf("invitation join: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join event"})
+		return
+	}
+
+	resp := gin.H{"message": "Invitation accepted", "event_id": attendee.EventID, "status": attendee.Status}
+	if attendee.Status == database.AttendeeStatusWaitlisted {
+		resp["message"] = "Event is full; added to the waitlist"
+		resp["waitlist_position"] = attendee.WaitlistPosition
+	}
+	c.JSON(http.StatusOK, resp)
+}
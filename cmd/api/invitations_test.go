@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestPrivateEventInvitations(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	ids := map[string]int{}
+	tokens := map[string]string{"owner": ownerToken, "anonymous": ""}
+	for _, name := range []string{"invitee", "byemail", "linkuser", "late", "stranger"} {
+		u := insertUserWithPassword(t, app, name+"@example.com", "password123")
+		ids[name] = u.ID
+		tokens[name], _ = jwtForUser(app, u.ID)
+	}
+
+	create := func(title, visibility string) *database.Event {
+		t.Helper()
+		event := map[string]interface{}{"title": title, "description": "Only for people who know about it", "start_time": "2030-01-01T19:00:00Z", "end_time": "2030-01-01T22:00:00Z", "visibility": visibility}
+		code, body := api.do("POST", "/api/v1/events", ownerToken, event)
+		var ev database.Event
+		if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil || ev.Visibility != visibility {
+			t.Fatalf("create %s: %d %s", title, code, body)
+		}
+		return &ev
+	}
+	private := create("Private dinner", database.EventVisibilityPrivate)
+	unlisted := create("Unlisted meetup", database.EventVisibilityUnlisted)
+	create("Public talk", database.EventVisibilityPublic)
+
+	// listed returns the titles of the events who sees in the listing
+	listed := func(who string) string {
+		t.Helper()
+		code, body := api.do("GET", "/api/v1/events", tokens[who], nil)
+		var resp struct {
+			Data []database.Event `json:"data"`
+		}
+		if code != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("list as %s: %d %s", who, code, body)
+		}
+		var titles []string
+		for _, ev := range resp.Data {
+			titles = append(titles, ev.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+	canOpen := func(who string, ev *database.Event) bool {
+		t.Helper()
+		code, _ := api.do("GET", fmt.Sprintf("/api/v1/events/%d", ev.ID), tokens[who], nil)
+		return code == http.StatusOK
+	}
+
+	if got := listed("anonymous"); got != "Public talk" {
+		t.Fatalf("anonymous listing: %q", got)
+	}
+	if got := listed("owner"); got != "Private dinner,Unlisted meetup,Public talk" {
+		t.Fatalf("owner listing: %q", got)
+	}
+	if canOpen("anonymous", private) || canOpen("stranger", private) {
+		t.Fatal("private event must be hidden from people who are not invited")
+	}
+	if !canOpen("anonymous", unlisted) {
+		t.Fatal("unlisted events open by ID")
+	}
+
+	// A stale token on a public route gets the anonymous view, not a 401
+	tokens["stale"] = "expired.or.garbled"
+	if got := listed("stale"); got != "Public talk" {
+		t.Fatalf("listing with a stale token: %q", got)
+	}
+	if !canOpen("stale", unlisted) || canOpen("stale", private) {
+		t.Fatal("events opened with a stale token must look as they do anonymously")
+	}
+	if code, _ := api.do("GET", "/api/v1/auth/me", tokens["stale"], nil); code != http.StatusUnauthorized {
+		t.Fatalf("protected route with a stale token: expected 401, got %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", private.ID, ids["stranger"]), tokens["stranger"], nil); code != http.StatusNotFound {
+		t.Fatalf("uninvited join: expected 404, got %d", code)
+	}
+
+	// Invitation by user ID
+	invite := func(body map[string]interface{}) (int, database.Invitation) {
+		t.Helper()
+		code, resp := api.do("POST", fmt.Sprintf("/api/v1/events/%d/invitations", private.ID), ownerToken, body)
+		var inv database.Invitation
+		json.Unmarshal(resp, &inv)
+		return code, inv
+	}
+	code, inv := invite(map[string]interface{}{"user_id": ids["invitee"]})
+	if code != http.StatusCreated || inv.UserID == nil || inv.Email != "invitee@example.com" {
+		t.Fatalf("invite by user id: %d %+v", code, inv)
+	}
+	select {
+	case msg := <-app.mailer.(*testMailer).sent:
+		if msg.To != "invitee@example.com" || !strings.Contains(msg.Body, "Private dinner") {
+			t.Fatalf("invitation mail: %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invitation mail sent")
+	}
+	if code, _ := invite(map[string]interface{}{"email": "invitee@example.com"}); code != http.StatusConflict {
+		t.Fatalf("duplicate invitation: expected 409, got %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/invitations", private.ID), tokens["stranger"], map[string]interface{}{"user_id": ids["stranger"]}); code != http.StatusForbidden {
+		t.Fatalf("stranger inviting: expected 403, got %d", code)
+	}
+	if !canOpen("invitee", private) || listed("invitee") != "Private dinner,Public talk" {
+		t.Fatalf("invitee should see the private event: %q", listed("invitee"))
+	}
+	code, body := api.do("GET", "/api/v1/invitations", tokens["invitee"], nil)
+	var received []database.ReceivedInvitation
+	if code != http.StatusOK || json.Unmarshal(body, &received) != nil || len(received) != 1 || received[0].Event.ID != private.ID {
+		t.Fatalf("my invitations: %d %s", code, body)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/invitations/%d/accept", inv.ID), tokens["stranger"], nil); code != http.StatusNotFound {
+		t.Fatalf("accepting someone else's invitation: expected 404, got %d", code)
+	}
+	code, body = api.do("POST", fmt.Sprintf("/api/v1/invitations/%d/accept", inv.ID), tokens["invitee"], nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"status":"pending"`) {
+		t.Fatalf("accept: %d %s", code, body)
+	}
+	if a, _ := app.models.Attendees.Get(private.ID, ids["invitee"]); a == nil {
+		t.Fatal("accepting should add the invitee as attendee")
+	}
+
+	// Invitations by email are only claimed by a verified address
+	code, inv = invite(map[string]interface{}{"email": "byemail@example.com"})
+	if code != http.StatusCreated || inv.UserID != nil {
+		t.Fatalf("invite unverified address: %d %+v", code, inv)
+	}
+	if canOpen("byemail", private) {
+		t.Fatal("unverified address must not claim the invitation")
+	}
+	if _, err := app.models.Users.MarkEmailVerified(ids["byemail"]); err != nil {
+		t.Fatal(err)
+	}
+	if !canOpen("byemail", private) {
+		t.Fatal("verified address should see the private event")
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/invitations/%d/decline", inv.ID), tokens["byemail"], nil); code != http.StatusOK {
+		t.Fatalf("decline: %d", code)
+	}
+	if canOpen("byemail", private) {
+		t.Fatal("declined invitations grant no access")
+	}
+
+	// Signed invite links
+	link := func(body map[string]interface{}) inviteLinkResponse {
+		t.Helper()
+		code, resp := api.do("POST", fmt.Sprintf("/api/v1/events/%d/invite-links", private.ID), ownerToken, body)
+		var l inviteLinkResponse
+		if code != http.StatusCreated || json.Unmarshal(resp, &l) != nil || l.Token == "" {
+			t.Fatalf("create link: %d %s", code, resp)
+		}
+		return l
+	}
+	redeem := func(who, token string) int {
+		t.Helper()
+		code, _ := api.do("POST", "/api/v1/invite-links/"+token+"/accept", tokens[who], nil)
+		return code
+	}
+	single := link(map[string]interface{}{"max_uses": 1})
+	if code := redeem("linkuser", single.Token+"x"); code != http.StatusNotFound {
+		t.Fatalf("tampered token: expected 404, got %d", code)
+	}
+	if code := redeem("invitee", single.Token); code != http.StatusOK {
+		t.Fatalf("existing attendee redeeming: %d", code)
+	}
+	if code := redeem("linkuser", single.Token); code != http.StatusOK {
+		t.Fatalf("redeem single-use link: %d", code)
+	}
+	if code := redeem("late", single.Token); code != http.StatusNotFound {
+		t.Fatalf("used up link: expected 404, got %d", code)
+	}
+	if !canOpen("linkuser", private) {
+		t.Fatal("attendees see the private event")
+	}
+
+	multi := link(nil)
+	if code := redeem("late", multi.Token); code != http.StatusOK {
+		t.Fatalf("redeem multi-use link: %d", code)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d/invite-links/%d", private.ID, multi.ID), ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("revoke link: %d", code)
+	}
+	if code := redeem("stranger", multi.Token); code != http.StatusNotFound {
+		t.Fatalf("revoked link: expected 404, got %d", code)
+	}
+
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d/invite-links", private.ID), ownerToken, nil)
+	var links []inviteLinkResponse
+	if code != http.StatusOK || json.Unmarshal(body, &links) != nil || len(links) != 2 || links[1].Uses != 1 || links[0].RevokedAt == nil {
+		t.Fatalf("list links: %d %s", code, body)
+	}
+
+	// Invitations and links to an event deleted since lead nowhere
+	code, inv = invite(map[string]interface{}{"user_id": ids["stranger"]})
+	if code != http.StatusCreated {
+		t.Fatalf("invite stranger: %d", code)
+	}
+	last := link(map[string]interface{}{})
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d", private.ID), ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("delete event: %d", code)
+	}
+	if code := redeem("stranger", last.Token); code != http.StatusNotFound {
+		t.Fatalf("link to a deleted event: expected 404, got %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/invitations/%d/accept", inv.ID), tokens["stranger"], nil); code != http.StatusNotFound {
+		t.Fatalf("invitation to a deleted event: expected 404, got %d", code)
+	}
+}
+
+func TestUserEventsHidePrivateEvents(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	alice := insertUserWithPassword(t, app, "alice@example.com", "password123")
+	aliceToken, _ := jwtForUser(app, alice.ID)
+	carol := insertUserWithPassword(t, app, "carol@example.com", "password123")
+	carolToken, _ := jwtForUser(app, carol.ID)
+
+	for _, visibility := range []string{"public", "private"} {
+		code, body := api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{"title": "A " + visibility + " party", "description": "Drinks and snacks",
+			"start_time": "2030-07-01T18:00:00Z", "end_time": "2030-07-01T22:00:00Z", "visibility": visibility})
+		var ev database.Event
+		if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+			t.Fatalf("create %s event: %d %s", visibility, code, body)
+		}
+		if code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", ev.ID, alice.ID), ownerToken, nil); code != http.StatusCreated {
+			t.Fatalf("add alice to the %s event: %d", visibility, code)
+		}
+	}
+
+	// alice and the owner see both events, a third party only the public one
+	path := fmt.Sprintf("/api/v1/attendees/%d/events", alice.ID)
+	for _, tc := range []struct {
+		name, token string
+		want        int
+	}{{"alice", aliceToken, 2}, {"owner", ownerToken, 2}, {"carol", carolToken, 1}} {
+		code, body := api.do("GET", path, tc.token, nil)
+		var events []database.UserEvent
+		if code != http.StatusOK || json.Unmarshal(body, &events) != nil || len(events) != tc.want {
+			t.Fatalf("events as %s: %d %s", tc.name, code, body)
+		}
+		if tc.want == 1 && events[0].Visibility != database.EventVisibilityPublic {
+			t.Fatalf("events as %s: %s", tc.name, body)
+		}
+
+		code, body = api.do("GET", path+"?limit=10", tc.token, nil)
+		var page struct {
+			Data []database.UserEvent `json:"data"`
+		}
+		if code != http.StatusOK || json.Unmarshal(body, &page) != nil || len(page.Data) != tc.want {
+			t.Fatalf("events page as %s: %d %s", tc.name, code, body)
+		}
+	}
+}
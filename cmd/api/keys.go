@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingKey is one asymmetric JWT key. Keys loaded from a public key file
+// can only verify tokens; they are kept around while tokens signed by a
+// retired private key may still be in circulation.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// keySet holds every key accepted for verification and the one used to sign
+// new tokens.
+type keySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+// loadKeySet reads every *.pem file in dir. The file name without extension
+// (and without a trailing ".pub") is the key id. Private keys may be PKCS#8
+// RSA or Ed25519 keys or PKCS#1 RSA keys; "PUBLIC KEY" files are loaded for
+// verification only. signingKID selects the signing key; when empty the
+// private key with the greatest id is used, so date-named files rotate
+// naturally.
+func loadKeySet(dir, signingKID string) (*keySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &keySet{keys: make(map[string]*signingKey)}
+	var privateKIDs []string
+
+	for _, path := range paths {
+		kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".pem"), ".pub")
+		key, err := loadKeyFile(path, kid)
+		if err != nil {
+			return nil, fmt.Errorf("load key %s: %w", path, err)
+		}
+		if existing, ok := ks.keys[kid]; ok && existing.private != nil {
+			// A private key already provides the public half
+			continue
+		}
+		ks.keys[kid] = key
+		if key.private != nil {
+			privateKIDs = append(privateKIDs, kid)
+		}
+	}
+
+	if len(privateKIDs) == 0 {
+		return nil, fmt.Errorf("no private signing keys found in %s", dir)
+	}
+
+	if signingKID == "" {
+		sort.Strings(privateKIDs)
+		signingKID = privateKIDs[len(privateKIDs)-1]
+	}
+	ks.signing = ks.keys[signingKID]
+	if ks.signing == nil || ks.signing.private == nil {
+		return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+	}
+
+	return ks, nil
+}
+
+func loadKeyFile(path, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.private, key.public = k, k.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		key.public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	}
+
+	return key, nil
+}
+
+// signToken signs claims with the active asymmetric key, or with the HS256
+// development secret when no key set is configured.
+func (app *application) signToken(claims jwt.MapClaims) (string, error) {
+	if app.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(app.jwtSecret))
+	}
+
+	token := jwt.NewWithClaims(app.keys.signing.method, claims)
+	token.Header["kid"] = app.keys.signing.kid
+	return token.SignedString(app.keys.signing.private)
+}
+
+// parseToken verifies a token's signature and standard claims. With a key
+// set configured only asymmetric tokens whose kid names a known key are
+// accepted, so HS256 tokens cannot be forged with a public key.
+func (app *application) parseToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if app.keys == nil {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+			return []byte(app.jwtSecret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := app.keys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return key.public, nil
+	})
+}
+
+// jwk is a JSON Web Key as published in the JWKS document (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+func (ks *keySet) jwks() jwksDocument {
+	doc := jwksDocument{Keys: []jwk{}}
+	if ks == nil {
+		return doc
+	}
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		k := jwk{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			k.Kty = "RSA"
+			k.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			k.Kty = "OKP"
+			k.Crv = "Ed25519"
+			k.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		doc.Keys = append(doc.Keys, k)
+	}
+	return doc
+}
+
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying EventHub access tokens. Tokens carry a kid header naming the key that signed them.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} jwksDocument
+// @Router /.well-known/jwks.json [get]
+func (app *application) jwksHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, app.keys.jwks())
+}
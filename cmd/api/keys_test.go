@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
+
+func TestAsymmetricSigningAndRotation(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "2026-01", edKey)
+
+	keys, err := loadKeySet(dir, "")
+	if err != nil {
+		t.Fatalf("load keys: %v", err)
+	}
+	app.keys = keys
+
+	u := insertUserWithPassword(t, app, "keys@example.com", "password123")
+	pair, err := app.startSession(u)
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(pair.AccessToken, jwt.MapClaims{})
+	if parsed.Header["alg"] != "EdDSA" || parsed.Header["kid"] != "2026-01" {
+		t.Fatalf("unexpected token header: %v", parsed.Header)
+	}
+
+	// Rotate: a newer RSA key becomes the signing key, the old key still verifies
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePrivateKey(t, dir, "2026-02", rsaKey)
+	if app.keys, err = loadKeySet(dir, ""); err != nil {
+		t.Fatalf("reload keys: %v", err)
+	}
+	if app.keys.signing.kid != "2026-02" {
+		t.Fatalf("expected newest key to sign, got %q", app.keys.signing.kid)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	if code, body := api.do("GET", "/api/v1/auth/me", pair.AccessToken, nil); code != http.StatusOK {
+		t.Fatalf("token from previous key: %d %s", code, body)
+	}
+
+	fresh, err := app.issueAccessToken(u, "")
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if code, body := api.do("GET", "/api/v1/auth/me", fresh, nil); code != http.StatusOK {
+		t.Fatalf("token from new key: %d %s", code, body)
+	}
+
+	// HS256 tokens are refused once asymmetric keys are configured
+	legacy, _ := jwtForUser(app, u.ID)
+	if code, _ := api.do("GET", "/api/v1/auth/me", legacy, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected HS256 token to be rejected, got %d", code)
+	}
+
+	// Unknown kid
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"user_id": u.ID})
+	forged.Header["kid"] = "2025-12"
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	forgedToken, _ := forged.SignedString(otherKey)
+	if code, _ := api.do("GET", "/api/v1/auth/me", forgedToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected unknown kid to be rejected, got %d", code)
+	}
+
+	code, body := api.do("GET", "/.well-known/jwks.json", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("jwks: %d %s", code, body)
+	}
+	var doc jwksDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("decode jwks: %v", err)
+	}
+	if len(doc.Keys) != 2 || doc.Keys[0].Kty != "OKP" || doc.Keys[1].Kty != "RSA" || doc.Keys[1].N == "" {
+		t.Fatalf("unexpected jwks: %s", body)
+	}
+}
+
+func TestLoadKeySetRejectsWeakOrMissingKeys(t *testing.T) {
+	if _, err := loadKeySet(t.TempDir(), ""); err == nil {
+		t.Fatal("expected an empty key directory to be rejected")
+	}
+
+	dir := t.TempDir()
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	writePrivateKey(t, dir, "weak", weak)
+	if _, err := loadKeySet(dir, ""); err == nil {
+		t.Fatal("expected a 1024-bit RSA key to be rejected")
+	}
+
+	dir = t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "current", edKey)
+	if _, err := loadKeySet(dir, "missing"); err == nil {
+		t.Fatal("expected an unknown signing key id to be rejected")
+	}
+}
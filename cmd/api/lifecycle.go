@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/mailer"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventTransitions lists the statuses an event may move to from each
+// status. Cancelled and completed events are final.
+var eventTransitions = map[string][]string{
+	database.EventStatusDraft:     {database.EventStatusPublished},
+	database.EventStatusPublished: {database.EventStatusCancelled, database.EventStatusCompleted},
+}
+
+// canMoveEvent reports whether an event may move from status from to to.
+func canMoveEvent(from, to string) bool {
+	for _, s := range eventTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type updateEventStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=published cancelled completed" example:"cancelled"`
+	Reason string `json:"reason" binding:"max=500" example:"The speaker is ill"`
+}
+
+// @Summary Change an event's status
+// @Description Publish a draft, or cancel or complete a published event (owner, co-organizer or admin). Events can only be completed once they have started; the scheduler completes them when they end. Cancelling notifies the attendees and the waitlist by email and frees the venue; their RSVPs are kept. For an occurrence of a recurring event, scope=all changes every occurrence in the same status and the series.
+// @Tags Events
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param scope query string false "this (default) or all"
+// @Param status body main.updateEventStatusRequest true "New status and reason"
+// @Success 200 {object} main.EventDoc
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/status [patch]
+func (app *application) updateEventStatus(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permEditEvent)
+	if ev == nil {
+		return
+	}
+	scope, ok := seriesScope(c)
+	if !ok {
+		return
+	}
+	if scope == database.SeriesScopeFollowing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, must be this or all"})
+		return
+	}
+	if scope == database.SeriesScopeAll && ev.SeriesID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only occurrences of recurring events can change status with scope all"})
+		return
+	}
+
+	var req updateEventStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if req.Status == ev.Status {
+		c.JSON(http.StatusOK, ev)
+		return
+	}
+	if !canMoveEvent(ev.Status, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An event cannot move from " + ev.Status + " to " + req.Status})
+		return
+	}
+	if req.Status == database.EventStatusCompleted {
+		if scope == database.SeriesScopeAll {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Occurrences can only be completed one at a time"})
+			return
+		}
+		if start, err := time.Parse(time.RFC3339, ev.StartTime); err == nil && start.After(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An event cannot be completed before it starts"})
+			return
+		}
+	}
+
+	tokenUser, _ := app.getUserFromContext(c)
+	ids, err := app.models.Events.SetStatus(ev, req.Status, scope, tokenUser.ID, req.Reason)
+	if err != nil {
+		if err == database.ErrEventStatusChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event status changed in the meantime; reload and try again"})
+			return
+		}
+		log.Printf("updateEventStatus: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event status"})
+		return
+	}
+
+	var event *database.Event
+	for _, id := range ids {
+		moved, err := app.models.Events.Get(id)
+		if err != nil || moved == nil {
+			log.Printf("updateEventStatus: load event %d: %v", id, err)
+			continue
+		}
+		if req.Status == database.EventStatusCancelled {
+			app.background(func() { app.sendCancellationEmails(moved, req.Reason) })
+		}
+		if req.Status == database.EventStatusPublished {
+			// Invitees accepted while the event was a draft may be waiting
+			app.promoteWaitlist(moved)
+		}
+		if id == ev.ID {
+			event = moved
+		}
+	}
+	if event == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// @Summary Event status history
+// @Description List every status change of an event, oldest first (event team or admin)
+// @Tags Events
+// @Produce json
+// @Param id path int true "Event ID"
+// @Success 200 {array} database.EventStatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/status-history [get]
+func (app *application) getEventStatusHistory(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permViewEvent)
+	if ev == nil {
+		return
+	}
+
+	changes, err := app.models.Events.StatusHistory(ev.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status history"})
+		return
+	}
+	if changes == nil {
+		changes = []*database.EventStatusChange{}
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// sendCancellationEmails tells everyone holding or waiting for a seat at ev
+// that it was cancelled.
+func (app *application) sendCancellationEmails(ev *database.Event, reason string) {
+	var recipients []*database.EventAttendee
+	for _, status := range []string{"", database.AttendeeStatusWaitlisted} {
+		attendees, err := app.models.Attendees.GetEventAttendees(ev.ID, status)
+		if err != nil {
+			log.Printf("[MAIL] load attendees of cancelled event %d: %v", ev.ID, err)
+			return
+		}
+		recipients = append(recipients, attendees...)
+	}
+
+	note := ""
+	if reason != "" {
+		note = "\n\nThe organizer wrote: " + reason
+	}
+	link := fmt.Sprintf("%s/events/%d", app.appURL, ev.ID)
+	for _, a := range recipients {
+		if a.Status == database.AttendeeStatusDeclined || a.Status == database.AttendeeStatusRejected {
+			continue
+		}
+		msg := mailer.Message{
+			To:      a.Email,
+			Subject: ev.Title + " has been cancelled",
+			Body: fmt.Sprintf("Hi %s,\n\n%s, planned for %s UTC, has been cancelled.%s\n\n%s\n",
+				a.Name, ev.Title, ev.StartTime, note, link),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := app.mailer.Send(ctx, msg)
+		cancel()
+		if err != nil {
+			log.Printf("[MAIL] send cancellation email for event %d to user %d: %v", ev.ID, a.ID, err)
+		}
+	}
+}
+
+// completeEvents periodically moves published events that have ended to
+// completed.
+func (app *application) completeEvents(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ids, err := app.models.Events.CompleteEnded(time.Now())
+		if err != nil {
+			log.Printf("[EVENTS] complete ended events: %v", err)
+			continue
+		}
+		if len(ids) > 0 {
+			log.Printf("[EVENTS] completed %d ended events", len(ids))
+		}
+	}
+}
+
+// purgeDeleted periodically removes the events and users deleted longer
+// ago than the retention period. Users are purged after their events so
+// that accounts deleted along with them go in the same run.
+func (app *application) purgeDeleted(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-app.deletedRetention)
+		if n, err := app.models.Events.PurgeDeleted(cutoff); err != nil {
+			log.Printf("[EVENTS] purge deleted events: %v", err)
+		} else if n > 0 {
+			log.Printf("[EVENTS] purged %d deleted events", n)
+		}
+		n, freed, err := app.models.Users.PurgeDeleted(cutoff)
+		if err != nil {
+			log.Printf("[USERS] purge deleted users: %v", err)
+		} else if n > 0 {
+			log.Printf("[USERS] purged %d deleted users", n)
+		}
+		// Seats held by purged accounts go to the waitlist
+		for _, id := range freed {
+			ev, err := app.models.Events.Get(id)
+			if err != nil {
+				log.Printf("[WAITLIST] load event %d: %v", id, err)
+				continue
+			}
+			if ev != nil {
+				app.promoteWaitlist(ev)
+			}
+		}
+	}
+}
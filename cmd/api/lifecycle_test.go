@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestEventLifecycle(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	alice := insertUserWithPassword(t, app, "alice@example.com", "password123")
+	aliceToken, _ := jwtForUser(app, alice.ID)
+	bob := insertUserWithPassword(t, app, "bob@example.com", "password123")
+	bobToken, _ := jwtForUser(app, bob.ID)
+
+	code, body := api.do("POST", "/api/v1/venues", ownerToken, map[string]interface{}{"name": "Hall", "address": "Main St 1"})
+	var venue database.Venue
+	if code != http.StatusCreated || json.Unmarshal(body, &venue) != nil {
+		t.Fatalf("create venue: %d %s", code, body)
+	}
+	payload := map[string]interface{}{"title": "Launch party", "description": "Celebrating the launch", "start_time": "2030-04-01T18:00:00Z", "end_time": "2030-04-01T22:00:00Z",
+		"capacity": 1, "venue_id": venue.ID, "status": "draft"}
+	code, body = api.do("POST", "/api/v1/events", ownerToken, payload)
+	var ev database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil || ev.Status != database.EventStatusDraft {
+		t.Fatalf("create draft: %d %s", code, body)
+	}
+	eventPath := fmt.Sprintf("/api/v1/events/%d", ev.ID)
+	setStatus := func(token, query string, req map[string]interface{}) (int, database.Event) {
+		t.Helper()
+		code, body := api.do("PATCH", eventPath+"/status"+query, token, req)
+		var got database.Event
+		json.Unmarshal(body, &got)
+		return code, got
+	}
+
+	// Drafts are only shown to their owner
+	for _, token := range []string{"", aliceToken} {
+		if code, _ := api.do("GET", eventPath, token, nil); code != http.StatusNotFound {
+			t.Fatalf("draft seen by others: expected 404, got %d", code)
+		}
+		if code, body := api.do("GET", "/api/v1/events", token, nil); code != http.StatusOK || strings.Contains(string(body), "Launch party") {
+			t.Fatalf("draft listed to others: %d %s", code, body)
+		}
+	}
+	if code, body := api.do("GET", "/api/v1/events", ownerToken, nil); code != http.StatusOK || !strings.Contains(string(body), "Launch party") {
+		t.Fatalf("draft not listed to its owner: %d %s", code, body)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("%s/attendees?user_id=%d", eventPath, owner.ID), ownerToken, nil); code != http.StatusConflict {
+		t.Fatalf("join a draft: expected 409, got %d", code)
+	}
+
+	// Transitions are enforced
+	if code, _ := setStatus(aliceToken, "", map[string]interface{}{"status": "published"}); code != http.StatusForbidden {
+		t.Fatalf("publish as another user: expected 403, got %d", code)
+	}
+	if code, _ := setStatus(ownerToken, "", map[string]interface{}{"status": "draft"}); code != http.StatusBadRequest {
+		t.Fatalf("invalid status: expected 400, got %d", code)
+	}
+	if code, _ := setStatus(ownerToken, "", map[string]interface{}{"status": "cancelled"}); code != http.StatusConflict {
+		t.Fatalf("cancel a draft: expected 409, got %d", code)
+	}
+	if code, got := setStatus(ownerToken, "", map[string]interface{}{"status": "published"}); code != http.StatusOK || got.Status != database.EventStatusPublished {
+		t.Fatalf("publish: %d %+v", code, got)
+	}
+	if code, _ := api.do("GET", eventPath, "", nil); code != http.StatusOK {
+		t.Fatalf("published event: expected 200, got %d", code)
+	}
+	if code, _ := setStatus(ownerToken, "", map[string]interface{}{"status": "completed"}); code != http.StatusConflict {
+		t.Fatalf("complete before the start: expected 409, got %d", code)
+	}
+
+	for _, u := range []struct {
+		user  *database.User
+		token string
+	}{{alice, aliceToken}, {bob, bobToken}} {
+		if code, body := api.do("POST", fmt.Sprintf("%s/attendees?user_id=%d", eventPath, u.user.ID), u.token, nil); code != http.StatusCreated {
+			t.Fatalf("join %s: %d %s", u.user.Email, code, body)
+		}
+	}
+
+	// Cancelling keeps the RSVPs and notifies the attendee and the waitlist
+	if code, got := setStatus(ownerToken, "", map[string]interface{}{"status": "cancelled", "reason": "The band is stuck abroad"}); code != http.StatusOK || got.Status != database.EventStatusCancelled {
+		t.Fatalf("cancel: %d %+v", code, got)
+	}
+	mailed := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(mailed) < 2 {
+		select {
+		case msg := <-app.mailer.(*testMailer).sent:
+			if !strings.Contains(msg.Body, "The band is stuck abroad") {
+				t.Fatalf("cancellation mail to %s: %q", msg.To, msg.Body)
+			}
+			mailed[msg.To] = true
+		case <-timeout:
+			t.Fatalf("cancellation mails sent to %v", mailed)
+		}
+	}
+	if !mailed[alice.Email] || !mailed[bob.Email] {
+		t.Fatalf("cancellation mails sent to %v", mailed)
+	}
+	code, body = api.do("GET", eventPath+"/attendees", ownerToken, nil)
+	var attendees []database.EventAttendee
+	if code != http.StatusOK || json.Unmarshal(body, &attendees) != nil || len(attendees) != 1 {
+		t.Fatalf("attendees of cancelled event: %d %s", code, body)
+	}
+
+	// Cancelled events are closed and free their venue
+	carol := insertUserWithPassword(t, app, "carol@example.com", "password123")
+	carolToken, _ := jwtForUser(app, carol.ID)
+	if code, _ := api.do("POST", fmt.Sprintf("%s/attendees?user_id=%d", eventPath, carol.ID), carolToken, nil); code != http.StatusConflict {
+		t.Fatalf("join a cancelled event: expected 409, got %d", code)
+	}
+	update := map[string]interface{}{"user_id": owner.ID, "title": "Launch party", "description": "Celebrating the launch", "start_time": "2030-04-02T18:00:00Z", "end_time": "2030-04-02T22:00:00Z"}
+	if code, _ := api.do("PUT", eventPath, ownerToken, update); code != http.StatusConflict {
+		t.Fatalf("update a cancelled event: expected 409, got %d", code)
+	}
+	if code, _ := setStatus(ownerToken, "", map[string]interface{}{"status": "published"}); code != http.StatusConflict {
+		t.Fatalf("republish: expected 409, got %d", code)
+	}
+	payload["title"], payload["status"] = "Replacement party", "published"
+	if code, body := api.do("POST", "/api/v1/events", ownerToken, payload); code != http.StatusCreated {
+		t.Fatalf("book the venue of a cancelled event: %d %s", code, body)
+	}
+
+	code, body = api.do("GET", eventPath+"/status-history", ownerToken, nil)
+	var history []database.EventStatusChange
+	if code != http.StatusOK || json.Unmarshal(body, &history) != nil || len(history) != 2 {
+		t.Fatalf("status history: %d %s", code, body)
+	}
+	if history[1].FromStatus != "published" || history[1].ToStatus != "cancelled" || history[1].Reason != "The band is stuck abroad" || *history[1].ChangedBy != owner.ID {
+		t.Fatalf("cancellation entry: %+v", history[1])
+	}
+	if code, _ := api.do("GET", eventPath+"/status-history", aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("history as an attendee: expected 403, got %d", code)
+	}
+
+	// The scheduler completes published events once they have ended
+	past := map[string]interface{}{"title": "Yesterday's talk", "description": "Already happened", "start_time": "2020-01-01T10:00:00Z", "end_time": "2020-01-01T11:00:00Z"}
+	code, body = api.do("POST", "/api/v1/events", ownerToken, past)
+	var ended database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ended) != nil {
+		t.Fatalf("create past event: %d %s", code, body)
+	}
+	ids, err := app.models.Events.CompleteEnded(time.Now())
+	if err != nil || len(ids) != 1 || ids[0] != ended.ID {
+		t.Fatalf("complete ended events: %v %v", ids, err)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d/status-history", ended.ID), ownerToken, nil)
+	if code != http.StatusOK || json.Unmarshal(body, &history) != nil || len(history) != 1 || history[0].ToStatus != "completed" || history[0].ChangedBy != nil {
+		t.Fatalf("automatic completion: %d %s", code, body)
+	}
+
+	// scope=all cancels every occurrence and ends the series
+	start := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Hour)
+	code, body = api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{
+		"title": "Daily standup", "description": "Fifteen minutes, no more",
+		"start_time": start.Format(time.RFC3339), "end_time": start.Add(15 * time.Minute).Format(time.RFC3339),
+		"recurrence": "FREQ=DAILY;COUNT=3",
+	})
+	var first database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &first) != nil {
+		t.Fatalf("create series: %d %s", code, body)
+	}
+	eventPath = fmt.Sprintf("/api/v1/events/%d", first.ID)
+	if code, _ := setStatus(ownerToken, "?scope=following", map[string]interface{}{"status": "cancelled"}); code != http.StatusBadRequest {
+		t.Fatalf("scope=following: expected 400, got %d", code)
+	}
+	if code, _ := setStatus(ownerToken, "?scope=all", map[string]interface{}{"status": "cancelled"}); code != http.StatusOK {
+		t.Fatalf("cancel series: %d", code)
+	}
+	if err := app.models.Series.MaterializeUntil(time.Now().Add(maxSeriesHorizon)); err != nil {
+		t.Fatalf("materialize: %v", err)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events?series_id=%d", *first.SeriesID), "", nil)
+	var listing struct {
+		Data []database.Event `json:"data"`
+	}
+	if code != http.StatusOK || json.Unmarshal(body, &listing) != nil || len(listing.Data) != 3 {
+		t.Fatalf("cancelled series: %d %s", code, body)
+	}
+	for _, occ := range listing.Data {
+		if occ.Status != database.EventStatusCancelled {
+			t.Fatalf("occurrence %d is %s", occ.ID, occ.Status)
+		}
+	}
+
+	// Draft series stay drafts until published together
+	code, body = api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{
+		"title": "Weekly retro", "description": "What went well, what did not",
+		"start_time": start.Format(time.RFC3339), "end_time": start.Add(time.Hour).Format(time.RFC3339),
+		"recurrence": "FREQ=DAILY;COUNT=2", "status": "draft",
+	})
+	if code != http.StatusCreated || json.Unmarshal(body, &first) != nil || first.Status != database.EventStatusDraft {
+		t.Fatalf("create draft series: %d %s", code, body)
+	}
+	seriesPath := fmt.Sprintf("/api/v1/events?series_id=%d", *first.SeriesID)
+	if code, body = api.do("GET", seriesPath, "", nil); code != http.StatusOK || json.Unmarshal(body, &listing) != nil || len(listing.Data) != 0 {
+		t.Fatalf("draft series listed: %d %s", code, body)
+	}
+	eventPath = fmt.Sprintf("/api/v1/events/%d", first.ID)
+	if code, _ := setStatus(ownerToken, "?scope=all", map[string]interface{}{"status": "published"}); code != http.StatusOK {
+		t.Fatalf("publish series: %d", code)
+	}
+	if code, body = api.do("GET", seriesPath, "", nil); code != http.StatusOK || json.Unmarshal(body, &listing) != nil || len(listing.Data) != 2 {
+		t.Fatalf("published series: %d %s", code, body)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func postLogin(t *testing.T, ts *httptest.Server, email, password string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	resp, err := http.Post(ts.URL+"/api/v1/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestLoginLockout(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := insertUserWithPassword(t, app, "victim@example.com", "password123")
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	if err := app.models.Users.Insert(admin); err != nil {
+		t.Fatalf("insert admin: %v", err)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	// Existing and unknown accounts behave identically
+	for _, email := range []string{u.Email, "ghost@example.com"} {
+		for i := 1; i < loginAccountThreshold; i++ {
+			if resp := postLogin(t, ts, email, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("%s attempt %d: expected 401, got %d", email, i, resp.StatusCode)
+			}
+		}
+		resp := postLogin(t, ts, email, "wrong")
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected lockout, got %d", email, resp.StatusCode)
+		}
+		if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry < 1 || retry > int(loginBaseLockout.Seconds()) {
+			t.Fatalf("%s: unexpected Retry-After %q", email, resp.Header.Get("Retry-After"))
+		}
+	}
+
+	// The correct password is refused while locked
+	if resp := postLogin(t, ts, u.Email, "password123"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected locked account to stay locked, got %d", resp.StatusCode)
+	}
+
+	adminToken, _ := jwtForUser(app, admin.ID)
+	code, body := api.do("GET", "/api/v1/admin/lockouts?user_id="+strconv.Itoa(u.ID), adminToken, nil)
+	var events []database.LockoutEvent
+	if code != http.StatusOK || json.Unmarshal(body, &events) != nil || len(events) != 1 || events[0].Kind != database.LockoutKindAccount {
+		t.Fatalf("lockouts: %d %s", code, body)
+	}
+
+	// Keep failing from the same address until the IP is locked as well;
+	// unlocking the account clears it since it locked the account
+	for i := 2 * loginAccountThreshold; i < loginIPThreshold-1; i++ {
+		if resp := postLogin(t, ts, "other"+strconv.Itoa(i)+"@example.com", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, resp.StatusCode)
+		}
+	}
+	if resp := postLogin(t, ts, "last@example.com", "wrong"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected IP lockout, got %d", resp.StatusCode)
+	}
+
+	if code, body := api.do("POST", "/api/v1/admin/users/"+strconv.Itoa(u.ID)+"/unlock", adminToken, nil); code != http.StatusOK {
+		t.Fatalf("unlock: %d %s", code, body)
+	}
+	if resp := postLogin(t, ts, u.Email, "password123"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login after unlock: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	insertUserWithPassword(t, app, "someone@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	// Spread failures over many accounts so only the IP threshold trips
+	for i := 1; i < loginIPThreshold; i++ {
+		if resp := postLogin(t, ts, "user"+strconv.Itoa(i)+"@example.com", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, resp.StatusCode)
+		}
+	}
+	if resp := postLogin(t, ts, "last@example.com", "wrong"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected IP lockout, got %d", resp.StatusCode)
+	}
+	if resp := postLogin(t, ts, "someone@example.com", "password123"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected locked IP to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{4, 0},
+		{5, loginBaseLockout},
+		{6, 2 * loginBaseLockout},
+		{8, 8 * loginBaseLockout},
+		{50, loginMaxLockout},
+	}
+	for _, tc := range cases {
+		if got := lockoutDuration(tc.failures, loginAccountThreshold); got != tc.want {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
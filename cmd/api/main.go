@@ -7,6 +7,10 @@ import (
 	"os"
 	"rest-api-in-gin/internal/database"
 	"rest-api-in-gin/internal/env"
+	"rest-api-in-gin/internal/mailer"
+	"rest-api-in-gin/internal/oidc"
+	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -46,18 +50,47 @@ import (
 // @tag.description Manage attendees for events
 // @tag.name Health
 // @tag.description System health and monitoring endpoints
+// @tag.name Admin
+// @tag.description User management and audit log (admin role required)
 
 type application struct {
 	db        *sql.DB
 	port      int
 	jwtSecret string
+	keys      *keySet
 	models    database.Models
+	mailer    mailer.Mailer
+	// appURL is the frontend base URL used in links sent by email
+	appURL string
+	// requireVerifiedEmail blocks users without a verified email from
+	// creating events
+	requireVerifiedEmail bool
+	// oidcProviders are the external identity providers users can sign in
+	// with, keyed by name
+	oidcProviders map[string]*oidc.Provider
+	// waitlistOfferTTL is how long a user promoted from a waitlist has to
+	// confirm the seat; zero gives them the seat straight away
+	waitlistOfferTTL time.Duration
+	// deletedRetention is how long deleted events and users can be
+	// restored before they are purged
+	deletedRetention time.Duration
+	wg               sync.WaitGroup
 }
 
 func main() {
 	// Validate critical environment variables
+	production := env.GetEnvString("GIN_MODE", "") == "release"
+
+	keys, err := loadSigningKeys(production)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	jwtSecret := env.GetEnvString("JWT_Secret", "")
 	if jwtSecret == "" || jwtSecret == "some-very-secret-secret" {
+		if production {
+			log.Fatal("JWT_Secret must be set to a unique value in production (it signs pagination cursors)")
+		}
 		log.Println("WARNING: Using default JWT secret. Set JWT_Secret environment variable for production!")
 		jwtSecret = "some-very-secret-secret"
 	}
@@ -84,11 +117,24 @@ func main() {
 
 	models := database.NewModels(db)
 
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	app := &application{
 		db:        db,
 		port:      env.GetEnvInt("PORT", 8080),
 		jwtSecret: jwtSecret,
+		keys:      keys,
 		models:    models,
+		mailer:    newMailer(production),
+		appURL:    env.GetEnvString("APP_URL", "http://localhost:3000"),
+
+		requireVerifiedEmail: env.GetEnvString("REQUIRE_VERIFIED_EMAIL", "") == "1",
+		oidcProviders:        oidcProviders,
+		waitlistOfferTTL:     time.Duration(env.GetEnvInt("WAITLIST_CONFIRM_HOURS", 0)) * time.Hour,
+		deletedRetention:     time.Duration(env.GetEnvInt("DELETED_RETENTION_DAYS", 30)) * 24 * time.Hour,
 	}
 
 	err = app.server()
@@ -97,6 +143,81 @@ func main() {
 	}
 }
 
+// loadSigningKeys loads the asymmetric JWT keys from JWT_KEYS_DIR. Without a
+// key directory tokens fall back to HS256 with JWT_Secret, which is refused in
+// production.
+func loadSigningKeys(production bool) (*keySet, error) {
+	dir := env.GetEnvString("JWT_KEYS_DIR", "")
+	if dir == "" {
+		if production {
+			return nil, fmt.Errorf("JWT_KEYS_DIR must be set in production; refusing to sign tokens with a shared secret")
+		}
+		log.Println("WARNING: JWT_KEYS_DIR not set; signing tokens with HS256 and JWT_Secret (development only)")
+		return nil, nil
+	}
+
+	keys, err := loadKeySet(dir, env.GetEnvString("JWT_SIGNING_KEY_ID", ""))
+	if err != nil {
+		return nil, fmt.Errorf("loading JWT keys: %w", err)
+	}
+	log.Printf("Signing tokens with %s key %q (%d verification keys)", keys.signing.method.Alg(), keys.signing.kid, len(keys.keys))
+	return keys, nil
+}
+
+// loadOIDCProviders reads the identity providers listed in OIDC_PROVIDERS
+// (comma separated names). Each name NAME is configured through
+// OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET,
+// OIDC_NAME_REDIRECT_URL and optionally OIDC_NAME_SCOPES (space separated).
+// Discovery happens on first use so an unreachable provider does not stop the
+// server from starting.
+func loadOIDCProviders() (map[string]*oidc.Provider, error) {
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range strings.Split(env.GetEnvString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := oidc.Config{
+			Name:         name,
+			Issuer:       env.GetEnvString(prefix+"ISSUER", ""),
+			ClientID:     env.GetEnvString(prefix+"CLIENT_ID", ""),
+			ClientSecret: env.GetEnvString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  env.GetEnvString(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(env.GetEnvString(prefix+"SCOPES", "")),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER, %sCLIENT_ID and %sREDIRECT_URL", name, prefix, prefix, prefix)
+		}
+		providers[name] = oidc.NewProvider(cfg, nil)
+		log.Printf("OIDC login enabled for provider %q (%s)", name, cfg.Issuer)
+	}
+	return providers, nil
+}
+
+// newMailer returns an SMTP mailer when SMTP_HOST is set and otherwise a
+// development mailer that writes messages to MAIL_DIR or the log.
+func newMailer(production bool) mailer.Mailer {
+	from := env.GetEnvString("MAIL_FROM", "EventHub <no-reply@eventhub.local>")
+
+	host := env.GetEnvString("SMTP_HOST", "")
+	if host == "" {
+		if production {
+			log.Println("WARNING: SMTP_HOST not set; emails containing reset links will only be logged")
+		}
+		return &mailer.FileMailer{Dir: env.GetEnvString("MAIL_DIR", ""), From: from}
+	}
+
+	return &mailer.SMTPMailer{
+		Host:     host,
+		Port:     env.GetEnvInt("SMTP_PORT", 587),
+		Username: env.GetEnvString("SMTP_USERNAME", ""),
+		Password: env.GetEnvString("SMTP_PASSWORD", ""),
+		From:     from,
+	}
+}
+
 func runMigrationsIfNeeded(db *sql.DB) error {
 	force := os.Getenv("FORCE_MIGRATE") == "1"
 
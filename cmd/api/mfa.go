@@ -0,0 +1,571 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/totp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// mfaChallengeTTL bounds how long a password login may wait for its
+	// second factor.
+	mfaChallengeTTL = 5 * time.Minute
+	// tokenTypeMFAChallenge marks tokens that can only be exchanged at
+	// /auth/mfa/verify; jwtAuthMiddleware rejects any token with a typ claim.
+	tokenTypeMFAChallenge = "mfa_challenge"
+	// totpSkew is how many 30 second steps of clock drift are tolerated.
+	totpSkew           = 1
+	totpIssuer         = "EventHub"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+type mfaChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type mfaVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type mfaStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	Pending                bool `json:"pending"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// QRPayload is the exact text to encode into the QR code shown to the
+	// user.
+	QRPayload string `json:"qr_payload"`
+}
+
+type mfaCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type disableMFARequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type mfaPolicyRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// issueMFAChallenge signs the short-lived token a password login returns when
+// the account has two-factor authentication enabled.
+func (app *application) issueMFAChallenge(user *database.User) (string, error) {
+	now := time.Now()
+	return app.signToken(jwt.MapClaims{
+		"user_id":       user.ID,
+		"typ":           tokenTypeMFAChallenge,
+		"token_version": user.TokenVersion,
+		"jti":           uuid.New().String(),
+		"iat":           jwt.NewNumericDate(now),
+		"exp":           jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+	})
+}
+
+// @Summary Complete a two-factor login
+// @Description Exchange the mfa_token returned by login, together with a TOTP code or an unused recovery code, for an access token and a refresh token. Each challenge can be used once; failures count towards the login lockout.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param verification body mfaVerifyRequest true "Challenge token and code"
+// @Success 200 {object} loginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /api/v1/auth/mfa/verify [post]
+func (app *application) verifyMFA(c *gin.Context) {
+	var req mfaVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	token, err := app.parseToken(req.MFAToken)
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	typ, _ := claims["typ"].(string)
+	jti, _ := claims["jti"].(string)
+	uid, _ := claims["user_id"].(float64)
+	tokenVersion, _ := claims["token_version"].(float64)
+	if typ != tokenTypeMFAChallenge || jti == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	revoked, err := app.models.Tokens.IsRevoked(jti, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+		return
+	}
+	user, err := app.models.Users.Get(int(uid))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if revoked || user == nil || user.Disabled() || user.PasswordResetRequired || int(tokenVersion) != user.TokenVersion {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	if !app.checkLoginLock(c, user.Email) {
+		return
+	}
+
+	enrollment, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if enrollment == nil || !enrollment.Confirmed() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	ok, err := app.checkSecondFactor(enrollment, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Printf("verifyMFA: user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		app.recordLoginFailure(c, user.Email, user, "Invalid verification code")
+		return
+	}
+
+	if err := app.models.LoginThrottle.Clear(accountThrottleKey(user.Email)); err != nil {
+		log.Printf("verifyMFA: clear failed attempts: %v", err)
+	}
+	// The challenge is single-use
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	if exp, ok := claims["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+	if err := app.models.Tokens.RevokeAccessToken(jti, expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+		return
+	}
+
+	app.respondWithSession(c, user)
+}
+
+// checkSecondFactor verifies a TOTP code, or a recovery code if code is
+// empty, and consumes it so it cannot be used again.
+func (app *application) checkSecondFactor(enrollment *database.TOTPEnrollment, code, recoveryCode string) (bool, error) {
+	if code == "" {
+		return app.models.MFA.UseRecoveryCode(enrollment.UserID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	return app.models.MFA.UseTOTPStep(enrollment.UserID, step)
+}
+
+// @Summary Two-factor status
+// @Description Show whether the authenticated user has two-factor authentication enabled, whether their role requires it and how many recovery codes remain
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} mfaStatusResponse
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/mfa [get]
+func (app *application) getMFAStatus(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	enrollment, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA status"})
+		return
+	}
+	required, err := app.models.MFA.RoleRequiresMFA(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA status"})
+		return
+	}
+	remaining, err := app.models.MFA.RemainingRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mfaStatusResponse{
+		Enabled:                enrollment != nil && enrollment.Confirmed(),
+		Pending:                enrollment != nil && !enrollment.Confirmed(),
+		Required:               required,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the authenticated user. Two-factor authentication is not enabled until the enrollment is confirmed with a first code; starting again replaces an unconfirmed secret.
+// @Tags Auth
+// @Produce json
+// @Success 201 {object} totpEnrollmentResponse
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/mfa/totp [post]
+func (app *application) startTOTPEnrollment(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	started, err := app.models.MFA.StartTOTPEnrollment(user.ID, secret)
+	if err != nil {
+		log.Printf("startTOTPEnrollment: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+	if !started {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	uri := totp.ProvisioningURI(totpIssuer, user.Email, secret)
+	c.JSON(http.StatusCreated, totpEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRPayload:       uri,
+	})
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Enable two-factor authentication by submitting the current code from the authenticator app. Returns one-time recovery codes, which are shown only once.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param code body mfaCodeRequest true "Current TOTP code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/mfa/totp/confirm [post]
+func (app *application) confirmTOTPEnrollment(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	enrollment, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrollment"})
+		return
+	}
+	if enrollment == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No enrollment in progress"})
+		return
+	}
+	if enrollment.Confirmed() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := app.models.MFA.ConfirmTOTP(user.ID, step, hashes); err != nil {
+		log.Printf("confirmTOTPEnrollment: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes with a new set, which is shown only once. Requires a current TOTP code or an unused recovery code.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param code body mfaCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} recoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func (app *application) regenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, enrollment, ok := app.requireSecondFactor(c, req.Code, req.RecoveryCode)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := app.models.MFA.ReplaceRecoveryCodes(enrollment.UserID, hashes); err != nil {
+		log.Printf("regenerateRecoveryCodes: user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Remove the TOTP secret and recovery codes. Requires the password and a current TOTP code or an unused recovery code; refused while the user's role requires two-factor authentication.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param credentials body disableMFARequest true "Password and second factor"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/auth/mfa/totp [delete]
+func (app *application) disableMFA(c *gin.Context) {
+	var req disableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	required, err := app.models.MFA.RoleRequiresMFA(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role requires two-factor authentication"})
+		return
+	}
+
+	if _, _, ok := app.requireSecondFactor(c, req.Code, req.RecoveryCode); !ok {
+		return
+	}
+
+	if err := app.models.MFA.DeleteTOTP(user.ID); err != nil {
+		log.Printf("disableMFA: user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// requireSecondFactor checks a TOTP or recovery code for the authenticated
+// user, writing an error response and returning ok=false if the user has no
+// confirmed enrollment or the code is wrong. Wrong codes count against the
+// same login throttle as verifyMFA, so a stolen session cannot be used to
+// guess codes.
+func (app *application) requireSecondFactor(c *gin.Context, code, recoveryCode string) (*database.User, *database.TOTPEnrollment, bool) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, nil, false
+	}
+
+	enrollment, err := app.models.MFA.GetTOTP(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return nil, nil, false
+	}
+	if enrollment == nil || !enrollment.Confirmed() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return nil, nil, false
+	}
+	if !app.checkLoginLock(c, user.Email) {
+		return nil, nil, false
+	}
+
+	ok, err := app.checkSecondFactor(enrollment, code, recoveryCode)
+	if err != nil {
+		log.Printf("requireSecondFactor: user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return nil, nil, false
+	}
+	if !ok {
+		app.recordLoginFailure(c, user.Email, user, "Invalid verification code")
+		return nil, nil, false
+	}
+
+	if err := app.models.LoginThrottle.Clear(accountThrottleKey(user.Email)); err != nil {
+		log.Printf("requireSecondFactor: clear failed attempts: %v", err)
+	}
+	return user, enrollment, true
+}
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns a fresh set of recovery codes formatted for
+// display, and the hashes to store.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryCodeEncoding.EncodeToString(b)
+		codes = append(codes, raw[:recoveryCodeLength/2]+"-"+raw[recoveryCodeLength/2:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode undoes the display formatting so codes can be typed
+// in any case and with or without the separator.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// @Summary MFA policy
+// @Description List the roles whose members must use two-factor authentication (admin only)
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} map[string][]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/mfa-policy [get]
+func (app *application) adminGetMFAPolicy(c *gin.Context) {
+	roles, err := app.models.MFA.RequiredRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"required_roles": roles})
+}
+
+// @Summary Require MFA for a role
+// @Description Require or stop requiring two-factor authentication for every member of a role (admin only). Members without MFA are refused everything except enrollment until they enroll.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param role path string true "Role"
+// @Param policy body mfaPolicyRequest true "Whether MFA is required"
+// @Success 200 {object} map[string][]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/mfa-policy/{role} [put]
+func (app *application) adminSetMFAPolicy(c *gin.Context) {
+	var req mfaPolicyRequest
+	role := c.Param("role")
+	if err := c.ShouldBindJSON(&req); err != nil || !database.ValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := app.models.MFA.SetRoleRequiresMFA(role, *req.Required); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update MFA policy"})
+		return
+	}
+
+	if admin, err := app.getUserFromContext(c); err == nil {
+		if err := app.models.Audit.Insert(admin.ID, auditMFAPolicyChanged, nil, gin.H{"role": role, "required": *req.Required}); err != nil {
+			log.Printf("audit: failed to record %s by %d: %v", auditMFAPolicyChanged, admin.ID, err)
+		}
+	}
+
+	app.adminGetMFAPolicy(c)
+}
+
+// @Summary Reset a user's MFA
+// @Description Remove a user's TOTP secret and recovery codes, for example after a lost device, and revoke their sessions (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/mfa/reset [post]
+func (app *application) adminResetUserMFA(c *gin.Context) {
+	user, ok := app.loadTargetUser(c)
+	if !ok || !app.rejectSelfTarget(c, user) {
+		return
+	}
+
+	if err := app.models.MFA.DeleteTOTP(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset MFA"})
+		return
+	}
+	if err := app.models.Tokens.RevokeUserSessions(user.ID, database.RevokeReasonCredentialsChange); err != nil {
+		log.Printf("adminResetUserMFA: revoke sessions: %v", err)
+	}
+	app.audit(c, auditMFAReset, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA reset"})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/totp"
+)
+
+func TestMFAEnrollmentAndLogin(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := insertUserWithPassword(t, app, "mfa@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	token, _ := jwtForUser(app, u.ID)
+
+	code, body := api.do("POST", "/api/v1/auth/mfa/totp", token, nil)
+	var enrollment totpEnrollmentResponse
+	if code != http.StatusCreated || json.Unmarshal(body, &enrollment) != nil || enrollment.Secret == "" {
+		t.Fatalf("start enrollment: %d %s", code, body)
+	}
+	if !strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/EventHub:mfa@example.com?") || enrollment.QRPayload != enrollment.ProvisioningURI {
+		t.Fatalf("unexpected provisioning URI %q", enrollment.ProvisioningURI)
+	}
+
+	// Unconfirmed enrollments do not affect login
+	if resp := postLogin(t, ts, u.Email, "password123"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login before confirmation: expected 200, got %d", resp.StatusCode)
+	}
+
+	if code, body = api.do("POST", "/api/v1/auth/mfa/totp/confirm", token, map[string]string{"code": "000000"}); code != http.StatusBadRequest {
+		t.Fatalf("confirm with wrong code: %d %s", code, body)
+	}
+	current, _ := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	code, body = api.do("POST", "/api/v1/auth/mfa/totp/confirm", token, map[string]string{"code": current})
+	var recovery recoveryCodesResponse
+	if code != http.StatusOK || json.Unmarshal(body, &recovery) != nil || len(recovery.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("confirm: %d %s", code, body)
+	}
+
+	login := func() string {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": u.Email, "password": "password123"})
+		var challenge mfaChallengeResponse
+		if code != http.StatusAccepted || json.Unmarshal(body, &challenge) != nil || !challenge.MFARequired || challenge.MFAToken == "" {
+			t.Fatalf("login: expected MFA challenge, got %d %s", code, body)
+		}
+		return challenge.MFAToken
+	}
+
+	challenge := login()
+	if code, _ = api.do("GET", "/api/v1/auth/me", challenge, nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected challenge token to be refused as access token, got %d", code)
+	}
+
+	// The code used to confirm enrollment cannot be replayed
+	if code, body = api.do("POST", "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": challenge, "code": current}); code != http.StatusUnauthorized {
+		t.Fatalf("replayed code: %d %s", code, body)
+	}
+
+	// Use the next time step, which is within the allowed skew
+	next, _ := totp.Code(enrollment.Secret, totp.Step(time.Now())+1)
+	code, body = api.do("POST", "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": challenge, "code": next})
+	var session loginResponse
+	if code != http.StatusOK || json.Unmarshal(body, &session) != nil || session.Token == "" || !session.User.MFAEnabled {
+		t.Fatalf("verify: %d %s", code, body)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", session.Token, nil); code != http.StatusOK {
+		t.Fatalf("expected access token to work, got %d", code)
+	}
+
+	// Challenges are single-use
+	rc := recovery.RecoveryCodes[0]
+	if code, _ = api.do("POST", "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": challenge, "recovery_code": rc}); code != http.StatusUnauthorized {
+		t.Fatalf("expected used challenge to be rejected, got %d", code)
+	}
+
+	// Recovery codes work once, regardless of case and separator
+	typed := strings.ToUpper(strings.ReplaceAll(rc, "-", ""))
+	if code, body = api.do("POST", "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": login(), "recovery_code": typed}); code != http.StatusOK {
+		t.Fatalf("recovery code: %d %s", code, body)
+	}
+	if code, _ = api.do("POST", "/api/v1/auth/mfa/verify", "", map[string]string{"mfa_token": login(), "recovery_code": rc}); code != http.StatusUnauthorized {
+		t.Fatalf("expected used recovery code to be rejected, got %d", code)
+	}
+
+	code, body = api.do("GET", "/api/v1/auth/mfa", session.Token, nil)
+	var status mfaStatusResponse
+	if code != http.StatusOK || json.Unmarshal(body, &status) != nil || !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Fatalf("status: %d %s", code, body)
+	}
+
+	disable := map[string]string{"password": "password123", "recovery_code": recovery.RecoveryCodes[1]}
+	if code, body = api.do("DELETE", "/api/v1/auth/mfa/totp", session.Token, disable); code != http.StatusOK {
+		t.Fatalf("disable: %d %s", code, body)
+	}
+	if resp := postLogin(t, ts, u.Email, "password123"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login after disabling MFA: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestMFACodeAttemptsThrottled(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := insertUserWithPassword(t, app, "guess@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	token, _ := jwtForUser(app, u.ID)
+	code, body := api.do("POST", "/api/v1/auth/mfa/totp", token, nil)
+	var enrollment totpEnrollmentResponse
+	if code != http.StatusCreated || json.Unmarshal(body, &enrollment) != nil {
+		t.Fatalf("start enrollment: %d %s", code, body)
+	}
+	current, _ := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if code, body = api.do("POST", "/api/v1/auth/mfa/totp/confirm", token, map[string]string{"code": current}); code != http.StatusOK {
+		t.Fatalf("confirm: %d %s", code, body)
+	}
+
+	// Guessing codes with a session locks the account like failed logins do
+	for i := 1; i < loginAccountThreshold; i++ {
+		if code, _ = api.do("POST", "/api/v1/auth/mfa/recovery-codes", token, map[string]string{"recovery_code": "wrong-code"}); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, code)
+		}
+	}
+	disable := map[string]string{"password": "password123", "code": "000000"}
+	if code, _ = api.do("DELETE", "/api/v1/auth/mfa/totp", token, disable); code != http.StatusTooManyRequests {
+		t.Fatalf("expected lockout, got %d", code)
+	}
+	resp := postLogin(t, ts, u.Email, "password123")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("login while locked: expected 429, got %d", resp.StatusCode)
+	}
+	if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry < 1 {
+		t.Fatalf("unexpected Retry-After %q", resp.Header.Get("Retry-After"))
+	}
+	next, _ := totp.Code(enrollment.Secret, totp.Step(time.Now())+1)
+	if code, _ = api.do("POST", "/api/v1/auth/mfa/recovery-codes", token, map[string]string{"code": next}); code != http.StatusTooManyRequests {
+		t.Fatalf("valid code while locked: expected 429, got %d", code)
+	}
+}
+
+func TestMFARequiredForRole(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	if err := app.models.Users.Insert(admin); err != nil {
+		t.Fatalf("insert admin: %v", err)
+	}
+	u := insertUserWithPassword(t, app, "member@example.com", "password123")
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	adminToken, _ := jwtForUser(app, admin.ID)
+	userToken, _ := jwtForUser(app, u.ID)
+
+	code, body := api.do("PUT", "/api/v1/admin/mfa-policy/user", adminToken, map[string]bool{"required": true})
+	if code != http.StatusOK || !strings.Contains(string(body), `"required_roles":["user"]`) {
+		t.Fatalf("set policy: %d %s", code, body)
+	}
+
+	if code, body = api.do("POST", "/api/v1/events", userToken, map[string]string{"title": "Blocked"}); code != http.StatusForbidden || !strings.Contains(string(body), "mfa enrollment required") {
+		t.Fatalf("expected unenrolled user to be refused, got %d %s", code, body)
+	}
+	if code, _ = api.do("GET", "/api/v1/auth/me", userToken, nil); code != http.StatusOK {
+		t.Fatalf("expected /auth/me to stay reachable, got %d", code)
+	}
+
+	code, body = api.do("POST", "/api/v1/auth/mfa/totp", userToken, nil)
+	var enrollment totpEnrollmentResponse
+	if code != http.StatusCreated || json.Unmarshal(body, &enrollment) != nil {
+		t.Fatalf("start enrollment: %d %s", code, body)
+	}
+	current, _ := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	code, body = api.do("POST", "/api/v1/auth/mfa/totp/confirm", userToken, map[string]string{"code": current})
+	var recovery recoveryCodesResponse
+	if code != http.StatusOK || json.Unmarshal(body, &recovery) != nil {
+		t.Fatalf("confirm: %d %s", code, body)
+	}
+
+	if code, _ = api.do("GET", "/api/v1/events/1/attendees", userToken, nil); code == http.StatusForbidden {
+		t.Fatalf("expected enrolled user to pass the MFA policy")
+	}
+	disable := map[string]string{"password": "password123", "recovery_code": recovery.RecoveryCodes[0]}
+	if code, _ = api.do("DELETE", "/api/v1/auth/mfa/totp", userToken, disable); code != http.StatusForbidden {
+		t.Fatalf("expected disabling required MFA to be refused, got %d", code)
+	}
+
+	if code, body = api.do("POST", "/api/v1/admin/users/"+strconv.Itoa(u.ID)+"/mfa/reset", adminToken, nil); code != http.StatusOK {
+		t.Fatalf("admin reset: %d %s", code, body)
+	}
+	if code, _ = api.do("POST", "/api/v1/events", userToken, map[string]string{"title": "Blocked"}); code != http.StatusForbidden {
+		t.Fatalf("expected user to need enrollment again after reset, got %d", code)
+	}
+}
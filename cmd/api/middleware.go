@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"rest-api-in-gin/internal/database"
 	"strings"
 	"sync"
 	"time"
@@ -27,89 +28,186 @@ func (app *application) jwtAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Parse Bearer token
+		// Parse Bearer token or API key
 		parts := strings.SplitN(auth, " ", 2)
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "apikey" {
+			app.authenticateAPIKey(c, parts[1])
+			return
+		}
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "invalid Authorization header format",
-				"message": "Authorization header must be in format: Bearer <token>",
+				"message": "Authorization header must be in format: Bearer <token> or ApiKey <key>",
 			})
 			return
 		}
-		tokenString := parts[1]
+		app.authenticateBearer(c, parts[1], c.AbortWithStatusJSON)
+	}
+}
 
-		// Validate token signature and expiration
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-			}
-			return []byte(app.jwtSecret), nil
+// authenticateBearer validates a JWT access token and loads its user into
+// the context. Failures are passed to reject, which responds to them.
+func (app *application) authenticateBearer(c *gin.Context, tokenString string, reject func(code int, obj any)) {
+	// Validate token signature and expiration
+	token, err := app.parseToken(tokenString)
+
+	if err != nil {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token",
+			"message": "Token is invalid or expired",
 		})
+		return
+	}
+
+	if !token.Valid {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token",
+			"message": "Token validation failed",
+		})
+		return
+	}
 
+	// Extract claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token claims",
+			"message": "Unable to parse token claims",
+		})
+		return
+	}
+
+	// Special-purpose tokens such as MFA challenges are not access tokens
+	if typ, _ := claims["typ"].(string); typ != "" {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token",
+			"message": "Token cannot be used for API access",
+		})
+		return
+	}
+
+	// Extract user ID
+	uidFloat, ok := claims["user_id"].(float64)
+	if !ok {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token claims",
+			"message": "Token does not contain valid user_id",
+		})
+		return
+	}
+	userID := int(uidFloat)
+
+	// Load user from database
+	user, err := app.models.Users.Get(userID)
+	if err != nil {
+		log.Printf("Error loading user %d: %v", userID, err)
+		reject(http.StatusInternalServerError, gin.H{
+			"error":   "failed to load user",
+			"message": "An error occurred while validating your account",
+		})
+		return
+	}
+
+	if user == nil {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "user not found",
+			"message": "The user associated with this token no longer exists",
+		})
+		return
+	}
+
+	// Reject tokens revoked by logout or whose session was revoked
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	if jti != "" || sid != "" {
+		revoked, err := app.models.Tokens.IsRevoked(jti, sid)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error":   "invalid token",
-				"message": "Token is invalid or expired",
+			log.Printf("Error checking token revocation for user %d: %v", userID, err)
+			reject(http.StatusInternalServerError, gin.H{
+				"error":   "failed to validate token",
+				"message": "An error occurred while validating your token",
 			})
 			return
 		}
-
-		if !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		if revoked {
+			reject(http.StatusUnauthorized, gin.H{
 				"error":   "invalid token",
-				"message": "Token validation failed",
+				"message": "Token has been revoked, please log in again",
 			})
 			return
 		}
+	}
 
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error":   "invalid token claims",
-				"message": "Unable to parse token claims",
-			})
-			return
-		}
+	// Tokens issued before the last email or password change are stale
+	tokenVersion, _ := claims["token_version"].(float64)
+	if int(tokenVersion) != user.TokenVersion {
+		reject(http.StatusUnauthorized, gin.H{
+			"error":   "invalid token",
+			"message": "Token has been revoked, please log in again",
+		})
+		return
+	}
 
-		// Extract user ID
-		uidFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error":   "invalid token claims",
-				"message": "Token does not contain valid user_id",
-			})
-			return
-		}
-		userID := int(uidFloat)
+	// Disabling an account or forcing a password reset revokes every
+	// outstanding token immediately.
+	if user.Disabled() {
+		reject(http.StatusForbidden, gin.H{
+			"error":   "account disabled",
+			"message": "This account has been disabled by an administrator",
+		})
+		return
+	}
+	if user.PasswordResetRequired {
+		reject(http.StatusForbidden, gin.H{
+			"error":   "password reset required",
+			"message": "An administrator requires you to reset your password",
+		})
+		return
+	}
 
-		// Load user from database
-		user, err := app.models.Users.Get(userID)
-		if err != nil {
-			log.Printf("Error loading user %d: %v", userID, err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error":   "failed to load user",
-				"message": "An error occurred while validating your account",
-			})
+	// Store user in context for downstream handlers
+	c.Set("user", user)
+	c.Set("user_id", userID)
+	c.Set("token_jti", jti)
+	c.Set("token_sid", sid)
+	if exp, ok := claims["exp"].(float64); ok {
+		c.Set("token_exp", time.Unix(int64(exp), 0))
+	}
+	c.Next()
+}
+
+// optionalAuth authenticates requests carrying credentials like
+// jwtAuthMiddleware and lets anonymous ones through, so public routes can
+// tailor their response to the caller. An access token that is expired,
+// revoked or otherwise refused gets the anonymous view rather than an
+// error, so clients holding on to a stale token can still browse.
+func (app *application) optionalAuth() gin.HandlerFunc {
+	authenticate := app.jwtAuthMiddleware()
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if auth == "" {
+			c.Next()
 			return
 		}
-
-		if user == nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error":   "user not found",
-				"message": "The user associated with this token no longer exists",
-			})
+		scheme, token, _ := strings.Cut(auth, " ")
+		if strings.ToLower(scheme) != "bearer" {
+			authenticate(c)
 			return
 		}
-
-		// Store user in context for downstream handlers
-		c.Set("user", user)
-		c.Set("user_id", userID)
-		c.Next()
+		app.authenticateBearer(c, token, func(code int, obj any) {
+			if code >= http.StatusInternalServerError {
+				c.AbortWithStatusJSON(code, obj)
+				return
+			}
+			c.Next()
+		})
 	}
 }
 
+// requireOwnerOrAdmin lets only the user with ownerID and admins through.
+// Event routes check team roles with requireEventPermission instead; this is
+// for resources without a team, such as venues, which only their creator
+// manages.
 func (app *application) requireOwnerOrAdmin(c *gin.Context, ownerID int) bool {
 	u, err := app.getUserFromContext(c)
 	if err != nil {
@@ -119,15 +217,71 @@ func (app *application) requireOwnerOrAdmin(c *gin.Context, ownerID int) bool {
 	if u.ID == ownerID {
 		return true
 	}
-	if u.Role == "admin" {
+	if u.Role == database.RoleAdmin {
 		return true
 	}
 	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 	return false
 }
 
-func requireOwnerOrAdmin(app *application, c *gin.Context, ownerID int) bool {
-	return app.requireOwnerOrAdmin(c, ownerID)
+// requireRole only lets through authenticated users holding one of roles.
+// It must run after jwtAuthMiddleware.
+func (app *application) requireRole(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		allowed[r] = true
+	}
+
+	return func(c *gin.Context) {
+		u, err := app.getUserFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if !allowed[u.Role] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "Your role does not allow access to this resource",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireMFAEnrollment refuses users whose role requires two-factor
+// authentication until they have enrolled. It must run after
+// jwtAuthMiddleware; enrollment routes are registered without it.
+func (app *application) requireMFAEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, err := app.getUserFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if u.MFAEnabled {
+			c.Next()
+			return
+		}
+
+		required, err := app.models.MFA.RoleRequiresMFA(u.Role)
+		if err != nil {
+			log.Printf("Error checking MFA policy for role %s: %v", u.Role, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "failed to check MFA policy",
+				"message": "An error occurred while validating your account",
+			})
+			return
+		}
+		if required {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "mfa enrollment required",
+				"message": "Your role requires two-factor authentication; enroll at /api/v1/auth/mfa/totp",
+			})
+			return
+		}
+		c.Next()
+	}
 }
 
 // corsMiddleware handles Cross-Origin Resource Sharing (CORS) with configurable origins
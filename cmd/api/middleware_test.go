@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	user := &database.User{Email: "user@example.com", Name: "User", Password: "x"}
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x"}
+	for _, u := range []*database.User{user, admin} {
+		if err := app.models.Users.Insert(u); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+	if ok, err := app.models.Users.UpdateRole(admin.ID, database.RoleAdmin); err != nil || !ok {
+		t.Fatalf("promote admin: ok=%v err=%v", ok, err)
+	}
+
+	loaded, err := app.models.Users.Get(admin.ID)
+	if err != nil || loaded.Role != database.RoleAdmin {
+		t.Fatalf("expected persisted admin role, got %+v (err=%v)", loaded, err)
+	}
+
+	g := gin.New()
+	g.GET("/admin-only", app.jwtAuthMiddleware(), app.requireRole(database.RoleAdmin), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, tc := range []struct {
+		userID int
+		want   int
+	}{
+		{user.ID, http.StatusForbidden},
+		{admin.ID, http.StatusNoContent},
+	} {
+		token, _ := jwtForUser(app, tc.userID)
+		req := httptest.NewRequest("GET", "/admin-only", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("user %d: expected %d, got %d", tc.userID, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestAdminCanRemoveAnyAttendee(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	owner := &database.User{Email: "owner@example.com", Name: "Owner", Password: "x"}
+	attendee := &database.User{Email: "att@example.com", Name: "Att", Password: "x"}
+	other := &database.User{Email: "other@example.com", Name: "Other", Password: "x"}
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	for _, u := range []*database.User{owner, attendee, other, admin} {
+		if err := app.models.Users.Insert(u); err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+	ev := &database.Event{User_id: owner.ID, Title: "Event", Description: "Role test event", StartTime: "2025-01-01T10:00:00Z", EndTime: "2025-01-01T11:00:00Z"}
+	if err := app.models.Events.Insert(ev); err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+	if _, err := app.models.Attendees.Insert(&database.Attendee{EventID: ev.ID, UserID: attendee.ID}); err != nil {
+		t.Fatalf("insert attendee: %v", err)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	remove := func(asUser int) int {
+		token, _ := jwtForUser(app, asUser)
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/events/%d/attendees/%d", ts.URL, ev.ID, attendee.ID), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("delete attendee: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := remove(other.ID); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for unrelated user, got %d", code)
+	}
+	if code := remove(admin.ID); code != http.StatusOK {
+		t.Fatalf("expected 200 for admin, got %d", code)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestNearbyEvents(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	token, _ := jwtForUser(app, owner.ID)
+
+	venueAt := func(name string, lat, lng float64) int {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/venues", token, map[string]interface{}{"name": name, "address": name, "latitude": lat, "longitude": lng})
+		var v database.Venue
+		if code != http.StatusCreated || json.Unmarshal(body, &v) != nil {
+			t.Fatalf("create venue %s: %d %s", name, code, body)
+		}
+		return v.ID
+	}
+	eventAt := func(title string, venueID int, extra map[string]interface{}) {
+		t.Helper()
+		event := map[string]interface{}{"title": title, "description": "An event somewhere nice", "start_time": "2030-06-01T10:00:00Z", "end_time": "2030-06-01T12:00:00Z", "venue_id": venueID}
+		for k, v := range extra {
+			event[k] = v
+		}
+		if code, body := api.do("POST", "/api/v1/events", token, event); code != http.StatusCreated {
+			t.Fatalf("create event %s: %d %s", title, code, body)
+		}
+	}
+
+	eventAt("Cape Town meetup", venueAt("Cape Town", -33.9249, 18.4241), nil)
+	eventAt("Wine tasting", venueAt("Stellenbosch", -33.9321, 18.8602), map[string]interface{}{"tags": []string{"wine"}})
+	eventAt("Johannesburg meetup", venueAt("Johannesburg", -26.2041, 28.0473), nil)
+	eventAt("Secret dinner", venueAt("Sea Point", -33.9155, 18.3894), map[string]interface{}{"visibility": "private"})
+	eventAt("Taveuni east", venueAt("Taveuni east", -16.8, 179.95), nil)
+	eventAt("Taveuni west", venueAt("Taveuni west", -16.8, -179.95), nil)
+	// Events without a venue have no location
+	if code, _ := api.do("POST", "/api/v1/events", token, map[string]interface{}{"title": "Anywhere", "description": "No venue at all", "start_time": "2030-06-01T10:00:00Z", "end_time": "2030-06-01T12:00:00Z"}); code != http.StatusCreated {
+		t.Fatalf("create event without venue: %d", code)
+	}
+
+	for _, q := range []string{"", "?lat=-33.9", "?lat=91&lng=18", "?lat=-33.9&lng=18.4&radius_km=0", "?lat=-33.9&lng=18.4&radius_km=1000"} {
+		if code, _ := api.do("GET", "/api/v1/events/nearby"+q, "", nil); code != http.StatusBadRequest {
+			t.Fatalf("nearby%s: expected 400, got %d", q, code)
+		}
+	}
+
+	var next, prev *string
+	nearby := func(query, token string) ([]database.EventNearbyResult, int) {
+		t.Helper()
+		code, body := api.do("GET", "/api/v1/events/nearby?"+query, token, nil)
+		var resp struct {
+			Data       []database.EventNearbyResult `json:"data"`
+			Pagination struct {
+				Total      int     `json:"total"`
+				NextCursor *string `json:"next_cursor"`
+				PrevCursor *string `json:"prev_cursor"`
+			} `json:"pagination"`
+		}
+		if code != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("nearby?%s: %d %s", query, code, body)
+		}
+		next, prev = resp.Pagination.NextCursor, resp.Pagination.PrevCursor
+		return resp.Data, resp.Pagination.Total
+	}
+	titles := func(results []database.EventNearbyResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Title)
+		}
+		return out
+	}
+
+	// Nearest first, with distances; private events stay hidden
+	results, total := nearby("lat=-33.9249&lng=18.4241&radius_km=50", "")
+	if total != 2 || len(results) != 2 || results[0].Title != "Cape Town meetup" || results[1].Title != "Wine tasting" {
+		t.Fatalf("anonymous nearby: %d %v", total, titles(results))
+	}
+	if results[0].DistanceKm != 0 || results[1].DistanceKm < 39 || results[1].DistanceKm > 42 {
+		t.Fatalf("distances: %v, %v", results[0].DistanceKm, results[1].DistanceKm)
+	}
+	if results[1].Location != "Stellenbosch, Stellenbosch" {
+		t.Fatalf("location: %q", results[1].Location)
+	}
+
+	results, total = nearby("lat=-33.9249&lng=18.4241&radius_km=50", token)
+	if total != 3 || results[1].Title != "Secret dinner" {
+		t.Fatalf("owner nearby: %d %v", total, titles(results))
+	}
+
+	// The radius is exact, not the bounding box
+	if results, total = nearby("lat=-33.9249&lng=18.4241&radius_km=5", token); total != 2 {
+		t.Fatalf("5 km radius: %d %v", total, titles(results))
+	}
+
+	// Combines with search and pagination
+	if results, total = nearby("lat=-33.9249&lng=18.4241&radius_km=50&search=wine", ""); total != 1 || results[0].Title != "Wine tasting" {
+		t.Fatalf("nearby search: %d %v", total, titles(results))
+	}
+	if results, total = nearby("lat=-33.9249&lng=18.4241&radius_km=50&limit=1&page=2", ""); total != 2 || len(results) != 1 || results[0].Title != "Wine tasting" {
+		t.Fatalf("nearby page 2: %d %v", total, titles(results))
+	}
+	if results, _ = nearby("lat=-33.9249&lng=18.4241&radius_km=50&page=9", ""); len(results) != 0 {
+		t.Fatalf("nearby past the last page: %v", titles(results))
+	}
+
+	// and with the tag filters and cursors of the event listing
+	if results, total = nearby("lat=-33.9249&lng=18.4241&radius_km=50&tags=wine", ""); total != 1 || results[0].Title != "Wine tasting" {
+		t.Fatalf("nearby by tag: %d %v", total, titles(results))
+	}
+	if results, _ = nearby("lat=-33.9249&lng=18.4241&radius_km=50&limit=1", token); len(results) != 1 || results[0].Title != "Cape Town meetup" || next == nil {
+		t.Fatalf("nearby first page: %v", titles(results))
+	}
+	if results, _ = nearby("lat=-33.9249&lng=18.4241&radius_km=50&limit=1&cursor="+*next, token); len(results) != 1 || results[0].Title != "Secret dinner" || next == nil || prev == nil {
+		t.Fatalf("nearby second page: %v", titles(results))
+	}
+	if results, _ = nearby("lat=-33.9249&lng=18.4241&radius_km=50&limit=1&cursor="+*next, token); len(results) != 1 || results[0].Title != "Wine tasting" || next != nil || prev == nil {
+		t.Fatalf("nearby last page: %v", titles(results))
+	}
+	if results, _ = nearby("lat=-33.9249&lng=18.4241&radius_km=50&limit=1&cursor="+*prev, token); len(results) != 1 || results[0].Title != "Secret dinner" {
+		t.Fatalf("nearby page back: %v", titles(results))
+	}
+	if code, _ := api.do("GET", "/api/v1/events/nearby?lat=-33.9249&lng=18.4241&cursor=bogus", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("nearby with invalid cursor: expected 400, got %d", code)
+	}
+
+	// Circles crossing the antimeridian find venues on both sides
+	if results, total = nearby("lat=-16.8&lng=179.99&radius_km=20", ""); total != 2 || results[0].Title != "Taveuni east" {
+		t.Fatalf("antimeridian: %d %v", total, titles(results))
+	}
+}
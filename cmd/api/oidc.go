@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/oidc"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcLoginTTL bounds how long a user may take at the identity provider.
+const oidcLoginTTL = 10 * time.Minute
+
+var errIdentityEmailUnverified = errors.New("identity provider did not supply a verified email")
+
+// @Summary List identity providers
+// @Description Names of the configured OpenID Connect providers users can sign in with
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string][]string
+// @Router /api/v1/auth/oidc/providers [get]
+func (app *application) listOIDCProviders(c *gin.Context) {
+	names := make([]string, 0, len(app.oidcProviders))
+	for name := range app.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// @Summary Start an identity provider login
+// @Description Redirect to the identity provider's authorization endpoint using the authorization code flow with PKCE. The login must be completed within 10 minutes.
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (app *application) oidcLogin(c *gin.Context) {
+	provider, ok := app.oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		log.Printf("oidcLogin: %s: %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	loginState := &database.LoginState{Provider: provider.Name(), Nonce: nonce, CodeVerifier: verifier}
+	if err := app.models.Identities.CreateLoginState(hashToken(state), loginState, time.Now().Add(oidcLoginTTL)); err != nil {
+		log.Printf("oidcLogin: store state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Complete an identity provider login
+// @Description Redirect target of the identity provider. Exchanges the authorization code, validates the ID token and signs in the linked user. A user is linked by a verified email address or created on first login. Returns the same response as a password login.
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login request"
+// @Success 200 {object} loginResponse
+// @Success 202 {object} mfaChallengeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (app *application) oidcCallback(c *gin.Context) {
+	provider, ok := app.oidcProvider(c)
+	if !ok {
+		return
+	}
+
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login was denied by the identity provider", "reason": e})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing code or state"})
+		return
+	}
+
+	loginState, err := app.models.Identities.ConsumeLoginState(hashToken(state), provider.Name())
+	if err != nil {
+		if err == database.ErrLoginStateInvalid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login state"})
+			return
+		}
+		log.Printf("oidcCallback: consume state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	rawIDToken, err := provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("oidcCallback: %s: %v", provider.Name(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to exchange authorization code"})
+		return
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		log.Printf("oidcCallback: %s: %v", provider.Name(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
+
+	user, err := app.userForIdentity(provider.Name(), claims)
+	if err != nil {
+		if err == errIdentityEmailUnverified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The identity provider did not supply a verified email address"})
+			return
+		}
+		log.Printf("oidcCallback: link %s identity: %v", provider.Name(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	app.completeLogin(c, user)
+}
+
+// userForIdentity returns the user linked to an identity provider account.
+// Unlinked accounts are linked to the user with the same verified email
+// address, or to a new user with no password.
+func (app *application) userForIdentity(provider string, claims *oidc.Claims) (*database.User, error) {
+	user, err := app.models.Identities.GetUser(provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		if claims.Email == "" || !claims.EmailVerified {
+			return nil, errIdentityEmailUnverified
+		}
+
+		user, err = app.models.Users.GetByEmail(claims.Email)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			name := claims.Name
+			if len(name) < 2 {
+				name = strings.SplitN(claims.Email, "@", 2)[0]
+			}
+			// An empty hash never matches, so password login stays
+			// impossible until the user sets one through a reset
+			user = &database.User{Email: claims.Email, Name: name}
+			if err := app.models.Users.Insert(user); err != nil {
+				return nil, err
+			}
+		}
+		if !user.EmailVerified() {
+			if _, err := app.models.Users.MarkEmailVerified(user.ID); err != nil {
+				return nil, err
+			}
+			if user, err = app.models.Users.Get(user.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := app.models.Identities.Link(user.ID, provider, claims.Subject, claims.Email); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// oidcProvider returns the provider named by the :provider path parameter,
+// writing a 404 response if it is not configured.
+func (app *application) oidcProvider(c *gin.Context) (*oidc.Provider, bool) {
+	provider, ok := app.oidcProviders[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return nil, false
+	}
+	return provider, true
+}
+
+// @Summary Linked identities
+// @Description List the external identity provider accounts linked to the authenticated user
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} database.Identity
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/auth/me/identities [get]
+func (app *application) listCurrentUserIdentities(c *gin.Context) {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	identities, err := app.models.Identities.ListForUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve identities"})
+		return
+	}
+	if identities == nil {
+		identities = []*database.Identity{}
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
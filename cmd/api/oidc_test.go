@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockOIDCServer is a minimal OpenID Connect provider. It signs in whoever
+// is set as the next user without asking for credentials.
+type mockOIDCServer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	user    jwt.MapClaims
+	pending map[string]url.Values // authorization code -> authorize request
+	// signWith, if set, signs ID tokens with a key missing from the JWKS
+	signWith *rsa.PrivateKey
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m := &mockOIDCServer{t: t, key: key, pending: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "mock-key", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "eventhub" {
+			http.Error(w, "bad authorize request", http.StatusBadRequest)
+			return
+		}
+		code, _ := oidc.RandomString()
+		m.mu.Lock()
+		m.pending[code] = q
+		m.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		authz, ok := m.pending[r.PostForm.Get("code")]
+		delete(m.pending, r.PostForm.Get("code"))
+		claims := jwt.MapClaims{}
+		for k, v := range m.user {
+			claims[k] = v
+		}
+		signer := m.key
+		if m.signWith != nil {
+			signer = m.signWith
+		}
+		m.mu.Unlock()
+
+		id, secret, _ := r.BasicAuth()
+		if !ok || id != "eventhub" || secret != "s3cret" || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != authz.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims["iss"] = m.URL
+		claims["aud"] = "eventhub"
+		claims["nonce"] = authz.Get("nonce")
+		claims["iat"] = time.Now().Unix()
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "mock-key"
+		idToken, _ := token.SignedString(signer)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockOIDCServer) setUser(claims jwt.MapClaims) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.user = claims
+}
+
+func TestOIDCLogin(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	idp := newMockOIDCServer(t)
+	defer idp.Close()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	app.oidcProviders = map[string]*oidc.Provider{
+		"corp": oidc.NewProvider(oidc.Config{
+			Name:         "corp",
+			Issuer:       idp.URL,
+			ClientID:     "eventhub",
+			ClientSecret: "s3cret",
+			RedirectURL:  ts.URL + "/api/v1/auth/oidc/corp/callback",
+		}, nil),
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	follow := func(u string) string {
+		t.Helper()
+		resp, err := noRedirect.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s: %v", u, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("GET %s: expected redirect, got %d", u, resp.StatusCode)
+		}
+		return resp.Header.Get("Location")
+	}
+	// login runs the browser side of the flow and returns the callback URL
+	login := func() string {
+		t.Helper()
+		return follow(follow(ts.URL + "/api/v1/auth/oidc/corp/login"))
+	}
+	callback := func(u string) (int, []byte) {
+		t.Helper()
+		parsed, _ := url.Parse(u)
+		return api.do("GET", parsed.RequestURI(), "", nil)
+	}
+
+	if code, body := api.do("GET", "/api/v1/auth/oidc/providers", "", nil); code != http.StatusOK || string(body) != `{"providers":["corp"]}` {
+		t.Fatalf("providers: %d %s", code, body)
+	}
+
+	// First login creates a user
+	idp.setUser(jwt.MapClaims{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true, "name": "Alice"})
+	cb := login()
+	code, body := callback(cb)
+	var first loginResponse
+	if code != http.StatusOK || json.Unmarshal(body, &first) != nil || first.Token == "" || first.User.Email != "alice@corp.example" || !first.User.EmailVerified() {
+		t.Fatalf("first login: %d %s", code, body)
+	}
+
+	// A state can only be used once
+	if code, body = callback(cb); code != http.StatusBadRequest {
+		t.Fatalf("replayed callback: %d %s", code, body)
+	}
+
+	// The identity stays linked even if the email changes at the provider
+	idp.setUser(jwt.MapClaims{"sub": "alice-1", "email": "alice.renamed@corp.example", "email_verified": true})
+	code, body = callback(login())
+	var second loginResponse
+	if code != http.StatusOK || json.Unmarshal(body, &second) != nil || second.User.ID != first.User.ID {
+		t.Fatalf("second login: %d %s", code, body)
+	}
+
+	code, body = api.do("GET", "/api/v1/auth/me/identities", second.Token, nil)
+	var identities []map[string]interface{}
+	if code != http.StatusOK || json.Unmarshal(body, &identities) != nil || len(identities) != 1 || identities[0]["provider"] != "corp" {
+		t.Fatalf("identities: %d %s", code, body)
+	}
+
+	// Existing accounts are linked by verified email
+	existing := insertUserWithPassword(t, app, "bob@corp.example", "password123")
+	idp.setUser(jwt.MapClaims{"sub": "bob-1", "email": "bob@corp.example", "email_verified": true})
+	code, body = callback(login())
+	var linked loginResponse
+	if code != http.StatusOK || json.Unmarshal(body, &linked) != nil || linked.User.ID != existing.ID {
+		t.Fatalf("link by email: %d %s", code, body)
+	}
+
+	// Unverified emails are not trusted for linking or sign-up
+	idp.setUser(jwt.MapClaims{"sub": "mallory-1", "email": "bob@corp.example", "email_verified": false})
+	if code, body = callback(login()); code != http.StatusForbidden {
+		t.Fatalf("unverified email: %d %s", code, body)
+	}
+
+	// Signing in after the account was deleted moves the identity to a new
+	// account, which keeps it when the old one cannot be restored
+	if _, err := app.models.Users.Delete(existing.ID); err != nil {
+		t.Fatalf("delete bob: %v", err)
+	}
+	idp.setUser(jwt.MapClaims{"sub": "bob-1", "email": "bob@corp.example", "email_verified": true})
+	code, body = callback(login())
+	var newcomer loginResponse
+	if code != http.StatusOK || json.Unmarshal(body, &newcomer) != nil || newcomer.User.ID == existing.ID {
+		t.Fatalf("login after deletion: %d %s", code, body)
+	}
+	code, body = api.do("GET", "/api/v1/auth/me/identities", newcomer.Token, nil)
+	if code != http.StatusOK || json.Unmarshal(body, &identities) != nil || len(identities) != 1 || identities[0]["subject"] != "bob-1" {
+		t.Fatalf("identities of the new account: %d %s", code, body)
+	}
+	if _, err := app.models.Users.Restore(existing.ID); err != database.ErrEmailTaken {
+		t.Fatalf("restore bob: expected ErrEmailTaken, got %v", err)
+	}
+	code, body = callback(login())
+	if code != http.StatusOK || json.Unmarshal(body, &linked) != nil || linked.User.ID != newcomer.User.ID {
+		t.Fatalf("login after the move: %d %s", code, body)
+	}
+
+	// ID tokens must be signed by a key the provider publishes
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	idp.mu.Lock()
+	idp.signWith = other
+	idp.mu.Unlock()
+	idp.setUser(jwt.MapClaims{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true})
+	if code, body = callback(login()); code != http.StatusUnauthorized {
+		t.Fatalf("forged id token: %d %s", code, body)
+	}
+
+	if code, _ = api.do("GET", "/api/v1/auth/oidc/unknown/login", "", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown provider: expected 404, got %d", code)
+	}
+}
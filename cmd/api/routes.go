@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"rest-api-in-gin/internal/database"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +29,7 @@ func (app *application) routes() *gin.Engine {
 	// Health and monitoring endpoints (no rate limiting)
 	g.GET("/health", app.healthCheck)
 	g.GET("/version", app.versionInfo)
+	g.GET("/.well-known/jwks.json", app.jwksHandler)
 
 	g.GET("/events", func(c *gin.Context) {
 		c.Redirect(http.StatusPermanentRedirect, "/api/v1/events")
@@ -36,24 +38,132 @@ func (app *application) routes() *gin.Engine {
 	// Public routes
 	public := g.Group("/api/v1")
 	{
-		public.GET("/events", app.getAllEvets)
-		public.GET("/events/:id", app.getEvent)
-
 		public.POST("/auth/register", app.createUser)
 		public.POST("/auth/login", app.loginUser)
+		public.POST("/auth/refresh", app.refreshToken)
+		public.POST("/auth/password/forgot", app.forgotPassword)
+		public.POST("/auth/password/reset", app.resetPassword)
+		public.POST("/auth/verify-email", app.verifyEmail)
+		public.POST("/auth/mfa/verify", app.verifyMFA)
+		public.GET("/auth/oidc/providers", app.listOIDCProviders)
+		public.GET("/auth/oidc/:provider/login", app.oidcLogin)
+		public.GET("/auth/oidc/:provider/callback", app.oidcCallback)
+	}
+
+	// Public event pages also show private events to signed-in invitees
+	browse := g.Group("/api/v1")
+	browse.Use(app.optionalAuth())
+	{
+		browse.GET("/events", app.getAllEvets)
+		browse.GET("/events/search", app.searchEvents)
+		browse.GET("/events/nearby", app.nearbyEvents)
+		browse.GET("/events/:id", app.getEvent)
+		browse.GET("/categories", app.listCategories)
+		browse.GET("/venues", app.listVenues)
+		browse.GET("/venues/:id", app.getVenue)
+		browse.GET("/venues/:id/availability", app.getVenueAvailability)
+	}
+
+	// Routes available to users who still have to enroll in MFA
+	enroll := g.Group("/api/v1")
+	enroll.Use(app.jwtAuthMiddleware())
+	{
+		enroll.GET("/auth/me", app.getCurrentUser)
+	}
+
+	enrollSession := enroll.Group("")
+	enrollSession.Use(app.sessionOnly())
+	{
+		enrollSession.POST("/auth/logout", app.logout)
+		enrollSession.GET("/auth/mfa", app.getMFAStatus)
+		enrollSession.POST("/auth/mfa/totp", app.startTOTPEnrollment)
+		enrollSession.POST("/auth/mfa/totp/confirm", app.confirmTOTPEnrollment)
+	}
+
+	auth := enroll.Group("")
+	auth.Use(app.requireMFAEnrollment())
+
+	// Account management is never available to API keys
+	account := auth.Group("")
+	account.Use(app.sessionOnly())
+	{
+		account.PATCH("/auth/me", app.updateCurrentUser)
+		account.POST("/auth/me/password", app.changePassword)
+		account.GET("/auth/me/identities", app.listCurrentUserIdentities)
+		account.POST("/auth/verify-email/resend", app.resendVerificationEmail)
+		account.DELETE("/auth/mfa/totp", app.disableMFA)
+		account.POST("/auth/mfa/recovery-codes", app.regenerateRecoveryCodes)
+
+		account.POST("/auth/api-keys", app.createAPIKey)
+		account.GET("/auth/api-keys", app.listAPIKeys)
+		account.DELETE("/auth/api-keys/:id", app.revokeAPIKey)
+	}
+
+	eventsRead := auth.Group("")
+	eventsRead.Use(app.requireScope(scopeEventsRead))
+	{
+		eventsRead.GET("/events/:id/attendees", app.getEventAttendees)
+		eventsRead.GET("/events/:id/attendees/:userId/history", app.getAttendeeStatusHistory)
+		eventsRead.GET("/events/:id/status-history", app.getEventStatusHistory)
+		eventsRead.GET("/events/:id/team", app.listEventTeam)
+		eventsRead.GET("/attendees/:id/events", app.getUserEvents)
+		eventsRead.GET("/events/:id/invitations", app.listEventInvitations)
+		eventsRead.GET("/events/:id/invite-links", app.listInviteLinks)
+		eventsRead.GET("/invitations", app.listMyInvitations)
+	}
+
+	eventsWrite := auth.Group("")
+	eventsWrite.Use(app.requireScope(scopeEventsWrite))
+	{
+		eventsWrite.POST("/events", app.createEvent)
+		eventsWrite.PUT("/events/:id", app.updateEvent)
+		eventsWrite.DELETE("/events/:id", app.deleteEvent)
+		eventsWrite.POST("/events/:id/restore", app.restoreEvent)
+		eventsWrite.PATCH("/events/:id/status", app.updateEventStatus)
+		eventsWrite.PUT("/events/:id/team/:userId", app.setEventTeamRole)
+		eventsWrite.DELETE("/events/:id/team/:userId", app.removeEventTeamMember)
+		eventsWrite.POST("/events/:id/transfer", app.transferEventOwnership)
+		eventsWrite.POST("/events/:id/invitations", app.createInvitation)
+		eventsWrite.DELETE("/events/:id/invitations/:invitationId", app.revokeInvitation)
+		eventsWrite.POST("/events/:id/invite-links", app.createInviteLink)
+		eventsWrite.DELETE("/events/:id/invite-links/:linkId", app.revokeInviteLink)
+		eventsWrite.POST("/venues", app.createVenue)
+		eventsWrite.PUT("/venues/:id", app.updateVenue)
+		eventsWrite.DELETE("/venues/:id", app.deleteVenue)
+	}
+
+	attendeesWrite := auth.Group("")
+	attendeesWrite.Use(app.requireScope(scopeAttendeesWrite))
+	{
+		attendeesWrite.POST("/events/:id/attendees", app.addEventAttendee)
+		attendeesWrite.PATCH("/events/:id/attendees/:userId", app.updateAttendeeStatus)
+		attendeesWrite.DELETE("/events/:id/attendees/:userId", app.deleteAttendeeFromEvent)
+		attendeesWrite.POST("/events/:id/attendees/:userId/confirm", app.confirmWaitlistOffer)
+		attendeesWrite.POST("/invitations/:id/accept", app.acceptInvitation)
+		attendeesWrite.POST("/invitations/:id/decline", app.declineInvitation)
+		attendeesWrite.POST("/invite-links/:token/accept", app.acceptInviteLink)
 	}
 
-	auth := g.Group("/api/v1")
-	auth.Use(app.jwtAuthMiddleware())
+	admin := account.Group("/admin")
+	admin.Use(app.requireRole(database.RoleAdmin))
 	{
-		auth.POST("/events", app.createEvent)
-		auth.PUT("/events/:id", app.updateEvent)
-		auth.DELETE("/events/:id", app.deleteEvent)
-
-		auth.POST("/events/:id/attendees", app.addEventAttendee)
-		auth.GET("/events/:id/attendees", app.getEventAttendees)
-		auth.DELETE("/events/:id/attendees/:userId", app.deleteAttendeeFromEvent)
-		auth.GET("/attendees/:id/events", app.getUserEvents)
+		admin.GET("/users", app.adminListUsers)
+		admin.GET("/users/:id", app.adminGetUser)
+		admin.DELETE("/users/:id", app.adminDeleteUser)
+		admin.POST("/users/:id/restore", app.adminRestoreUser)
+		admin.PATCH("/users/:id/role", app.adminUpdateUserRole)
+		admin.POST("/users/:id/disable", app.adminDisableUser)
+		admin.POST("/users/:id/enable", app.adminEnableUser)
+		admin.POST("/users/:id/force-password-reset", app.adminForcePasswordReset)
+		admin.POST("/users/:id/unlock", app.adminUnlockUser)
+		admin.POST("/users/:id/mfa/reset", app.adminResetUserMFA)
+		admin.GET("/mfa-policy", app.adminGetMFAPolicy)
+		admin.PUT("/mfa-policy/:role", app.adminSetMFAPolicy)
+		admin.GET("/audit-log", app.adminListAuditLog)
+		admin.GET("/lockouts", app.adminListLockouts)
+		admin.POST("/categories", app.adminCreateCategory)
+		admin.PUT("/categories/:id", app.adminUpdateCategory)
+		admin.DELETE("/categories/:id", app.adminDeleteCategory)
 	}
 	// Serve EventHub static UI
 	g.Static("/eventhub", "web/eventhub")
@@ -2,11 +2,15 @@ package main
 
 import (
 	"database/sql"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"rest-api-in-gin/internal/database"
 
@@ -15,6 +19,9 @@ import (
 	"fmt"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
 // helper to create an application with a temp sqlite DB and run migrations
@@ -28,70 +35,28 @@ func setupAppWithTempDB(t *testing.T) (*application, func()) {
 	dbPath := tmp.Name()
 	tmp.Close()
 
+	if err := migrateTestDB(dbPath); err != nil {
+		os.Remove(dbPath)
+		t.Fatalf("migrate test db: %v", err)
+	}
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		os.Remove(dbPath)
 		t.Fatalf("open sqlite db: %v", err)
 	}
 
-	// create minimal schema required for tests (avoid running full migrations)
-	createUsers := `CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT NOT NULL UNIQUE,
-		name TEXT,
-		password TEXT,
-		role TEXT DEFAULT 'user',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	createEvents := `CREATE TABLE IF NOT EXISTS events (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		description TEXT,
-		start_time DATETIME NOT NULL,
-		end_time DATETIME NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-
-	if _, err := db.Exec(createUsers); err != nil {
-		db.Close()
-		os.Remove(dbPath)
-		t.Fatalf("create users table: %v", err)
-	}
-	if _, err := db.Exec(createEvents); err != nil {
-		db.Close()
-		os.Remove(dbPath)
-		t.Fatalf("create events table: %v", err)
-	}
-	createAttendees := `CREATE TABLE IF NOT EXISTS attendees (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		event_id INTEGER NOT NULL,
-		user_id INTEGER NOT NULL,
-		status TEXT NOT NULL DEFAULT 'pending',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);`
-	if _, err := db.Exec(createAttendees); err != nil {
-		db.Close()
-		os.Remove(dbPath)
-		t.Fatalf("create attendees table: %v", err)
-	}
-
 	models := database.NewModels(db)
 	app := &application{
 		db:        db,
 		port:      0,
 		jwtSecret: "test-secret",
 		models:    models,
+		mailer:    newTestMailer(),
+		appURL:    "http://app.test",
 	}
 
 	cleanup := func() {
+		app.wg.Wait()
 		db.Close()
 		os.Remove(dbPath)
 	}
@@ -99,6 +64,84 @@ func setupAppWithTempDB(t *testing.T) (*application, func()) {
 	return app, cleanup
 }
 
+// migrateTestDB applies every migration in cmd/migrate/migrations to the
+// database at path, so tests run against the production schema.
+func migrateTestDB(path string) error {
+	migrations, err := testMigrations()
+	if err != nil {
+		return err
+	}
+	source, err := iofs.New(migrations, ".")
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	instance, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite3", instance)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
+
+// testMigrations returns the migrations to apply. Without FTS5 the
+// events_fts migration is replaced by a no-op, so only the search tests
+// need -tags sqlite_fts5 and the rest run against a plain build.
+func testMigrations() (fs.FS, error) {
+	dir := os.DirFS("../migrate/migrations")
+	if ftsSupported() {
+		return dir, nil
+	}
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := fstest.MapFS{}
+	for _, e := range entries {
+		data := []byte("-- events_fts needs SQLite built with FTS5\n")
+		if !strings.Contains(e.Name(), "_create_events_fts.") {
+			if data, err = fs.ReadFile(dir, e.Name()); err != nil {
+				return nil, err
+			}
+		}
+		migrations[e.Name()] = &fstest.MapFile{Data: data}
+	}
+	return migrations, nil
+}
+
+var (
+	ftsOnce sync.Once
+	ftsOK   bool
+)
+
+// ftsSupported reports whether the sqlite3 driver was built with FTS5
+// (-tags sqlite_fts5).
+func ftsSupported() bool {
+	ftsOnce.Do(func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		_, err = db.Exec(`CREATE VIRTUAL TABLE fts_probe USING fts5(body)`)
+		ftsOK = err == nil
+	})
+	return ftsOK
+}
+
 func TestPublicRoutes(t *testing.T) {
 	app, cleanup := setupAppWithTempDB(t)
 	defer cleanup()
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rsvpTransitions lists, for each current status, the statuses the attendee
+// themselves and the organizers (the event owner, co-organizers and admins) may
+// move an RSVP to. Waitlist promotion and offer expiry are handled by the
+// waitlist instead.
+var rsvpTransitions = map[string]struct{ attendee, organizer []string }{
+	database.AttendeeStatusPending: {
+		attendee:  []string{database.AttendeeStatusConfirmed, database.AttendeeStatusDeclined},
+		organizer: []string{database.AttendeeStatusConfirmed, database.AttendeeStatusRejected},
+	},
+	database.AttendeeStatusConfirmed: {
+		attendee:  []string{database.AttendeeStatusDeclined},
+		organizer: []string{database.AttendeeStatusRejected},
+	},
+	database.AttendeeStatusDeclined: {
+		attendee: []string{database.AttendeeStatusConfirmed},
+	},
+	database.AttendeeStatusRejected: {
+		organizer: []string{database.AttendeeStatusConfirmed},
+	},
+	database.AttendeeStatusOffered: {
+		attendee: []string{database.AttendeeStatusConfirmed, database.AttendeeStatusDeclined},
+	},
+	database.AttendeeStatusWaitlisted: {
+		attendee: []string{database.AttendeeStatusDeclined},
+	},
+}
+
+// canTransition reports whether an attendee or organizer may move an RSVP
+// from status from to status to.
+func canTransition(from, to string, isAttendee, isOrganizer bool) bool {
+	t := rsvpTransitions[from]
+	var allowed []string
+	if isAttendee {
+		allowed = append(allowed, t.attendee...)
+	}
+	if isOrganizer {
+		allowed = append(allowed, t.organizer...)
+	}
+	for _, s := range allowed {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type updateAttendeeStatusRequest struct {
+	Status string `json:"status" binding:"required" example:"confirmed"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// @Summary Change an RSVP status
+// @Description Attendees can confirm or decline their RSVP; event owners, co-organizers and admins can approve (confirmed) or reject it. Confirming a declined or rejected RSVP needs a free seat. Freed seats go to the waitlist.
+// @Tags Attendees
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param userId path int true "User ID"
+// @Param status body updateAttendeeStatusRequest true "New status and optional reason"
+// @Success 200 {object} main.AttendeeDoc
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/attendees/{userId} [patch]
+func (app *application) updateAttendeeStatus(c *gin.Context) {
+	ev, attendee, isAttendee, isOrganizer, ok := app.loadAttendee(c)
+	if !ok {
+		return
+	}
+
+	var req updateAttendeeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	if !database.ValidAttendeeStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	if req.Status == attendee.Status {
+		c.JSON(http.StatusOK, attendee)
+		return
+	}
+	if !canTransition(attendee.Status, req.Status, isAttendee, isOrganizer) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change this RSVP from " + attendee.Status + " to " + req.Status})
+		return
+	}
+	if database.Seated(req.Status) && !database.Seated(attendee.Status) && ev.Status != database.EventStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Event is not open for registration"})
+		return
+	}
+
+	tokenUser, _ := app.getUserFromContext(c)
+	err := app.models.Attendees.SetStatus(ev.ID, attendee.UserID, attendee.Status, req.Status, tokenUser.ID, req.Reason)
+	switch err {
+	case nil:
+	case database.ErrEventFull:
+		c.JSON(http.StatusConflict, gin.H{"error": "event full", "message": "This event has reached its capacity"})
+		return
+	case database.ErrOfferExpired:
+		c.JSON(http.StatusConflict, gin.H{"error": "The seat offer has expired"})
+		return
+	case database.ErrStatusChanged:
+		c.JSON(http.StatusConflict, gin.H{"error": "RSVP status changed in the meantime; reload and try again"})
+		return
+	default:
+		log.Printf("updateAttendeeStatus: db error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update RSVP status"})
+		return
+	}
+
+	if database.Seated(attendee.Status) && !database.Seated(req.Status) {
+		app.promoteWaitlist(ev)
+	}
+
+	updated, err := app.models.Attendees.Get(ev.ID, attendee.UserID)
+	if err != nil || updated == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendee"})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// @Summary RSVP status history
+// @Description List every status change of a user's RSVP, oldest first (the attendee, event owner, co-organizer or admin)
+// @Tags Attendees
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param userId path int true "User ID"
+// @Success 200 {array} database.AttendeeStatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/attendees/{userId}/history [get]
+func (app *application) getAttendeeStatusHistory(c *gin.Context) {
+	ev, attendee, _, _, ok := app.loadAttendee(c)
+	if !ok {
+		return
+	}
+
+	changes, err := app.models.Attendees.StatusHistory(ev.ID, attendee.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve RSVP history"})
+		return
+	}
+	if changes == nil {
+		changes = []*database.AttendeeStatusChange{}
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// loadAttendee loads the event and RSVP named by the :id and :userId path
+// parameters, and reports whether the authenticated user is that attendee
+// or may manage the event's attendees. It writes an error response
+// and returns ok=false if either does not exist or the user is neither.
+func (app *application) loadAttendee(c *gin.Context) (ev *database.Event, attendee *database.Attendee, isAttendee, isOrganizer, ok bool) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tokenUser, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ev, err = app.models.Events.Get(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	if ev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	isAttendee = tokenUser.ID == userID
+	isOrganizer, err = app.eventPermitted(ev, tokenUser, permManageAttendees)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+	if !isAttendee && !isOrganizer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	attendee, err = app.models.Attendees.Get(eventID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendee"})
+		return
+	}
+	if attendee == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendee not found"})
+		return
+	}
+
+	return ev, attendee, isAttendee, isOrganizer, true
+}
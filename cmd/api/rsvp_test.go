@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestRSVPStatusLifecycle(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	event := map[string]interface{}{"title": "Board game night", "description": "Two seats left at the table", "start_time": "2030-01-01T19:00:00Z", "end_time": "2030-01-01T22:00:00Z", "capacity": 2}
+	code, body := api.do("POST", "/api/v1/events", ownerToken, event)
+	var ev database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+		t.Fatalf("create: %d %s", code, body)
+	}
+
+	ids := map[string]int{}
+	tokens := map[string]string{"owner": ownerToken}
+	for _, name := range []string{"a", "b", "c", "stranger"} {
+		u := insertUserWithPassword(t, app, name+"@example.com", "password123")
+		ids[name] = u.ID
+		tokens[name], _ = jwtForUser(app, u.ID)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if code, body := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", ev.ID, ids[name]), tokens[name], nil); code != http.StatusCreated {
+			t.Fatalf("join %s: %d %s", name, code, body)
+		}
+	}
+
+	// setStatus changes who's RSVP as actor and returns the response code
+	setStatus := func(actor, who, status, reason string) int {
+		t.Helper()
+		code, body := api.do("PATCH", fmt.Sprintf("/api/v1/events/%d/attendees/%d", ev.ID, ids[who]), tokens[actor],
+			map[string]string{"status": status, "reason": reason})
+		if code == http.StatusOK {
+			var a database.Attendee
+			if json.Unmarshal(body, &a) != nil || a.Status != status {
+				t.Fatalf("%s setting %s to %s: %s", actor, who, status, body)
+			}
+		}
+		return code
+	}
+	list := func(status string) []database.EventAttendee {
+		t.Helper()
+		code, body := api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees?status=%s", ev.ID, status), ownerToken, nil)
+		var attendees []database.EventAttendee
+		if code != http.StatusOK || json.Unmarshal(body, &attendees) != nil {
+			t.Fatalf("list %q: %d %s", status, code, body)
+		}
+		return attendees
+	}
+
+	if got := list(""); len(got) != 2 || got[0].Status != database.AttendeeStatusPending {
+		t.Fatalf("default listing: %+v", got)
+	}
+	if got := list("waitlisted"); len(got) != 1 || got[0].ID != ids["c"] {
+		t.Fatalf("waitlisted listing: %+v", got)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees?status=maybe", ev.ID), ownerToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown status filter: expected 400, got %d", code)
+	}
+
+	if code := setStatus("a", "a", "confirmed", ""); code != http.StatusOK {
+		t.Fatalf("attendee confirm: %d", code)
+	}
+	if got := list("confirmed"); len(got) != 1 || got[0].ID != ids["a"] {
+		t.Fatalf("confirmed listing: %+v", got)
+	}
+	if code := setStatus("a", "a", "rejected", ""); code != http.StatusForbidden {
+		t.Fatalf("attendee rejecting themselves: expected 403, got %d", code)
+	}
+	if code := setStatus("stranger", "a", "declined", ""); code != http.StatusForbidden {
+		t.Fatalf("stranger: expected 403, got %d", code)
+	}
+
+	// Rejecting frees the seat for the waitlist
+	if code := setStatus("owner", "b", "rejected", "no plus-ones"); code != http.StatusOK {
+		t.Fatalf("organizer reject: %d", code)
+	}
+	if got := list("pending"); len(got) != 1 || got[0].ID != ids["c"] {
+		t.Fatalf("c should have been promoted: %+v", got)
+	}
+	if code := setStatus("b", "b", "confirmed", ""); code != http.StatusForbidden {
+		t.Fatalf("rejected attendee confirming: expected 403, got %d", code)
+	}
+
+	// Declining and confirming again needs the seat to still be free
+	if code := setStatus("a", "a", "declined", ""); code != http.StatusOK {
+		t.Fatalf("decline: %d", code)
+	}
+	if code := setStatus("a", "a", "confirmed", ""); code != http.StatusOK {
+		t.Fatalf("confirm after decline: %d", code)
+	}
+	if code := setStatus("owner", "b", "confirmed", ""); code != http.StatusConflict {
+		t.Fatalf("approve into a full event: expected 409, got %d", code)
+	}
+
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees/%d/history", ev.ID, ids["b"]), tokens["b"], nil)
+	var history []database.AttendeeStatusChange
+	if code != http.StatusOK || json.Unmarshal(body, &history) != nil || len(history) != 2 {
+		t.Fatalf("history: %d %s", code, body)
+	}
+	if h := history[1]; h.FromStatus != "pending" || h.ToStatus != "rejected" || h.Reason != "no plus-ones" || h.ChangedBy == nil || *h.ChangedBy != owner.ID {
+		t.Fatalf("rejection entry: %+v", h)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees/%d/history", ev.ID, ids["c"]), ownerToken, nil)
+	history = nil
+	if code != http.StatusOK || json.Unmarshal(body, &history) != nil || len(history) != 2 || history[1].ToStatus != "pending" || history[1].ChangedBy != nil {
+		t.Fatalf("promotion history: %d %s", code, body)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees/%d/history", ev.ID, ids["a"]), tokens["stranger"], nil); code != http.StatusForbidden {
+		t.Fatalf("stranger history: expected 403, got %d", code)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestBuildFTSQuery(t *testing.T) {
+	cases := map[string]string{
+		"go":                     `"go"`,
+		"conf*":                  `"conf"*`,
+		`"go workshop" rust`:     `"go workshop" "rust"`,
+		`title:secret OR NOT x-`: `"title" "secret" "OR" "NOT" "x"`,
+		`"unterminated phrase`:   `"unterminated phrase"`,
+		"* ^ ()":                 ``,
+	}
+	for in, want := range cases {
+		if got := database.BuildFTSQuery(in); got != want {
+			t.Errorf("BuildFTSQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	if !ftsSupported() {
+		t.Skip("sqlite built without FTS5; run tests with -tags sqlite_fts5")
+	}
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	u := &database.User{Email: "s@example.com", Name: "S", Password: "x"}
+	if err := app.models.Users.Insert(u); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	seed := []database.Event{
+		{User_id: u.ID, Title: "Go Conference", Description: "Talks about the Go language", StartTime: "2025-01-10T10:00:00Z", EndTime: "2025-01-10T12:00:00Z"},
+		{User_id: u.ID, Title: "Cooking class", Description: "Bring your own go-to recipe for the conference dinner", StartTime: "2025-02-10T10:00:00Z", EndTime: "2025-02-10T12:00:00Z"},
+		{User_id: u.ID, Title: "Board games", Description: "Strategy night for everyone", StartTime: "2025-03-10T10:00:00Z", EndTime: "2025-03-10T12:00:00Z"},
+		{User_id: u.ID, Title: "<script>alert(1)</script> workshop", Description: "Learn <b>markup</b> & escaping", StartTime: "2025-04-10T10:00:00Z", EndTime: "2025-04-10T12:00:00Z"},
+	}
+	for i := range seed {
+		if err := app.models.Events.Insert(&seed[i]); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+	// The update trigger must keep the index in sync
+	seed[2].Title = "Strategy games"
+	if err := app.models.Events.Update(&seed[2]); err != nil {
+		t.Fatalf("update event: %v", err)
+	}
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+
+	type searchResponse struct {
+		Data       []database.EventSearchResult `json:"data"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	search := func(q string) searchResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/v1/events/search?q=" + url.QueryEscape(q))
+		if err != nil {
+			t.Fatalf("search %q: %v", q, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("search %q: expected 200, got %d", q, resp.StatusCode)
+		}
+		var out searchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+
+	// Title matches rank above description matches
+	out := search("conference")
+	if out.Pagination.Total != 2 || out.Data[0].ID != seed[0].ID {
+		t.Fatalf("conference: unexpected results %+v", out)
+	}
+	if out.Data[0].Score <= out.Data[1].Score {
+		t.Fatalf("expected descending scores, got %v then %v", out.Data[0].Score, out.Data[1].Score)
+	}
+	if out.Data[0].TitleHighlight != "Go <mark>Conference</mark>" {
+		t.Fatalf("unexpected highlight %q", out.Data[0].TitleHighlight)
+	}
+
+	if out = search("confer*"); out.Pagination.Total != 2 {
+		t.Fatalf("prefix: expected 2 results, got %d", out.Pagination.Total)
+	}
+	if out = search(`"go language"`); out.Pagination.Total != 1 || out.Data[0].ID != seed[0].ID {
+		t.Fatalf("phrase: unexpected results %+v", out)
+	}
+	if out = search("strategy games"); out.Pagination.Total != 1 || out.Data[0].ID != seed[2].ID {
+		t.Fatalf("updated title: unexpected results %+v", out)
+	}
+	if out = search("board"); out.Pagination.Total != 0 {
+		t.Fatalf("stale title still indexed: %+v", out)
+	}
+
+	// Highlights are HTML with the event's own text escaped
+	if out = search("markup"); out.Pagination.Total != 1 ||
+		out.Data[0].TitleHighlight != "&lt;script&gt;alert(1)&lt;/script&gt; workshop" ||
+		out.Data[0].Snippet != "Learn &lt;b&gt;<mark>markup</mark>&lt;/b&gt; &amp; escaping" {
+		t.Fatalf("escaping: unexpected results %+v", out)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v1/events/search?q=%2A")
+	if err != nil {
+		t.Fatalf("empty search: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty query, got %d", resp.StatusCode)
+	}
+}
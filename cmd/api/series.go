@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/rrule"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// seriesHorizon is how far ahead occurrences of recurring events are
+	// kept materialized as events.
+	seriesHorizon = 180 * 24 * time.Hour
+
+	// maxSeriesHorizon bounds how far a listing may extend series on demand.
+	maxSeriesHorizon = 2 * 365 * 24 * time.Hour
+)
+
+// createSeries creates the recurring event described by event, whose
+// normalized start and end are those of the first occurrence, and responds
+// with that occurrence.
+func (app *application) createSeries(c *gin.Context, event *database.Event) {
+	rule, err := rrule.Parse(event.Recurrence)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := app.models.Series.Insert(event, rule, time.Now().Add(seriesHorizon)); err != nil {
+		if err == database.ErrNoOccurrences {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The recurrence rule has no occurrences"})
+			return
+		}
+		if err == database.ErrVenueBooked {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue is already booked at the time of an occurrence"})
+			return
+		}
+		log.Printf("createEvent: db insert series error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// seriesScope reads the scope query parameter of edits and deletions.
+func seriesScope(c *gin.Context) (string, bool) {
+	switch scope := c.DefaultQuery("scope", database.SeriesScopeThis); scope {
+	case database.SeriesScopeThis, database.SeriesScopeFollowing, database.SeriesScopeAll:
+		return scope, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, must be this, following or all"})
+		return "", false
+	}
+}
+
+// updateOccurrences applies updated, the normalized new values of
+// occurrence existing, to the following or all occurrences of its series.
+func (app *application) updateOccurrences(c *gin.Context, existing, updated *database.Event, scope string) {
+	oldStart, err := time.Parse(time.RFC3339, existing.StartTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	newStart, _ := time.Parse(time.RFC3339, updated.StartTime)
+	// Moving occurrences to other days could break the rule's pattern
+	loc, _ := loadTimezone(updated.Timezone)
+	if oldStart.In(loc).Format("2006-01-02") != newStart.In(loc).Format("2006-01-02") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Edits to several occurrences can only change the time of day"})
+		return
+	}
+
+	ids, err := app.models.Series.UpdateOccurrences(existing, updated, scope)
+	if err != nil {
+		if err == database.ErrCapacityBelowAttendance {
+			c.JSON(http.StatusConflict, gin.H{"error": "Capacity is below the current number of attendees"})
+			return
+		}
+		if err == database.ErrVenueBooked {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue is already booked at the time of an occurrence"})
+			return
+		}
+		log.Printf("updateEvent: db update series error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+
+	var event *database.Event
+	for _, id := range ids {
+		ev, err := app.models.Events.Get(id)
+		if err != nil || ev == nil {
+			log.Printf("updateEvent: load occurrence %d: %v", id, err)
+			continue
+		}
+		// A larger capacity may free seats for the waitlist
+		app.promoteWaitlist(ev)
+		if id == existing.ID {
+			event = ev
+		}
+	}
+	if event == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// materializeSeries periodically extends recurring events so their
+// occurrences stay materialized seriesHorizon ahead.
+func (app *application) materializeSeries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := app.models.Series.MaterializeUntil(time.Now().Add(seriesHorizon)); err != nil {
+			log.Printf("[SERIES] materialize occurrences: %v", err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/rrule"
+)
+
+func TestRRuleExpansion(t *testing.T) {
+	cases := []struct {
+		rule  string
+		start string
+		want  []string
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=5", "2030-01-02T18:00:00Z", // a Wednesday
+			[]string{"2030-01-02", "2030-01-07", "2030-01-09", "2030-01-14", "2030-01-16"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "2030-01-01T09:00:00Z",
+			[]string{"2030-01-25", "2030-02-22", "2030-03-29"}},
+		{"FREQ=DAILY;INTERVAL=2;UNTIL=20300107", "2030-01-01T09:00:00Z",
+			[]string{"2030-01-01", "2030-01-03", "2030-01-05", "2030-01-07"}},
+		{"RRULE:FREQ=MONTHLY;COUNT=3", "2030-01-31T09:00:00Z",
+			[]string{"2030-01-31", "2030-03-31", "2030-05-31"}},
+	}
+	for _, tc := range cases {
+		r, err := rrule.Parse(tc.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.rule, err)
+		}
+		start, _ := time.Parse(time.RFC3339, tc.start)
+		var got []string
+		it := r.Iterator(start)
+		for occ, ok := it.Next(); ok && len(got) < 10; occ, ok = it.Next() {
+			got = append(got, occ.Format("2006-01-02"))
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s from %s: got %v, want %v", tc.rule, tc.start, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"", "FREQ=YEARLY", "FREQ=DAILY;COUNT=2;UNTIL=20300101", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=DAILY;BYDAY=XX", "COUNT=3"} {
+		if _, err := rrule.Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRecurringEvents(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	guest := insertUserWithPassword(t, app, "guest@example.com", "password123")
+	guestToken, _ := jwtForUser(app, guest.ID)
+
+	// The first Monday at least a week from now
+	monday := time.Now().UTC().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+	for monday.Weekday() != time.Monday {
+		monday = monday.AddDate(0, 0, 1)
+	}
+	at := func(days, hour int) string {
+		return monday.AddDate(0, 0, days).Add(time.Duration(hour) * time.Hour).Format(time.RFC3339)
+	}
+
+	code, body := api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{
+		"title": "Running club", "description": "Easy laps around the park", "start_time": at(0, 18), "end_time": at(0, 19),
+		"recurrence": "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6",
+	})
+	var first database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &first) != nil || first.SeriesID == nil || first.StartTime != at(0, 18) {
+		t.Fatalf("create series: %d %s", code, body)
+	}
+
+	list := func(query string) []database.Event {
+		t.Helper()
+		code, body := api.do("GET", "/api/v1/events?limit=100&"+query, ownerToken, nil)
+		var resp struct {
+			Data []database.Event `json:"data"`
+		}
+		if code != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("list %s: %d %s", query, code, body)
+		}
+		return resp.Data
+	}
+	occurrences := func(seriesID int) []database.Event {
+		t.Helper()
+		return list(fmt.Sprintf("series_id=%d", seriesID))
+	}
+	update := func(ev database.Event, scope string, change func(*database.Event)) (int, database.Event) {
+		t.Helper()
+		change(&ev)
+		code, body := api.do("PUT", fmt.Sprintf("/api/v1/events/%d?scope=%s", ev.ID, scope), ownerToken, ev)
+		var got database.Event
+		json.Unmarshal(body, &got)
+		return code, got
+	}
+
+	occ := occurrences(*first.SeriesID)
+	if len(occ) != 6 || occ[1].StartTime != at(2, 18) || occ[5].StartTime != at(16, 18) || occ[5].EndTime != at(16, 19) {
+		t.Fatalf("occurrences: %+v", occ)
+	}
+	if got := list("start_date=" + at(7, 0) + "&end_date=" + at(10, 0)); len(got) != 2 {
+		t.Fatalf("window should hold two occurrences, got %d", len(got))
+	}
+
+	// Each occurrence has its own RSVPs
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", occ[1].ID, guest.ID), guestToken, nil); code != http.StatusCreated {
+		t.Fatalf("rsvp to occurrence: %d", code)
+	}
+	if a, _ := app.models.Attendees.Get(occ[2].ID, guest.ID); a != nil {
+		t.Fatal("an RSVP applies to one occurrence only")
+	}
+
+	// scope=this changes one occurrence
+	code, got := update(occ[1], database.SeriesScopeThis, func(ev *database.Event) { ev.Title = "Running club (indoors)" })
+	if code != http.StatusOK || got.Title != "Running club (indoors)" || got.SeriesID == nil {
+		t.Fatalf("update this: %d %+v", code, got)
+	}
+	if occ = occurrences(*first.SeriesID); occ[0].Title != "Running club" || occ[2].Title != "Running club" {
+		t.Fatalf("other occurrences must keep their title: %+v", occ)
+	}
+
+	// scope=following splits the series at the fourth occurrence
+	code, got = update(occ[3], database.SeriesScopeFollowing, func(ev *database.Event) {
+		ev.Title, ev.StartTime, ev.EndTime = "Evening run", at(9, 19), at(9, 21)
+	})
+	if code != http.StatusOK || got.SeriesID == nil || *got.SeriesID == *first.SeriesID || got.Recurrence != "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3" {
+		t.Fatalf("update following: %d %+v", code, got)
+	}
+	later := occurrences(*got.SeriesID)
+	if len(later) != 3 || later[2].Title != "Evening run" || later[2].StartTime != at(16, 19) || later[2].EndTime != at(16, 21) {
+		t.Fatalf("following occurrences: %+v", later)
+	}
+	if occ = occurrences(*first.SeriesID); len(occ) != 3 || !strings.Contains(occ[0].Recurrence, "UNTIL=") {
+		t.Fatalf("earlier occurrences: %+v", occ)
+	}
+
+	// scope=all updates every occurrence left in the series
+	capacity := 5
+	code, got = update(occ[0], database.SeriesScopeAll, func(ev *database.Event) { ev.Capacity = &capacity })
+	if code != http.StatusOK || got.Capacity == nil || *got.Capacity != 5 {
+		t.Fatalf("update all: %d %+v", code, got)
+	}
+	for _, ev := range occurrences(*first.SeriesID) {
+		if ev.Capacity == nil || *ev.Capacity != 5 || ev.Title != "Running club" {
+			t.Fatalf("all occurrences should be updated: %+v", ev)
+		}
+	}
+	if code, _ := update(occ[0], database.SeriesScopeAll, func(ev *database.Event) { ev.StartTime, ev.EndTime = at(1, 18), at(1, 19) }); code != http.StatusBadRequest {
+		t.Fatalf("moving all occurrences to another day: expected 400, got %d", code)
+	}
+	if code, _ := update(occ[0], "bogus", func(*database.Event) {}); code != http.StatusBadRequest {
+		t.Fatalf("invalid scope: expected 400, got %d", code)
+	}
+
+	single := database.Event{User_id: owner.ID, Title: "One-off", Description: "Happens only once", StartTime: at(3, 10), EndTime: at(3, 11)}
+	if err := app.models.Events.Insert(&single); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := update(single, database.SeriesScopeAll, func(*database.Event) {}); code != http.StatusBadRequest {
+		t.Fatalf("scope=all on a single event: expected 400, got %d", code)
+	}
+
+	// Cancelling the following occurrences ends the series for good
+	if code, body := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d?scope=following", later[1].ID), guestToken, nil); code != http.StatusForbidden {
+		t.Fatalf("guest cancelling occurrences: expected 403, got %d %s", code, body)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d?scope=following", later[1].ID), ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("cancel following: %d", code)
+	}
+	if err := app.models.Series.MaterializeUntil(time.Now().Add(maxSeriesHorizon)); err != nil {
+		t.Fatal(err)
+	}
+	if got := occurrences(*later[0].SeriesID); len(got) != 1 || got[0].ID != later[0].ID {
+		t.Fatalf("cancelled occurrences came back: %+v", got)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d?scope=all", occ[0].ID), ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("cancel all: %d", code)
+	}
+	if got := occurrences(*first.SeriesID); len(got) != 0 {
+		t.Fatalf("cancelled series still has occurrences: %+v", got)
+	}
+
+	// Open-ended series are extended when a listing looks further ahead
+	code, body = api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{
+		"title": "Standup", "description": "Daily check-in for the team", "start_time": at(0, 9), "end_time": at(0, 10),
+		"recurrence": "FREQ=DAILY",
+	})
+	var daily database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &daily) != nil {
+		t.Fatalf("create daily series: %d %s", code, body)
+	}
+	count := func(from, to string) int {
+		t.Helper()
+		code, body := api.do("GET", fmt.Sprintf("/api/v1/events?series_id=%d&start_date=%s&end_date=%s", *daily.SeriesID, from, to), ownerToken, nil)
+		var resp struct {
+			Pagination struct {
+				Total int `json:"total"`
+			} `json:"pagination"`
+		}
+		if code != http.StatusOK || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("count: %d %s", code, body)
+		}
+		return resp.Pagination.Total
+	}
+	if n := count(at(0, 0), at(10, 0)); n != 10 {
+		t.Fatalf("expected 10 daily occurrences, got %d", n)
+	}
+	if n := count(at(300, 0), at(310, 0)); n != 10 {
+		t.Fatalf("expected the series to be extended on demand, got %d occurrences", n)
+	}
+	if err := app.models.Series.MaterializeUntil(time.Now().Add(seriesHorizon)); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(at(0, 0), at(400, 0)); n > 400 {
+		t.Fatalf("occurrences were materialized twice: %d", n)
+	}
+
+	code, body = api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{
+		"title": "Broken", "description": "Never going to happen", "start_time": at(0, 9), "end_time": at(0, 10),
+		"recurrence": "FREQ=HOURLY",
+	})
+	if code != http.StatusBadRequest || !strings.Contains(string(body), "FREQ") {
+		t.Fatalf("invalid rule: %d %s", code, body)
+	}
+}
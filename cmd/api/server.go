@@ -28,6 +28,21 @@ func (app *application) server() error {
 	log.Printf("Docs: http://localhost:%d/docs", app.port)
 	log.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	// Remove expired refresh tokens and revocation entries
+	go app.purgeExpiredTokens(time.Hour)
+
+	// Pass unconfirmed waitlist offers on to the next in line
+	go app.expireWaitlistOffers(time.Minute)
+
+	// Keep occurrences of recurring events materialized ahead
+	go app.materializeSeries(time.Hour)
+
+	// Complete published events once they have ended
+	go app.completeEvents(time.Minute)
+
+	// Purge deleted events and users once they can no longer be restored
+	go app.purgeDeleted(time.Hour)
+
 	// Channel to listen for errors coming from the listener.
 	serverErrors := make(chan error, 1)
 
@@ -59,6 +74,9 @@ func (app *application) server() error {
 			return fmt.Errorf("could not stop server gracefully: %w", err)
 		}
 		log.Println("Server stopped gracefully")
+
+		// Let background tasks such as outgoing email finish
+		app.wg.Wait()
 	}
 
 	return nil
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	if err := app.models.Users.Insert(admin); err != nil {
+		t.Fatalf("insert admin: %v", err)
+	}
+	adminToken, _ := jwtForUser(app, admin.ID)
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	alice := insertUserWithPassword(t, app, "alice@example.com", "password123")
+	aliceToken, _ := jwtForUser(app, alice.ID)
+
+	code, body := api.do("POST", "/api/v1/venues", ownerToken, map[string]interface{}{"name": "Hall", "address": "Main St 1"})
+	var venue database.Venue
+	if code != http.StatusCreated || json.Unmarshal(body, &venue) != nil {
+		t.Fatalf("create venue: %d %s", code, body)
+	}
+	create := func(token, title string) database.Event {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/events", token, map[string]interface{}{"title": title, "description": "Something worth attending",
+			"start_time": "2030-05-01T18:00:00Z", "end_time": "2030-05-01T20:00:00Z", "venue_id": venue.ID})
+		var ev database.Event
+		if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+			t.Fatalf("create event %s: %d %s", title, code, body)
+		}
+		return ev
+	}
+	ev := create(ownerToken, "Book club")
+	eventPath := fmt.Sprintf("/api/v1/events/%d", ev.ID)
+
+	// Deleted events disappear but can be restored
+	if code, _ := api.do("DELETE", eventPath, ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("delete event: %d", code)
+	}
+	if code, _ := api.do("GET", eventPath, ownerToken, nil); code != http.StatusNotFound {
+		t.Fatalf("deleted event: expected 404, got %d", code)
+	}
+	if code, body := api.do("GET", "/api/v1/events", ownerToken, nil); code != http.StatusOK || strings.Contains(string(body), "Book club") {
+		t.Fatalf("deleted event listed: %d %s", code, body)
+	}
+	if err := app.models.Events.Update(&ev); err != database.ErrEventNotFound {
+		t.Fatalf("update a deleted event: expected ErrEventNotFound, got %v", err)
+	}
+	if code, _ := api.do("POST", eventPath+"/restore", aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("restore as another user: expected 403, got %d", code)
+	}
+	deleted, err := app.models.Events.GetDeleted(ev.ID)
+	if err != nil || deleted == nil {
+		t.Fatalf("get deleted event: %+v %v", deleted, err)
+	}
+	code, body = api.do("POST", eventPath+"/restore", ownerToken, nil)
+	var restored database.Event
+	if code != http.StatusOK || json.Unmarshal(body, &restored) != nil || restored.ID != ev.ID {
+		t.Fatalf("restore event: %d %s", code, body)
+	}
+	if code, _ := api.do("GET", eventPath, "", nil); code != http.StatusOK {
+		t.Fatalf("restored event: expected 200, got %d", code)
+	}
+	if code, _ := api.do("POST", eventPath+"/restore", ownerToken, nil); code != http.StatusNotFound {
+		t.Fatalf("restore a live event: expected 404, got %d", code)
+	}
+	// A restore racing another one finds the event live
+	if err := app.models.Events.Restore(deleted); err != database.ErrEventNotFound {
+		t.Fatalf("restore an event restored in the meantime: expected ErrEventNotFound, got %v", err)
+	}
+
+	// Deleted events free their venue, so restoring may clash
+	if code, _ := api.do("DELETE", eventPath, adminToken, nil); code != http.StatusOK {
+		t.Fatalf("delete event as admin: %d", code)
+	}
+	poetry := create(aliceToken, "Poetry night")
+	poetryPath := fmt.Sprintf("/api/v1/events/%d", poetry.ID)
+	code, body = api.do("POST", "/api/v1/events", aliceToken, map[string]interface{}{"title": "Open mic", "description": "Bring your own poems",
+		"start_time": "2030-05-02T18:00:00Z", "end_time": "2030-05-02T20:00:00Z"})
+	var openMic database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &openMic) != nil {
+		t.Fatalf("create event without venue: %d %s", code, body)
+	}
+	if code, _ := api.do("POST", eventPath+"/restore", adminToken, nil); code != http.StatusConflict {
+		t.Fatalf("restore into a booked venue: expected 409, got %d", code)
+	}
+
+	// An event deleted on its own just before its owner stays deleted when
+	// the owner is restored
+	code, body = api.do("POST", "/api/v1/events", aliceToken, map[string]interface{}{"title": "Karaoke", "description": "Sing your heart out",
+		"start_time": "2030-05-03T18:00:00Z", "end_time": "2030-05-03T20:00:00Z"})
+	var karaoke database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &karaoke) != nil {
+		t.Fatalf("create event without venue: %d %s", code, body)
+	}
+	karaokePath := fmt.Sprintf("/api/v1/events/%d", karaoke.ID)
+	if code, _ := api.do("DELETE", karaokePath, aliceToken, nil); code != http.StatusOK {
+		t.Fatalf("delete event: %d", code)
+	}
+
+	// Deleting a user deletes their events and revokes their tokens
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", admin.ID), adminToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("delete own account: expected 400, got %d", code)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", alice.ID), ownerToken, nil); code != http.StatusForbidden {
+		t.Fatalf("delete user as non-admin: expected 403, got %d", code)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", alice.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("delete user: %d", code)
+	}
+	if code, _ := api.do("GET", "/api/v1/auth/me", aliceToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("token of a deleted user: expected 401, got %d", code)
+	}
+	if code, _ := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": alice.Email, "password": "password123"}); code != http.StatusUnauthorized {
+		t.Fatalf("login as a deleted user: expected 401, got %d", code)
+	}
+	if code, body := api.do("GET", "/api/v1/events", "", nil); code != http.StatusOK || strings.Contains(string(body), "Open mic") {
+		t.Fatalf("events of a deleted user listed: %d %s", code, body)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/admin/users/%d", alice.ID), adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("deleted user: expected 404, got %d", code)
+	}
+
+	// The owner's event can now be restored, but not alice's until she is
+	if code, _ := api.do("POST", eventPath+"/restore", ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("restore after the clash went: %d", code)
+	}
+	if code, _ := api.do("POST", poetryPath+"/restore", adminToken, nil); code != http.StatusConflict {
+		t.Fatalf("restore the event of a deleted user: expected 409, got %d", code)
+	}
+
+	// The address of a deleted account is free to register again, which
+	// blocks restoring the account until the new one gives it up
+	register := map[string]string{"email": alice.Email, "password": "password456", "confirm": "password456", "name": "Alice Again"}
+	if code, body := api.do("POST", "/api/v1/auth/register", "", register); code != http.StatusCreated {
+		t.Fatalf("register the address of a deleted user: %d %s", code, body)
+	}
+	newcomer, err := app.models.Users.GetByEmail(alice.Email)
+	if err != nil || newcomer == nil || newcomer.ID == alice.ID {
+		t.Fatalf("new account: %+v %v", newcomer, err)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/restore", alice.ID), adminToken, nil); code != http.StatusConflict {
+		t.Fatalf("restore a user whose address was taken: expected 409, got %d", code)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/events/%d", openMic.ID), "", nil); code != http.StatusNotFound {
+		t.Fatalf("event of a user whose restore failed: expected 404, got %d", code)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", newcomer.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("delete the new account: %d", code)
+	}
+
+	// Restoring the user brings back the events deleted with them, except
+	// those whose venue was booked in the meantime
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/restore", alice.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("restore user: %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/restore", alice.ID), adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("restore a live user: expected 404, got %d", code)
+	}
+	if code, _ := api.do("GET", poetryPath, "", nil); code != http.StatusNotFound {
+		t.Fatalf("event clashing with a restored one: expected 404, got %d", code)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/events/%d", openMic.ID), "", nil); code != http.StatusOK {
+		t.Fatalf("event deleted with its owner: expected 200 after the restore, got %d", code)
+	}
+	if code, _ := api.do("GET", karaokePath, "", nil); code != http.StatusNotFound {
+		t.Fatalf("event deleted before its owner: expected 404 after the restore, got %d", code)
+	}
+	if code, _ := api.do("GET", "/api/v1/auth/me", aliceToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("token issued before the deletion: expected 401, got %d", code)
+	}
+	if code, _ := api.do("POST", "/api/v1/auth/login", "", map[string]string{"email": alice.Email, "password": "password123"}); code != http.StatusOK {
+		t.Fatalf("login as a restored user: expected 200, got %d", code)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/admin/audit-log?user_id=%d", alice.ID), adminToken, nil)
+	if code != http.StatusOK || !strings.Contains(string(body), auditUserDeleted) || !strings.Contains(string(body), auditUserRestored) {
+		t.Fatalf("audit log: %d %s", code, body)
+	}
+
+	// The purge removes what was deleted before the cutoff for good
+	if code, _ := api.do("DELETE", eventPath, ownerToken, nil); code != http.StatusOK {
+		t.Fatalf("delete event: %d", code)
+	}
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", owner.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("delete owner: %d", code)
+	}
+	// Only the newcomer goes; the owner still owns events
+	if n, _, err := app.models.Users.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("purge a user who still owns events: %d %v", n, err)
+	}
+	if n, err := app.models.Events.PurgeDeleted(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("purge within the retention period: %d %v", n, err)
+	}
+	if n, err := app.models.Events.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Fatalf("purge deleted events: %d %v", n, err)
+	}
+	if n, _, err := app.models.Users.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("purge deleted users: %d %v", n, err)
+	}
+	if code, _ := api.do("POST", eventPath+"/restore", adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("restore a purged event: expected 404, got %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/restore", owner.ID), adminToken, nil); code != http.StatusNotFound {
+		t.Fatalf("restore a purged user: expected 404, got %d", code)
+	}
+}
+
+func TestDeletedUsersAttendance(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	admin := &database.User{Email: "admin@example.com", Name: "Admin", Password: "x", Role: database.RoleAdmin}
+	if err := app.models.Users.Insert(admin); err != nil {
+		t.Fatalf("insert admin: %v", err)
+	}
+	adminToken, _ := jwtForUser(app, admin.ID)
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+
+	type member struct {
+		user  *database.User
+		token string
+	}
+	users := map[string]member{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		u := insertUserWithPassword(t, app, name+"@example.com", "password123")
+		token, _ := jwtForUser(app, u.ID)
+		users[name] = member{u, token}
+	}
+	create := func(title string, capacity interface{}) database.Event {
+		t.Helper()
+		code, body := api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{"title": title, "description": "Something worth attending",
+			"start_time": "2030-05-01T18:00:00Z", "end_time": "2030-05-01T20:00:00Z", "capacity": capacity})
+		var ev database.Event
+		if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+			t.Fatalf("create event %s: %d %s", title, code, body)
+		}
+		return ev
+	}
+	join := func(ev database.Event, name string) (status string, position int) {
+		t.Helper()
+		m := users[name]
+		code, body := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", ev.ID, m.user.ID), m.token, nil)
+		var resp struct {
+			Status           string `json:"status"`
+			WaitlistPosition int    `json:"waitlist_position"`
+		}
+		if code != http.StatusCreated || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("join %s: %d %s", name, code, body)
+		}
+		return resp.Status, resp.WaitlistPosition
+	}
+	attendees := func(ev database.Event, query string) string {
+		t.Helper()
+		code, body := api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees%s", ev.ID, query), ownerToken, nil)
+		if code != http.StatusOK {
+			t.Fatalf("attendees of %s: %d %s", ev.Title, code, body)
+		}
+		return string(body)
+	}
+	deleteUser := func(name string) {
+		t.Helper()
+		if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/admin/users/%d", users[name].user.ID), adminToken, nil); code != http.StatusOK {
+			t.Fatalf("delete %s: %d", name, code)
+		}
+	}
+	// mailed waits for the mails in flight and returns who they went to
+	mailed := func() map[string]bool {
+		app.wg.Wait()
+		to := map[string]bool{}
+		for {
+			select {
+			case msg := <-app.mailer.(*testMailer).sent:
+				to[msg.To] = true
+			default:
+				return to
+			}
+		}
+	}
+
+	dinner := create("Small dinner", 1)
+	quiz := create("Quiz night", nil)
+	if status, _ := join(dinner, "alice"); status != database.AttendeeStatusPending {
+		t.Fatalf("alice: expected a seat, got %q", status)
+	}
+	if status, pos := join(dinner, "bob"); status != database.AttendeeStatusWaitlisted || pos != 1 {
+		t.Fatalf("bob: got %q at %d", status, pos)
+	}
+	join(quiz, "alice")
+	join(quiz, "carol")
+
+	// Deleted accounts leave the lists, but their seats stay taken while
+	// their place in the queue does not hold anyone up
+	deleteUser("alice")
+	deleteUser("bob")
+	if list := attendees(dinner, "") + attendees(dinner, "?status=waitlisted"); strings.Contains(list, "alice@example.com") || strings.Contains(list, "bob@example.com") {
+		t.Fatalf("deleted users listed as attendees: %s", list)
+	}
+	if status, pos := join(dinner, "carol"); status != database.AttendeeStatusWaitlisted || pos != 1 {
+		t.Fatalf("carol: got %q at %d", status, pos)
+	}
+
+	// Cancelling does not mail deleted accounts
+	mailed()
+	code, body := api.do("PATCH", fmt.Sprintf("/api/v1/events/%d/status", quiz.ID), ownerToken, map[string]interface{}{"status": "cancelled"})
+	if code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", code, body)
+	}
+	if to := mailed(); len(to) != 1 || !to["carol@example.com"] {
+		t.Fatalf("cancellation mails sent to %v", to)
+	}
+
+	// Restoring the account brings its RSVPs back
+	if code, _ := api.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/restore", users["alice"].user.ID), adminToken, nil); code != http.StatusOK {
+		t.Fatalf("restore alice: %d", code)
+	}
+	if list := attendees(dinner, ""); !strings.Contains(list, "alice@example.com") {
+		t.Fatalf("restored user not listed as attendee: %s", list)
+	}
+
+	// Purging the account gives its seats to the waitlists; the cancelled
+	// quiz is reported too but promotes nobody
+	deleteUser("alice")
+	n, freed, err := app.models.Users.PurgeDeleted(time.Now().Add(time.Minute))
+	if err != nil || n != 2 || len(freed) != 2 || freed[0] != dinner.ID || freed[1] != quiz.ID {
+		t.Fatalf("purge: %d %v %v", n, freed, err)
+	}
+	app.promoteWaitlist(&dinner)
+	if list := attendees(dinner, ""); !strings.Contains(list, "carol@example.com") {
+		t.Fatalf("carol not promoted: %s", list)
+	}
+}
+
+func TestPurgeRemovesDependentRows(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	guest := insertUserWithPassword(t, app, "guest@example.com", "password123")
+	var gone, kept database.Event
+	for _, ev := range []*database.Event{&gone, &kept} {
+		*ev = database.Event{User_id: owner.ID, Title: "Meetup", Description: "Purge test event", StartTime: "2030-01-01T10:00:00Z", EndTime: "2030-01-01T12:00:00Z"}
+		if err := app.models.Events.Insert(ev); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := app.db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	exec(`INSERT INTO tags (name) VALUES ('go')`)
+	exec(`INSERT INTO event_series (user_id, rrule, title, start_time, end_time, materialized_until) VALUES (?, 'FREQ=WEEKLY', 'Weekly', '2030-01-01T10:00:00Z', '2030-01-01T12:00:00Z', '2030-01-01T10:00:00Z')`, guest.ID)
+	exec(`INSERT INTO series_tags (series_id, tag_id) SELECT id, 1 FROM event_series`)
+	for _, id := range []int{gone.ID, kept.ID} {
+		exec(`INSERT INTO attendees (event_id, user_id, status) VALUES (?, ?, 'confirmed')`, id, guest.ID)
+		exec(`INSERT INTO attendee_status_history (event_id, user_id, to_status, changed_by) VALUES (?, ?, 'confirmed', ?)`, id, owner.ID, guest.ID)
+		exec(`INSERT INTO attendee_status_history (event_id, user_id, to_status) VALUES (?, ?, 'confirmed')`, id, guest.ID)
+		exec(`INSERT INTO event_status_history (event_id, from_status, to_status, changed_by) VALUES (?, 'draft', 'published', ?)`, id, guest.ID)
+		exec(`INSERT INTO event_invitations (event_id, invited_by, email) VALUES (?, ?, 'friend@example.com')`, id, guest.ID)
+		exec(`INSERT INTO event_invitations (event_id, user_id, email) VALUES (?, ?, ?)`, id, guest.ID, guest.Email)
+		exec(`INSERT INTO event_invite_links (event_id, created_by) VALUES (?, ?)`, id, guest.ID)
+		exec(`INSERT INTO event_roles (event_id, user_id, role, granted_by) VALUES (?, ?, 'viewer', ?)`, id, owner.ID, guest.ID)
+		exec(`INSERT INTO event_roles (event_id, user_id, role) VALUES (?, ?, 'co_organizer')`, id, guest.ID)
+		exec(`INSERT INTO event_tags (event_id, tag_id) VALUES (?, 1)`, id)
+	}
+	exec(`INSERT INTO auth_sessions (id, user_id) VALUES ('session', ?)`, guest.ID)
+	exec(`INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES ('session', 'refresh', '2030-01-01T00:00:00Z')`)
+	exec(`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes) VALUES (?, 'ci', 'eh_', 'key', 'events:read')`, guest.ID)
+	exec(`INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES (?, 'password_reset', 'reset', '2030-01-01T00:00:00Z')`, guest.ID)
+	exec(`INSERT INTO user_identities (user_id, provider, subject) VALUES (?, 'google', 'guest')`, guest.ID)
+	exec(`INSERT INTO user_totp (user_id, secret) VALUES (?, 'secret')`, guest.ID)
+	exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, 'code')`, guest.ID)
+	exec(`INSERT INTO venues (created_by, name, address) VALUES (?, 'Hall', 'Main St 1')`, guest.ID)
+
+	if err := app.models.Events.Delete(gone.ID); err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+	if _, err := app.models.Users.Delete(guest.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if n, err := app.models.Events.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("purge events: %d %v", n, err)
+	}
+	if n, _, err := app.models.Users.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("purge users: %d %v", n, err)
+	}
+
+	// Nothing points at the purged rows any more; the rows of the other
+	// event that only mentioned the user remain
+	count := func(query string, args ...interface{}) int {
+		t.Helper()
+		var n int
+		if err := app.db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	for _, table := range []string{"attendees", "attendee_status_history", "event_status_history", "event_invitations", "event_invite_links", "event_roles", "event_tags"} {
+		if n := count(`SELECT COUNT(*) FROM `+table+` WHERE event_id = ?`, gone.ID); n != 0 {
+			t.Errorf("%s of the purged event: %d left", table, n)
+		}
+	}
+	for _, table := range []string{"attendees", "attendee_status_history", "event_invitations", "event_roles", "auth_sessions", "api_keys", "user_tokens", "user_identities", "user_totp", "mfa_recovery_codes", "event_series"} {
+		if n := count(`SELECT COUNT(*) FROM `+table+` WHERE user_id = ?`, guest.ID); n != 0 {
+			t.Errorf("%s of the purged user: %d left", table, n)
+		}
+	}
+	for table, column := range map[string]string{"attendee_status_history": "changed_by", "event_status_history": "changed_by", "event_invitations": "invited_by",
+		"event_invite_links": "created_by", "event_roles": "granted_by", "venues": "created_by"} {
+		if n := count(`SELECT COUNT(*) FROM `+table+` WHERE `+column+` = ?`, guest.ID); n != 0 {
+			t.Errorf("%s.%s still names the purged user: %d", table, column, n)
+		}
+	}
+	if n := count(`SELECT COUNT(*) FROM refresh_tokens`) + count(`SELECT COUNT(*) FROM series_tags`); n != 0 {
+		t.Errorf("refresh tokens and series tags left: %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM event_roles WHERE event_id = ? AND user_id = ? AND granted_by IS NULL`, kept.ID, owner.ID); n != 1 {
+		t.Errorf("role granted by the purged user: %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM event_invite_links WHERE event_id = ?`, kept.ID); n != 1 {
+		t.Errorf("invite link created by the purged user: %d", n)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions on an event, granted by the roles in rolePermissions
+const (
+	// permViewEvent shows a private or draft event and its status history
+	permViewEvent = "event.view"
+	// permViewAttendees lists the attendees, e.g. for checking them in
+	permViewAttendees = "attendees.view"
+	// permManageAttendees adds, removes, approves and invites attendees
+	permManageAttendees = "attendees.manage"
+	// permEditEvent updates the event and changes its status
+	permEditEvent = "event.edit"
+	// permManageTeam grants and revokes roles on the event
+	permManageTeam = "team.manage"
+	// permOwnEvent deletes, restores and transfers the event
+	permOwnEvent = "event.own"
+)
+
+// rolePermissions lists what each role on an event's team may do. Admins
+// may do everything.
+var rolePermissions = map[string][]string{
+	database.EventRoleOwner:        {permViewEvent, permViewAttendees, permManageAttendees, permEditEvent, permManageTeam, permOwnEvent},
+	database.EventRoleCoOrganizer:  {permViewEvent, permViewAttendees, permManageAttendees, permEditEvent},
+	database.EventRoleCheckInStaff: {permViewEvent, permViewAttendees},
+	database.EventRoleViewer:       {permViewEvent},
+}
+
+type setTeamRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=co_organizer checkin_staff viewer" example:"co_organizer"`
+}
+
+type transferOwnershipRequest struct {
+	UserID int `json:"user_id" binding:"required" example:"42"`
+}
+
+// eventRole returns the role user holds on ev's team, or "" if none.
+func (app *application) eventRole(ev *database.Event, user *database.User) (string, error) {
+	if ev.User_id == user.ID {
+		return database.EventRoleOwner, nil
+	}
+	return app.models.EventRoles.Role(ev.ID, user.ID)
+}
+
+// eventPermitted reports whether user may do perm on ev.
+func (app *application) eventPermitted(ev *database.Event, user *database.User, perm string) (bool, error) {
+	if user.Role == database.RoleAdmin {
+		return true, nil
+	}
+	role, err := app.eventRole(ev, user)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// requireEventPermission checks that the authenticated user may do perm on
+// ev, responding with an error otherwise. Every organizer action on an
+// event goes through it.
+func (app *application) requireEventPermission(c *gin.Context, ev *database.Event, perm string) bool {
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	permitted, err := app.eventPermitted(ev, user, perm)
+	if err != nil {
+		log.Printf("requireEventPermission: role of user %d on event %d: %v", user.ID, ev.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return false
+	}
+	if !permitted {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this on this event"})
+		return false
+	}
+	return true
+}
+
+// @Summary List an event's team
+// @Description List the owner, co-organizers, check-in staff and viewers of an event (event team or admin)
+// @Tags Events
+// @Produce json
+// @Param id path int true "Event ID"
+// @Success 200 {array} database.EventTeamMember
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/team [get]
+func (app *application) listEventTeam(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permViewEvent)
+	if ev == nil {
+		return
+	}
+
+	team, err := app.models.EventRoles.List(ev)
+	if err != nil {
+		log.Printf("listEventTeam: db list error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team"})
+		return
+	}
+	if team == nil {
+		team = []*database.EventTeamMember{}
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// @Summary Give someone a role on an event
+// @Description Add a user to an event's team as co-organizer, check-in staff or viewer, or change their role (owner or admin). Co-organizers edit the event and manage its attendees, check-in staff see the attendees and viewers see the event even if it is private or a draft.
+// @Tags Events
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param userId path int true "User ID"
+// @Param role body main.setTeamRoleRequest true "Role"
+// @Success 200 {object} database.EventTeamMember
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/team/{userId} [put]
+func (app *application) setEventTeamRole(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permManageTeam)
+	if ev == nil {
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req setTeamRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if userID == ev.User_id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner's role can only change by transferring ownership"})
+		return
+	}
+	user, err := app.models.Users.Get(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	tokenUser, _ := app.getUserFromContext(c)
+	member := database.EventTeamMember{EventID: ev.ID, UserID: user.ID, Name: user.Name, Email: user.Email, Role: req.Role, GrantedBy: &tokenUser.ID}
+	if err := app.models.EventRoles.Set(&member); err != nil {
+		log.Printf("setEventTeamRole: db set error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// @Summary Remove someone from an event's team
+// @Description Take a user's role on an event away (owner or admin). Team members may also leave on their own.
+// @Tags Events
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param userId path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/team/{userId} [delete]
+func (app *application) removeEventTeamMember(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	ev, err := app.models.Events.Get(eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+	if ev == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	tokenUser, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if tokenUser.ID != userID && !app.requireEventPermission(c, ev, permManageTeam) {
+		return
+	}
+	if userID == ev.User_id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner can only leave by transferring ownership"})
+		return
+	}
+
+	removed, err := app.models.EventRoles.Remove(ev.ID, userID)
+	if err != nil {
+		log.Printf("removeEventTeamMember: db remove error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member removed"})
+}
+
+// @Summary Transfer an event
+// @Description Make another user the owner of an event (owner or admin). The previous owner stays on the team as a co-organizer. Occurrences of a recurring event are transferred one at a time.
+// @Tags Events
+// @Accept json
+// @Produce json
+// @Param id path int true "Event ID"
+// @Param transfer body main.transferOwnershipRequest true "New owner"
+// @Success 200 {object} main.EventDoc
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/transfer [post]
+func (app *application) transferEventOwnership(c *gin.Context) {
+	ev := app.loadOrganizedEvent(c, permOwnEvent)
+	if ev == nil {
+		return
+	}
+
+	var req transferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if req.UserID == ev.User_id {
+		c.JSON(http.StatusOK, ev)
+		return
+	}
+	user, err := app.models.Users.Get(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+	if user == nil {
+		invalidRequest(c, fieldErrors{"user_id": "does not exist"})
+		return
+	}
+
+	tokenUser, _ := app.getUserFromContext(c)
+	if err := app.models.EventRoles.TransferOwnership(ev, user.ID, tokenUser.ID); err != nil {
+		if err == database.ErrEventOwnerChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event owner changed in the meantime; reload and try again"})
+			return
+		}
+		log.Printf("transferEventOwnership: db transfer error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer event"})
+		return
+	}
+
+	event, err := app.models.Events.Get(ev.ID)
+	if err != nil || event == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestEventTeam(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	alice := insertUserWithPassword(t, app, "alice@example.com", "password123")
+	aliceToken, _ := jwtForUser(app, alice.ID)
+	bob := insertUserWithPassword(t, app, "bob@example.com", "password123")
+	bobToken, _ := jwtForUser(app, bob.ID)
+	dave := insertUserWithPassword(t, app, "dave@example.com", "password123")
+	daveToken, _ := jwtForUser(app, dave.ID)
+	carol := insertUserWithPassword(t, app, "carol@example.com", "password123")
+	carolToken, _ := jwtForUser(app, carol.ID)
+
+	code, body := api.do("POST", "/api/v1/events", ownerToken, map[string]interface{}{"title": "Team offsite", "description": "Planning the next quarter",
+		"start_time": "2030-06-01T09:00:00Z", "end_time": "2030-06-01T17:00:00Z", "visibility": "private"})
+	var ev database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+		t.Fatalf("create event: %d %s", code, body)
+	}
+	eventPath := fmt.Sprintf("/api/v1/events/%d", ev.ID)
+	teamPath := func(u *database.User) string { return fmt.Sprintf("%s/team/%d", eventPath, u.ID) }
+
+	// Only the owner manages the team
+	for _, m := range []struct {
+		user *database.User
+		role string
+	}{{alice, "co_organizer"}, {bob, "checkin_staff"}, {dave, "viewer"}} {
+		code, body := api.do("PUT", teamPath(m.user), ownerToken, map[string]string{"role": m.role})
+		var member database.EventTeamMember
+		if code != http.StatusOK || json.Unmarshal(body, &member) != nil || member.Role != m.role || *member.GrantedBy != owner.ID {
+			t.Fatalf("grant %s to %s: %d %s", m.role, m.user.Email, code, body)
+		}
+	}
+	if code, _ := api.do("PUT", teamPath(carol), aliceToken, map[string]string{"role": "viewer"}); code != http.StatusForbidden {
+		t.Fatalf("grant as co-organizer: expected 403, got %d", code)
+	}
+	if code, _ := api.do("PUT", teamPath(carol), ownerToken, map[string]string{"role": "owner"}); code != http.StatusBadRequest {
+		t.Fatalf("grant owner: expected 400, got %d", code)
+	}
+	if code, _ := api.do("PUT", teamPath(owner), ownerToken, map[string]string{"role": "viewer"}); code != http.StatusBadRequest {
+		t.Fatalf("demote the owner: expected 400, got %d", code)
+	}
+	if code, _ := api.do("PUT", eventPath+"/team/999", ownerToken, map[string]string{"role": "viewer"}); code != http.StatusNotFound {
+		t.Fatalf("grant to an unknown user: expected 404, got %d", code)
+	}
+
+	code, body = api.do("GET", eventPath+"/team", daveToken, nil)
+	var team []database.EventTeamMember
+	if code != http.StatusOK || json.Unmarshal(body, &team) != nil || len(team) != 4 {
+		t.Fatalf("list team: %d %s", code, body)
+	}
+	for i, want := range []string{"owner", "co_organizer", "checkin_staff", "viewer"} {
+		if team[i].Role != want {
+			t.Fatalf("team member %d: %+v", i, team[i])
+		}
+	}
+	if code, _ := api.do("GET", eventPath+"/team", carolToken, nil); code != http.StatusForbidden {
+		t.Fatalf("team as outsider: expected 403, got %d", code)
+	}
+
+	// Team members see the private event, outsiders do not
+	if code, _ := api.do("GET", eventPath, daveToken, nil); code != http.StatusOK {
+		t.Fatalf("private event as viewer: expected 200, got %d", code)
+	}
+	if code, _ := api.do("GET", eventPath, carolToken, nil); code != http.StatusNotFound {
+		t.Fatalf("private event as outsider: expected 404, got %d", code)
+	}
+
+	// Roles decide who edits the event and manages its attendees
+	update := map[string]interface{}{"user_id": carol.ID, "title": "Team offsite", "description": "Planning the next two quarters",
+		"start_time": "2030-06-01T09:00:00Z", "end_time": "2030-06-01T18:00:00Z"}
+	code, body = api.do("PUT", eventPath, aliceToken, update)
+	var updated database.Event
+	if code != http.StatusOK || json.Unmarshal(body, &updated) != nil || updated.User_id != owner.ID {
+		t.Fatalf("update as co-organizer: %d %s", code, body)
+	}
+	if code, _ := api.do("PUT", eventPath, bobToken, update); code != http.StatusForbidden {
+		t.Fatalf("update as check-in staff: expected 403, got %d", code)
+	}
+	if code, _ := api.do("POST", fmt.Sprintf("%s/attendees?user_id=%d", eventPath, carol.ID), aliceToken, nil); code != http.StatusCreated {
+		t.Fatalf("add attendee as co-organizer: %d", code)
+	}
+	if code, _ := api.do("GET", eventPath+"/attendees", bobToken, nil); code != http.StatusOK {
+		t.Fatalf("attendees as check-in staff: %d", code)
+	}
+	for _, token := range []string{daveToken, carolToken} {
+		if code, _ := api.do("GET", eventPath+"/attendees", token, nil); code != http.StatusForbidden {
+			t.Fatalf("attendees as viewer or attendee: expected 403, got %d", code)
+		}
+	}
+	attendeePath := fmt.Sprintf("%s/attendees/%d", eventPath, carol.ID)
+	if code, _ := api.do("DELETE", attendeePath, bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("remove attendee as check-in staff: expected 403, got %d", code)
+	}
+	if code, _ := api.do("DELETE", attendeePath, aliceToken, nil); code != http.StatusOK {
+		t.Fatalf("remove attendee as co-organizer: %d", code)
+	}
+	if code, _ := api.do("DELETE", eventPath, aliceToken, nil); code != http.StatusForbidden {
+		t.Fatalf("delete as co-organizer: expected 403, got %d", code)
+	}
+
+	// Members may leave the team on their own
+	if code, _ := api.do("DELETE", teamPath(dave), bobToken, nil); code != http.StatusForbidden {
+		t.Fatalf("remove another member: expected 403, got %d", code)
+	}
+	if code, _ := api.do("DELETE", teamPath(bob), bobToken, nil); code != http.StatusOK {
+		t.Fatalf("leave the team: %d", code)
+	}
+	if code, _ := api.do("GET", eventPath+"/attendees", bobToken, nil); code != http.StatusNotFound {
+		t.Fatalf("attendees of a private event after leaving: expected 404, got %d", code)
+	}
+	if code, _ := api.do("DELETE", teamPath(owner), ownerToken, nil); code != http.StatusBadRequest {
+		t.Fatalf("owner leaving: expected 400, got %d", code)
+	}
+
+	// Transferring keeps the previous owner on the team
+	if code, _ := api.do("POST", eventPath+"/transfer", aliceToken, map[string]int{"user_id": alice.ID}); code != http.StatusForbidden {
+		t.Fatalf("transfer as co-organizer: expected 403, got %d", code)
+	}
+	if code, _ := api.do("POST", eventPath+"/transfer", ownerToken, map[string]int{"user_id": 999}); code != http.StatusBadRequest {
+		t.Fatalf("transfer to an unknown user: expected 400, got %d", code)
+	}
+	code, body = api.do("POST", eventPath+"/transfer", ownerToken, map[string]int{"user_id": alice.ID})
+	var transferred database.Event
+	if code != http.StatusOK || json.Unmarshal(body, &transferred) != nil || transferred.User_id != alice.ID {
+		t.Fatalf("transfer: %d %s", code, body)
+	}
+	code, body = api.do("GET", eventPath+"/team", aliceToken, nil)
+	if code != http.StatusOK || json.Unmarshal(body, &team) != nil || len(team) != 3 ||
+		team[0].UserID != alice.ID || team[0].Role != "owner" || team[1].UserID != owner.ID || team[1].Role != "co_organizer" {
+		t.Fatalf("team after transfer: %d %s", code, body)
+	}
+	if code, _ := api.do("DELETE", eventPath, ownerToken, nil); code != http.StatusForbidden {
+		t.Fatalf("delete as previous owner: expected 403, got %d", code)
+	}
+	if code, _ := api.do("PUT", eventPath, ownerToken, update); code != http.StatusOK {
+		t.Fatalf("update as previous owner: %d", code)
+	}
+	if code, _ := api.do("DELETE", eventPath, aliceToken, nil); code != http.StatusOK {
+		t.Fatalf("delete as new owner: %d", code)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"rest-api-in-gin/internal/database"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	// sessionRetention is how long revoked and expired login sessions are
+	// kept before they are purged. It must exceed accessTokenTTL, or access
+	// tokens of a revoked session would pass again once it is gone.
+	sessionRetention = 7 * 24 * time.Hour
+)
+
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// issueAccessToken signs a short-lived access token for user within a login
+// session. The role claim is informational for clients; jwtAuthMiddleware
+// always reloads the user so role changes take effect immediately, and
+// rejects tokens whose jti or session was revoked or whose token_version is
+// stale.
+func (app *application) issueAccessToken(user *database.User, sessionID string) (string, error) {
+	now := time.Now()
+	return app.signToken(jwt.MapClaims{
+		"user_id":       user.ID,
+		"role":          user.Role,
+		"token_version": user.TokenVersion,
+		"jti":           uuid.New().String(),
+		"sid":           sessionID,
+		"iat":           jwt.NewNumericDate(now),
+		"exp":           jwt.NewNumericDate(now.Add(accessTokenTTL)),
+	})
+}
+
+// startSession opens a new login session for user and returns its first
+// access and refresh tokens.
+func (app *application) startSession(user *database.User) (*tokenPair, error) {
+	refresh, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New().String()
+	if err := app.models.Tokens.CreateSession(sessionID, user.ID, refreshHash, time.Now().Add(refreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	access, err := app.issueAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newOpaqueToken returns a random opaque token (refresh, password reset or
+// verification) and the hash to store.
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex SHA-256 digest under which an opaque token is
+// stored, so a database leak does not expose usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// purgeExpiredTokens periodically removes refresh tokens and revocation
+// entries that have expired, login sessions past sessionRetention, stale
+// failed-login counters and abandoned identity provider logins.
+func (app *application) purgeExpiredTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := app.models.Tokens.PurgeExpired(); err != nil {
+			log.Printf("[TOKENS] purge expired tokens: %v", err)
+		}
+		if _, err := app.models.Tokens.PurgeSessions(time.Now().Add(-sessionRetention)); err != nil {
+			log.Printf("[TOKENS] purge old sessions: %v", err)
+		}
+		if err := app.models.LoginThrottle.PurgeStale(time.Now().Add(-loginFailureWindow)); err != nil {
+			log.Printf("[LOGIN] purge stale login attempts: %v", err)
+		}
+		if err := app.models.Identities.PurgeExpiredStates(); err != nil {
+			log.Printf("[OIDC] purge expired login states: %v", err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"rest-api-in-gin/internal/database"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// fieldErrors maps the JSON names of invalid request fields to what is
+// wrong with them.
+type fieldErrors map[string]string
+
+func init() {
+	// Report validation errors under the JSON names clients know
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindingErrors describes why a request body could not be bound. Errors
+// that are not about a particular field are reported under "body".
+func bindingErrors(err error) fieldErrors {
+	fields := fieldErrors{}
+
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &invalid):
+		for _, fe := range invalid {
+			fields[fe.Field()] = validationMessage(fe)
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		fields[typeErr.Field] = "must be a " + typeErr.Type.String()
+	default:
+		fields["body"] = "must be a valid JSON object"
+	}
+	return fields
+}
+
+func validationMessage(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	default:
+		return "is invalid"
+	}
+}
+
+// invalidRequest responds with 400 and the per-field errors.
+func invalidRequest(c *gin.Context, fields fieldErrors) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "fields": fields})
+}
+
+// loadTimezone loads an IANA time zone. Unlike time.LoadLocation it does
+// not accept "Local", which depends on the server.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// normalizeEventTimes checks that ev's start and end are RFC 3339 times,
+// with the end after the start, and that its time zone exists. Valid times
+// are converted to UTC; an empty time zone defaults to UTC.
+func normalizeEventTimes(ev *database.Event) fieldErrors {
+	fields := fieldErrors{}
+
+	if ev.Timezone == "" {
+		ev.Timezone = "UTC"
+	}
+	if _, err := loadTimezone(ev.Timezone); err != nil {
+		fields["timezone"] = "must be an IANA time zone such as Europe/Berlin"
+	}
+
+	start, err := time.Parse(time.RFC3339, ev.StartTime)
+	if err != nil {
+		fields["start_time"] = "must be an RFC 3339 date-time such as 2025-12-01T18:00:00+01:00"
+	}
+	end, err := time.Parse(time.RFC3339, ev.EndTime)
+	if err != nil {
+		fields["end_time"] = "must be an RFC 3339 date-time such as 2025-12-01T20:00:00+01:00"
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	if !end.After(start) {
+		fields["end_time"] = "must be after start_time"
+		return fields
+	}
+
+	ev.StartTime = start.UTC().Format(time.RFC3339)
+	ev.EndTime = end.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// renderTimezone reads the tz query parameter, the time zone event times
+// are rendered in: an IANA name, or "event" for each event's own time
+// zone. It defaults to UTC, the stored form.
+func renderTimezone(c *gin.Context) (string, bool) {
+	tz := c.Query("tz")
+	if tz == "" || tz == "event" {
+		return tz, true
+	}
+	if _, err := loadTimezone(tz); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tz, must be an IANA time zone or event"})
+		return "", false
+	}
+	return tz, true
+}
+
+// renderEventTimes converts the start and end of events to tz, as read by
+// renderTimezone. Times that do not parse are left alone.
+func renderEventTimes(tz string, events ...*database.Event) {
+	if tz == "" {
+		return
+	}
+	locations := map[string]*time.Location{}
+	for _, ev := range events {
+		name := tz
+		if name == "event" {
+			name = ev.Timezone
+		}
+		loc, ok := locations[name]
+		if !ok {
+			loc, _ = loadTimezone(name)
+			locations[name] = loc
+		}
+		if loc == nil {
+			continue
+		}
+		for _, value := range []*string{&ev.StartTime, &ev.EndTime} {
+			if t, err := time.Parse(time.RFC3339, *value); err == nil {
+				*value = t.In(loc).Format(time.RFC3339)
+			}
+		}
+	}
+}
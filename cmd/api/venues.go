@@ -0,0 +1,338 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAvailabilityRange bounds the date range of a venue availability query.
+const maxAvailabilityRange = 366 * 24 * time.Hour
+
+// availabilitySlot is a free period of a venue.
+type availabilitySlot struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// validateVenue checks the fields that depend on the venue kind.
+func validateVenue(v *database.Venue) fieldErrors {
+	fields := fieldErrors{}
+	if v.Kind == "" {
+		v.Kind = database.VenueKindPhysical
+	}
+	if v.Kind != database.VenueKindOnline && v.Address == "" {
+		fields["address"] = "is required for physical and hybrid venues"
+	}
+	if v.Kind != database.VenueKindPhysical && v.MeetingURL == "" {
+		fields["meeting_url"] = "is required for online and hybrid venues"
+	}
+	if (v.Latitude == nil) != (v.Longitude == nil) {
+		fields["latitude"] = "must be given together with longitude"
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+// loadVenue loads the venue named by the id path parameter, responding
+// with 400 or 404 if there is none.
+func (app *application) loadVenue(c *gin.Context) *database.Venue {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid venue ID"})
+		return nil
+	}
+	venue, err := app.models.Venues.Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue"})
+		return nil
+	}
+	if venue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Venue not found"})
+		return nil
+	}
+	return venue
+}
+
+// requireVenueManager lets the venue's creator and admins through. Venues
+// are shared between events, so event co-organizers do not manage them.
+func (app *application) requireVenueManager(c *gin.Context, venue *database.Venue) bool {
+	creator := 0
+	if venue.CreatedBy != nil {
+		creator = *venue.CreatedBy
+	}
+	return app.requireOwnerOrAdmin(c, creator)
+}
+
+// checkEventVenue checks that the venue ev links to exists and holds its
+// capacity, and fills in ev's location. It responds with 400 and returns
+// false otherwise.
+func (app *application) checkEventVenue(c *gin.Context, ev *database.Event) bool {
+	ev.Location = ""
+	if ev.VenueID == nil {
+		return true
+	}
+	venue, err := app.models.Venues.Get(*ev.VenueID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue"})
+		return false
+	}
+	if venue == nil {
+		invalidRequest(c, fieldErrors{"venue_id": "does not exist"})
+		return false
+	}
+	if venue.Capacity != nil && ev.Capacity != nil && *ev.Capacity > *venue.Capacity {
+		invalidRequest(c, fieldErrors{"capacity": "must be at most the venue capacity of " + strconv.Itoa(*venue.Capacity)})
+		return false
+	}
+	ev.Location = venue.Location()
+	return true
+}
+
+// @Summary Create a venue
+// @Description Create a physical, online or hybrid venue events can be booked at. Physical and hybrid venues need an address, online and hybrid ones a meeting_url.
+// @Tags Venues
+// @Accept json
+// @Produce json
+// @Param venue body database.Venue true "Venue payload"
+// @Success 201 {object} database.Venue
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/venues [post]
+func (app *application) createVenue(c *gin.Context) {
+	var venue database.Venue
+	if err := c.ShouldBindJSON(&venue); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if fields := validateVenue(&venue); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+
+	user, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	venue.CreatedBy = &user.ID
+
+	if err := app.models.Venues.Insert(&venue); err != nil {
+		log.Printf("createVenue: db insert error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create venue"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, venue)
+}
+
+// @Summary List venues
+// @Description List venues ordered by name
+// @Tags Venues
+// @Produce json
+// @Param search query string false "Only venues whose name or address contains this"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/venues [get]
+func (app *application) listVenues(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	venues, total, err := app.models.Venues.List(c.Query("search"), limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("listVenues: db list error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venues"})
+		return
+	}
+	if venues == nil {
+		venues = []*database.Venue{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       venues,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// @Summary Get a venue
+// @Tags Venues
+// @Produce json
+// @Param id path int true "Venue ID"
+// @Success 200 {object} database.Venue
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/venues/{id} [get]
+func (app *application) getVenue(c *gin.Context) {
+	venue := app.loadVenue(c)
+	if venue == nil {
+		return
+	}
+	c.JSON(http.StatusOK, venue)
+}
+
+// @Summary Update a venue
+// @Description Update a venue (its creator or an admin)
+// @Tags Venues
+// @Accept json
+// @Produce json
+// @Param id path int true "Venue ID"
+// @Param venue body database.Venue true "Venue payload"
+// @Success 200 {object} database.Venue
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/venues/{id} [put]
+func (app *application) updateVenue(c *gin.Context) {
+	existing := app.loadVenue(c)
+	if existing == nil || !app.requireVenueManager(c, existing) {
+		return
+	}
+
+	var venue database.Venue
+	if err := c.ShouldBindJSON(&venue); err != nil {
+		invalidRequest(c, bindingErrors(err))
+		return
+	}
+	if fields := validateVenue(&venue); fields != nil {
+		invalidRequest(c, fields)
+		return
+	}
+
+	venue.ID = existing.ID
+	if err := app.models.Venues.Update(&venue); err != nil {
+		log.Printf("updateVenue: db update error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update venue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, venue)
+}
+
+// @Summary Delete a venue
+// @Description Delete a venue no event uses (its creator or an admin)
+// @Tags Venues
+// @Param id path int true "Venue ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/venues/{id} [delete]
+func (app *application) deleteVenue(c *gin.Context) {
+	venue := app.loadVenue(c)
+	if venue == nil || !app.requireVenueManager(c, venue) {
+		return
+	}
+
+	if err := app.models.Venues.Delete(venue.ID); err != nil {
+		if err == database.ErrVenueInUse {
+			c.JSON(http.StatusConflict, gin.H{"error": "The venue is used by events"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete venue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Venue deleted successfully"})
+}
+
+// @Summary Venue availability
+// @Description List the bookings of a venue in a date range and the free periods between them. Bookings by events the caller may not see are listed without event details.
+// @Tags Venues
+// @Produce json
+// @Param id path int true "Venue ID"
+// @Param from query string false "Start of the range (YYYY-MM-DD or RFC 3339, default: now)"
+// @Param to query string false "End of the range (YYYY-MM-DD or RFC 3339, default: 30 days after from, at most a year)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/venues/{id}/availability [get]
+func (app *application) getVenueAvailability(c *gin.Context) {
+	venue := app.loadVenue(c)
+	if venue == nil {
+		return
+	}
+
+	from, to, ok := availabilityRange(c)
+	if !ok {
+		return
+	}
+
+	bookings, err := app.models.Venues.Bookings(venue.ID, from.Format(time.RFC3339), to.Format(time.RFC3339), app.viewer(c))
+	if err != nil {
+		log.Printf("getVenueAvailability: db bookings error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve venue availability"})
+		return
+	}
+	if bookings == nil {
+		bookings = []*database.VenueBooking{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"venue_id": venue.ID,
+		"from":     from.Format(time.RFC3339),
+		"to":       to.Format(time.RFC3339),
+		"booked":   bookings,
+		"free":     freeSlots(from, to, bookings),
+	})
+}
+
+// availabilityRange reads the from and to query parameters in UTC.
+func availabilityRange(c *gin.Context) (from, to time.Time, ok bool) {
+	from = time.Now().UTC().Truncate(time.Second)
+	if v, valid := parseDateParam(c.Query("from"), false); !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+		return from, to, false
+	} else if v != "" {
+		from, _ = time.Parse(time.RFC3339, v)
+	}
+
+	to = from.Add(30 * 24 * time.Hour)
+	if v, valid := parseDateParam(c.Query("to"), true); !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+		return from, to, false
+	} else if v != "" {
+		to, _ = time.Parse(time.RFC3339, v)
+	}
+
+	if !to.After(from) || to.Sub(from) > maxAvailabilityRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most a year later"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// freeSlots returns the periods between from and to not covered by
+// bookings, which are ordered by start time.
+func freeSlots(from, to time.Time, bookings []*database.VenueBooking) []availabilitySlot {
+	slots := []availabilitySlot{}
+	cursor := from
+	for _, b := range bookings {
+		start, err1 := time.Parse(time.RFC3339, b.StartTime)
+		end, err2 := time.Parse(time.RFC3339, b.EndTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start.After(cursor) {
+			slots = append(slots, availabilitySlot{cursor.Format(time.RFC3339), start.UTC().Format(time.RFC3339)})
+		}
+		if end.After(cursor) {
+			cursor = end.UTC()
+		}
+	}
+	if to.After(cursor) {
+		slots = append(slots, availabilitySlot{cursor.Format(time.RFC3339), to.Format(time.RFC3339)})
+	}
+	return slots
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestVenues(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	other := insertUserWithPassword(t, app, "other@example.com", "password123")
+	otherToken, _ := jwtForUser(app, other.ID)
+
+	// Kind-dependent fields are validated
+	code, body := api.do("POST", "/api/v1/venues", ownerToken, map[string]interface{}{"name": "Zoom room", "kind": "online"})
+	if code != http.StatusBadRequest || !strings.Contains(string(body), `"meeting_url"`) {
+		t.Fatalf("online venue without meeting url: %d %s", code, body)
+	}
+	code, body = api.do("POST", "/api/v1/venues", ownerToken, map[string]interface{}{"name": "Hall", "address": "1 Main Street", "latitude": 95, "longitude": 18})
+	if code != http.StatusBadRequest || !strings.Contains(string(body), `"latitude"`) {
+		t.Fatalf("invalid latitude: %d %s", code, body)
+	}
+
+	code, body = api.do("POST", "/api/v1/venues", ownerToken, map[string]interface{}{
+		"name": "Convention Center", "address": "1 Main Street", "capacity": 100, "latitude": -33.92, "longitude": 18.42,
+		"kind": "hybrid", "meeting_url": "https://meet.example.com/hall",
+	})
+	var venue database.Venue
+	if code != http.StatusCreated || json.Unmarshal(body, &venue) != nil || venue.Kind != database.VenueKindHybrid || venue.CreatedBy == nil {
+		t.Fatalf("create venue: %d %s", code, body)
+	}
+	if code, _ := api.do("PUT", fmt.Sprintf("/api/v1/venues/%d", venue.ID), otherToken, map[string]interface{}{"name": "Mine now", "address": "2 Side Street"}); code != http.StatusForbidden {
+		t.Fatalf("updating someone else's venue: expected 403, got %d", code)
+	}
+	code, body = api.do("GET", "/api/v1/venues?search=main", "", nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"Convention Center"`) {
+		t.Fatalf("list venues: %d %s", code, body)
+	}
+
+	create := func(title, start, end string, extra map[string]interface{}) (int, database.Event) {
+		t.Helper()
+		event := map[string]interface{}{"title": title, "description": "Held at the convention center", "start_time": start, "end_time": end, "venue_id": venue.ID}
+		for k, v := range extra {
+			event[k] = v
+		}
+		code, body := api.do("POST", "/api/v1/events", ownerToken, event)
+		var ev database.Event
+		json.Unmarshal(body, &ev)
+		return code, ev
+	}
+	code, morning := create("Morning talk", "2030-05-01T09:00:00Z", "2030-05-01T11:00:00Z", nil)
+	if code != http.StatusCreated || morning.VenueID == nil || morning.Location != "Convention Center, 1 Main Street" {
+		t.Fatalf("create event at venue: %d %+v", code, morning)
+	}
+	if code, _ := create("Overlap", "2030-05-01T10:30:00+01:00", "2030-05-01T12:00:00+01:00", nil); code != http.StatusConflict {
+		t.Fatalf("overlapping booking: expected 409, got %d", code)
+	}
+	code, afternoon := create("Afternoon talk", "2030-05-01T11:00:00Z", "2030-05-01T12:00:00Z", nil)
+	if code != http.StatusCreated {
+		t.Fatalf("back-to-back booking: %d", code)
+	}
+	if code, _ := create("Too big", "2030-05-02T09:00:00Z", "2030-05-02T10:00:00Z", map[string]interface{}{"capacity": 500}); code != http.StatusBadRequest {
+		t.Fatalf("capacity above the venue's: expected 400, got %d", code)
+	}
+	if code, _ := create("Nowhere", "2030-05-02T09:00:00Z", "2030-05-02T10:00:00Z", map[string]interface{}{"venue_id": 999}); code != http.StatusBadRequest {
+		t.Fatalf("unknown venue: expected 400, got %d", code)
+	}
+
+	// Moving an event onto another booking is rejected too
+	update := map[string]interface{}{"user_id": owner.ID, "title": "Afternoon talk", "description": "Held at the convention center",
+		"start_time": "2030-05-01T10:00:00Z", "end_time": "2030-05-01T12:00:00Z", "venue_id": venue.ID}
+	if code, _ := api.do("PUT", fmt.Sprintf("/api/v1/events/%d", afternoon.ID), ownerToken, update); code != http.StatusConflict {
+		t.Fatalf("update onto a booking: expected 409, got %d", code)
+	}
+	update["start_time"] = "2030-05-01T13:00:00Z"
+	update["end_time"] = "2030-05-01T14:00:00Z"
+	if code, _ := api.do("PUT", fmt.Sprintf("/api/v1/events/%d", afternoon.ID), ownerToken, update); code != http.StatusOK {
+		t.Fatalf("move to a free slot: %d", code)
+	}
+
+	// Recurring events book the venue for every occurrence
+	code, _ = create("Daily standup", "2030-05-01T09:30:00Z", "2030-05-01T10:00:00Z", map[string]interface{}{"recurrence": "FREQ=DAILY;COUNT=5"})
+	if code != http.StatusConflict {
+		t.Fatalf("series clashing with a booking: expected 409, got %d", code)
+	}
+
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/venues/%d/availability?from=2030-05-01&to=2030-05-01", venue.ID), "", nil)
+	var avail struct {
+		Booked []database.VenueBooking `json:"booked"`
+		Free   []availabilitySlot      `json:"free"`
+	}
+	if code != http.StatusOK || json.Unmarshal(body, &avail) != nil {
+		t.Fatalf("availability: %d %s", code, body)
+	}
+	if len(avail.Booked) != 2 || avail.Booked[0].EventID == nil || *avail.Booked[0].EventID != morning.ID {
+		t.Fatalf("bookings: %s", body)
+	}
+	free := []availabilitySlot{
+		{"2030-05-01T00:00:00Z", "2030-05-01T09:00:00Z"},
+		{"2030-05-01T11:00:00Z", "2030-05-01T13:00:00Z"},
+		{"2030-05-01T14:00:00Z", "2030-05-01T23:59:59Z"},
+	}
+	if fmt.Sprint(avail.Free) != fmt.Sprint(free) {
+		t.Fatalf("free slots: %+v", avail.Free)
+	}
+	if code, _ := api.do("GET", fmt.Sprintf("/api/v1/venues/%d/availability?from=2030-05-01&to=2032-05-01", venue.ID), "", nil); code != http.StatusBadRequest {
+		t.Fatalf("availability range over a year: expected 400, got %d", code)
+	}
+
+	if code, _ := api.do("DELETE", fmt.Sprintf("/api/v1/venues/%d", venue.ID), ownerToken, nil); code != http.StatusConflict {
+		t.Fatalf("deleting a venue in use: expected 409, got %d", code)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-api-in-gin/internal/database"
+	"rest-api-in-gin/internal/mailer"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promoteWaitlist fills free seats of ev from its waitlist and notifies the
+// promoted users in the background. Failures are logged: the seats are
+// filled again on the next promotion or offer expiry sweep.
+func (app *application) promoteWaitlist(ev *database.Event) {
+	promoted, err := app.models.Attendees.PromoteWaitlist(ev.ID, app.waitlistOfferTTL)
+	if err != nil {
+		log.Printf("[WAITLIST] promote for event %d: %v", ev.ID, err)
+		return
+	}
+	for _, a := range promoted {
+		a := a
+		app.background(func() { app.sendWaitlistPromotionEmail(ev, a) })
+	}
+}
+
+func (app *application) sendWaitlistPromotionEmail(ev *database.Event, a *database.Attendee) {
+	user, err := app.models.Users.Get(a.UserID)
+	if err != nil || user == nil {
+		log.Printf("[MAIL] load user %d for waitlist promotion: %v", a.UserID, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/events/%d", app.appURL, ev.ID)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "A seat opened up for " + ev.Title,
+		Body: fmt.Sprintf("Hi %s,\n\nA seat opened up for %s and you are next on the waitlist, so it is yours.\n\n%s\n",
+			user.Name, ev.Title, link),
+	}
+	if a.Status == database.AttendeeStatusOffered && a.OfferExpiresAt != nil {
+		msg.Body = fmt.Sprintf("Hi %s,\n\nA seat opened up for %s and you are next on the waitlist. Confirm it before %s UTC or it goes to the next person in line.\n\n%s\n",
+			user.Name, ev.Title, a.OfferExpiresAt.UTC().Format("2006-01-02 15:04"), link)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := app.mailer.Send(ctx, msg); err != nil {
+		log.Printf("[MAIL] send waitlist promotion email to user %d: %v", user.ID, err)
+	}
+}
+
+// expireWaitlistOffers periodically releases seats offered to waitlisted
+// users who did not confirm in time and offers them to the next in line.
+func (app *application) expireWaitlistOffers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.sweepWaitlistOffers()
+	}
+}
+
+func (app *application) sweepWaitlistOffers() {
+	eventIDs, err := app.models.Attendees.EventsWithExpiredOffers()
+	if err != nil {
+		log.Printf("[WAITLIST] find expired offers: %v", err)
+		return
+	}
+	for _, id := range eventIDs {
+		ev, err := app.models.Events.Get(id)
+		if err != nil {
+			log.Printf("[WAITLIST] load event %d: %v", id, err)
+			continue
+		}
+		if ev != nil {
+			app.promoteWaitlist(ev)
+		}
+	}
+}
+
+// @Summary Confirm a waitlist seat
+// @Description Accept the seat offered to the authenticated user after being promoted from the waitlist. Offers not confirmed in time pass to the next person in line.
+// @Tags Attendees
+// @Param id path int true "Event ID"
+// @Param userId path int true "User ID (must be the authenticated user)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/events/{id}/attendees/{userId}/confirm [post]
+func (app *application) confirmWaitlistOffer(c *gin.Context) {
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tokenUser, err := app.getUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if tokenUser.ID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the invited user can confirm a seat"})
+		return
+	}
+
+	confirmed, err := app.models.Attendees.ConfirmOffer(eventID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm seat"})
+		return
+	}
+	if !confirmed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No open seat offer for this event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Seat confirmed"})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rest-api-in-gin/internal/database"
+)
+
+func TestWaitlistPromotion(t *testing.T) {
+	app, cleanup := setupAppWithTempDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(app.routes())
+	defer ts.Close()
+	api := apiClient{t, ts}
+
+	owner := insertUserWithPassword(t, app, "owner@example.com", "password123")
+	ownerToken, _ := jwtForUser(app, owner.ID)
+	event := map[string]interface{}{"user_id": owner.ID, "title": "Small dinner", "description": "Only one seat at the table", "start_time": "2030-01-01T19:00:00Z", "end_time": "2030-01-01T22:00:00Z", "capacity": 1}
+	code, body := api.do("POST", "/api/v1/events", ownerToken, event)
+	var ev database.Event
+	if code != http.StatusCreated || json.Unmarshal(body, &ev) != nil {
+		t.Fatalf("create: %d %s", code, body)
+	}
+
+	type member struct {
+		user  *database.User
+		token string
+	}
+	users := map[string]member{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		u := insertUserWithPassword(t, app, name+"@example.com", "password123")
+		token, _ := jwtForUser(app, u.ID)
+		users[name] = member{u, token}
+	}
+	join := func(name string) (status string, position int) {
+		t.Helper()
+		m := users[name]
+		code, body := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees?user_id=%d", ev.ID, m.user.ID), m.token, nil)
+		var resp struct {
+			Status           string `json:"status"`
+			WaitlistPosition int    `json:"waitlist_position"`
+		}
+		if code != http.StatusCreated || json.Unmarshal(body, &resp) != nil {
+			t.Fatalf("join %s: %d %s", name, code, body)
+		}
+		return resp.Status, resp.WaitlistPosition
+	}
+	leave := func(name string) {
+		t.Helper()
+		m := users[name]
+		if code, body := api.do("DELETE", fmt.Sprintf("/api/v1/events/%d/attendees/%d", ev.ID, m.user.ID), m.token, nil); code != http.StatusOK {
+			t.Fatalf("leave %s: %d %s", name, code, body)
+		}
+	}
+	// place returns name's status and waitlist position as shown in
+	// /attendees/:id/events
+	place := func(name string) (string, int) {
+		t.Helper()
+		m := users[name]
+		code, body := api.do("GET", fmt.Sprintf("/api/v1/attendees/%d/events", m.user.ID), m.token, nil)
+		var events []database.UserEvent
+		if code != http.StatusOK || json.Unmarshal(body, &events) != nil {
+			t.Fatalf("events of %s: %d %s", name, code, body)
+		}
+		if len(events) == 0 {
+			return "", 0
+		}
+		return events[0].Status, events[0].WaitlistPosition
+	}
+	expectMail := func(name, contains string) {
+		t.Helper()
+		to := users[name].user.Email
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-app.mailer.(*testMailer).sent:
+				if msg.To != to {
+					continue
+				}
+				if !strings.Contains(msg.Body, contains) {
+					t.Fatalf("mail to %s: %q does not contain %q", name, msg.Body, contains)
+				}
+				return
+			case <-timeout:
+				t.Fatalf("no mail sent to %s", name)
+			}
+		}
+	}
+
+	if status, _ := join("a"); status != database.AttendeeStatusPending {
+		t.Fatalf("a: expected a seat, got %q", status)
+	}
+	if status, pos := join("b"); status != database.AttendeeStatusWaitlisted || pos != 1 {
+		t.Fatalf("b: got %q at %d", status, pos)
+	}
+	if status, pos := join("c"); status != database.AttendeeStatusWaitlisted || pos != 2 {
+		t.Fatalf("c: got %q at %d", status, pos)
+	}
+	if status, pos := place("c"); status != database.AttendeeStatusWaitlisted || pos != 2 {
+		t.Fatalf("c listed as %q at %d", status, pos)
+	}
+	code, body = api.do("GET", fmt.Sprintf("/api/v1/events/%d/attendees", ev.ID), ownerToken, nil)
+	if code != http.StatusOK || strings.Contains(string(body), "b@example.com") {
+		t.Fatalf("waitlisted users must not be listed as attendees: %d %s", code, body)
+	}
+
+	// Without a confirmation window the next in line takes the seat
+	leave("a")
+	expectMail("b", "it is yours")
+	if status, _ := place("b"); status != database.AttendeeStatusPending {
+		t.Fatalf("b after promotion: %q", status)
+	}
+	if status, pos := place("c"); status != database.AttendeeStatusWaitlisted || pos != 1 {
+		t.Fatalf("c after promotion: %q at %d", status, pos)
+	}
+
+	// With a confirmation window the seat is only offered
+	app.waitlistOfferTTL = time.Hour
+	leave("b")
+	expectMail("c", "Confirm it before")
+	if status, _ := place("c"); status != database.AttendeeStatusOffered {
+		t.Fatalf("c after offer: %q", status)
+	}
+	if status, pos := join("d"); status != database.AttendeeStatusWaitlisted || pos != 1 {
+		t.Fatalf("d: got %q at %d", status, pos)
+	}
+
+	// An offer that is not confirmed in time moves on
+	expireOffer := func(name string, at time.Time) {
+		t.Helper()
+		if _, err := app.db.Exec(`UPDATE attendees SET offer_expires_at = ? WHERE user_id = ?`, at.UTC(), users[name].user.ID); err != nil {
+			t.Fatalf("expire offer: %v", err)
+		}
+	}
+	expireOffer("c", time.Now().Add(-time.Minute))
+	app.sweepWaitlistOffers()
+	expectMail("d", "Confirm it before")
+	if status, _ := place("c"); status != "" {
+		t.Fatalf("c should have lost the seat, got %q", status)
+	}
+	confirm := func(name string) int {
+		m := users[name]
+		code, _ := api.do("POST", fmt.Sprintf("/api/v1/events/%d/attendees/%d/confirm", ev.ID, m.user.ID), m.token, nil)
+		return code
+	}
+	if code := confirm("c"); code != http.StatusNotFound {
+		t.Fatalf("expired offer confirm: expected 404, got %d", code)
+	}
+	// Nor can an expired offer be taken up by changing the RSVP status
+	expireOffer("d", time.Now().Add(-time.Minute))
+	d := users["d"]
+	if code, body := api.do("PATCH", fmt.Sprintf("/api/v1/events/%d/attendees/%d", ev.ID, d.user.ID), d.token, map[string]string{"status": "confirmed"}); code != http.StatusConflict {
+		t.Fatalf("confirm an expired offer through PATCH: expected 409, got %d %s", code, body)
+	}
+	if status, _ := place("d"); status != database.AttendeeStatusOffered {
+		t.Fatalf("d after the rejected PATCH: %q", status)
+	}
+	expireOffer("d", time.Now().Add(time.Hour))
+	if code := confirm("d"); code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d", code)
+	}
+	if status, _ := place("d"); status != database.AttendeeStatusConfirmed {
+		t.Fatalf("d after confirming: %q", status)
+	}
+
+	// Raising the capacity promotes the waitlist too
+	app.waitlistOfferTTL = 0
+	if status, _ := join("e"); status != database.AttendeeStatusWaitlisted {
+		t.Fatalf("e: got %q", status)
+	}
+	event["capacity"] = 2
+	if code, body := api.do("PUT", fmt.Sprintf("/api/v1/events/%d", ev.ID), ownerToken, event); code != http.StatusOK {
+		t.Fatalf("raise capacity: %d %s", code, body)
+	}
+	expectMail("e", "it is yours")
+	if status, _ := place("e"); status != database.AttendeeStatusPending {
+		t.Fatalf("e after capacity increase: %q", status)
+	}
+}
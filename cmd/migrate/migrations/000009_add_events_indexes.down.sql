@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_events_user_id;
+DROP INDEX IF EXISTS idx_events_start_time;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_events_start_time ON events (start_time, id);
+CREATE INDEX IF NOT EXISTS idx_events_user_id ON events (user_id);
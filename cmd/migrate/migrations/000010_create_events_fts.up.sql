@@ -0,0 +1,25 @@
+-- Full-text index over event titles and descriptions.
+-- Requires SQLite built with FTS5 (go build -tags sqlite_fts5).
+CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(
+    title,
+    description,
+    content='events',
+    content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS events_fts_ai AFTER INSERT ON events BEGIN
+    INSERT INTO events_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_fts_ad AFTER DELETE ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_fts_au AFTER UPDATE OF title, description ON events BEGIN
+    INSERT INTO events_fts (events_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO events_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+-- Index events that existed before this migration
+INSERT INTO events_fts (events_fts) VALUES ('rebuild');
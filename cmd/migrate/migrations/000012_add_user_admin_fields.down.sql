@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_admin_audit_log_target;
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE users DROP COLUMN password_reset_required;
+ALTER TABLE users DROP COLUMN disabled_at;
@@ -0,0 +1,3 @@
+-- Bumped whenever a user's credentials change; tokens carrying an older
+-- version are rejected.
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
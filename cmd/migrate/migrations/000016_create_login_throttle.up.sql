@@ -0,0 +1,22 @@
+-- Failed login attempts per key ("account:<email>" or "ip:<address>").
+-- Keys for unknown emails are tracked too so lockouts do not reveal which
+-- accounts exist.
+CREATE TABLE IF NOT EXISTS login_throttle (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL CHECK (kind IN ('account', 'ip')),
+    subject TEXT NOT NULL,
+    user_id INTEGER,
+    ip TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_user_id ON login_lockouts (user_id);
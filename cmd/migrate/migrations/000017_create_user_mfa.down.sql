@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mfa_required_roles;
+DROP INDEX IF EXISTS idx_mfa_recovery_codes_user_id;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
@@ -0,0 +1,2 @@
+-- Maximum number of attendees; NULL means unlimited.
+ALTER TABLE events ADD COLUMN capacity INTEGER CHECK (capacity IS NULL OR capacity > 0);
@@ -0,0 +1,24 @@
+-- Waitlist for full events. SQLite cannot alter a CHECK constraint, so the
+-- table is rebuilt to allow the new statuses:
+--   waitlisted: queued in FIFO (id) order for a free seat
+--   offered:    promoted from the waitlist, holding a seat until
+--               offer_expires_at unless confirmed
+CREATE TABLE attendees_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('pending', 'confirmed', 'declined', 'waitlisted', 'offered')),
+    offer_expires_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO attendees_new (id, event_id, user_id, status, created_at, updated_at)
+SELECT id, event_id, user_id, status, created_at, updated_at FROM attendees;
+
+DROP TABLE attendees;
+ALTER TABLE attendees_new RENAME TO attendees;
+
+CREATE INDEX IF NOT EXISTS idx_attendees_event_status ON attendees (event_id, status);
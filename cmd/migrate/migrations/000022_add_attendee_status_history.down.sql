@@ -0,0 +1,22 @@
+DROP TABLE IF EXISTS attendee_status_history;
+
+CREATE TABLE attendees_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('pending', 'confirmed', 'declined', 'waitlisted', 'offered')),
+    offer_expires_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO attendees_old (id, event_id, user_id, status, offer_expires_at, created_at, updated_at)
+SELECT id, event_id, user_id, CASE WHEN status = 'rejected' THEN 'declined' ELSE status END, offer_expires_at, created_at, updated_at
+FROM attendees;
+
+DROP TABLE attendees;
+ALTER TABLE attendees_old RENAME TO attendees;
+
+CREATE INDEX IF NOT EXISTS idx_attendees_event_status ON attendees (event_id, status);
@@ -0,0 +1,40 @@
+-- RSVP lifecycle: organizers can reject attendees, so the attendees table is
+-- rebuilt to allow the 'rejected' status.
+CREATE TABLE attendees_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('pending', 'confirmed', 'declined', 'rejected', 'waitlisted', 'offered')),
+    offer_expires_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO attendees_new (id, event_id, user_id, status, offer_expires_at, created_at, updated_at)
+SELECT id, event_id, user_id, status, offer_expires_at, created_at, updated_at FROM attendees;
+
+DROP TABLE attendees;
+ALTER TABLE attendees_new RENAME TO attendees;
+
+CREATE INDEX IF NOT EXISTS idx_attendees_event_status ON attendees (event_id, status);
+
+-- Every RSVP status change. from_status is NULL when the user joins;
+-- changed_by is NULL for changes made by the system, such as waitlist
+-- promotion and offer expiry.
+CREATE TABLE IF NOT EXISTS attendee_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    changed_by INTEGER,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attendee_status_history_attendee ON attendee_status_history (event_id, user_id);
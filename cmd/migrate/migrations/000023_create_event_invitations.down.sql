@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_invite_links;
+DROP TABLE IF EXISTS event_invitations;
+ALTER TABLE events DROP COLUMN visibility;
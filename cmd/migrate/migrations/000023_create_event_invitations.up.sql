@@ -0,0 +1,39 @@
+-- Who may see an event: public events are listed to everyone, unlisted ones
+-- only open by ID and private ones only for invitees.
+ALTER TABLE events ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private'));
+
+-- Invitations addressed to one person. user_id stays NULL until someone
+-- with a verified email address claims the invitation.
+CREATE TABLE IF NOT EXISTS event_invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    invited_by INTEGER,
+    user_id INTEGER,
+    email TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    responded_at DATETIME,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_invitations_pending ON event_invitations (event_id, email) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_event_invitations_user ON event_invitations (user_id);
+CREATE INDEX IF NOT EXISTS idx_event_invitations_email ON event_invitations (email);
+
+-- Shareable invite links; max_uses NULL means unlimited.
+CREATE TABLE IF NOT EXISTS event_invite_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    created_by INTEGER,
+    max_uses INTEGER CHECK (max_uses IS NULL OR max_uses > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_invite_links_event ON event_invite_links (event_id);
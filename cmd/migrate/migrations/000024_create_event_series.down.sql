@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_series_occurrence;
+ALTER TABLE events DROP COLUMN occurrence_start;
+ALTER TABLE events DROP COLUMN series_id;
+DROP TABLE IF EXISTS event_series;
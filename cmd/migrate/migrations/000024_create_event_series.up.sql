@@ -0,0 +1,28 @@
+-- Recurring events. A series holds the recurrence rule and the template of
+-- its occurrences, which are stored as ordinary events up to
+-- materialized_until so each has its own RSVPs. Cancelled occurrences are
+-- deleted and never materialized again.
+CREATE TABLE IF NOT EXISTS event_series (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    rrule TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    capacity INTEGER CHECK (capacity IS NULL OR capacity > 0),
+    visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private')),
+    materialized_until DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_series_materialized_until ON event_series (materialized_until);
+
+-- occurrence_start is the start the rule gave an occurrence, which stays
+-- its key when the occurrence itself is moved.
+ALTER TABLE events ADD COLUMN series_id INTEGER REFERENCES event_series(id) ON DELETE CASCADE;
+ALTER TABLE events ADD COLUMN occurrence_start DATETIME;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_series_occurrence ON events (series_id, occurrence_start);
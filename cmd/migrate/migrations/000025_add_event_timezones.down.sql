@@ -0,0 +1,2 @@
+ALTER TABLE event_series DROP COLUMN timezone;
+ALTER TABLE events DROP COLUMN timezone;
@@ -0,0 +1,12 @@
+-- Event times are stored in UTC. timezone is the IANA time zone an event is
+-- planned in; recurring events are expanded in it so occurrences keep their
+-- local time across daylight saving changes.
+ALTER TABLE events ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE event_series ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- Normalize times stored with an offset to UTC. Values that are not valid
+-- date-times are left as they are.
+UPDATE events SET start_time = strftime('%Y-%m-%dT%H:%M:%SZ', start_time)
+WHERE strftime('%Y-%m-%dT%H:%M:%SZ', start_time) IS NOT NULL;
+UPDATE events SET end_time = strftime('%Y-%m-%dT%H:%M:%SZ', end_time)
+WHERE strftime('%Y-%m-%dT%H:%M:%SZ', end_time) IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_venue_start;
+ALTER TABLE event_series DROP COLUMN venue_id;
+ALTER TABLE events DROP COLUMN venue_id;
+DROP TABLE IF EXISTS venues;
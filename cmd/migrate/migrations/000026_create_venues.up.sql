@@ -0,0 +1,22 @@
+-- Places events take place at. Online and hybrid venues have a meeting URL;
+-- an event books its venue for its whole duration.
+CREATE TABLE IF NOT EXISTS venues (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_by INTEGER,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    capacity INTEGER CHECK (capacity IS NULL OR capacity > 0),
+    latitude REAL CHECK (latitude IS NULL OR latitude BETWEEN -90 AND 90),
+    longitude REAL CHECK (longitude IS NULL OR longitude BETWEEN -180 AND 180),
+    kind TEXT NOT NULL DEFAULT 'physical' CHECK (kind IN ('physical', 'online', 'hybrid')),
+    meeting_url TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+ALTER TABLE events ADD COLUMN venue_id INTEGER REFERENCES venues(id);
+ALTER TABLE event_series ADD COLUMN venue_id INTEGER REFERENCES venues(id);
+
+-- Finds the bookings of a venue that overlap a time range
+CREATE INDEX IF NOT EXISTS idx_events_venue_start ON events (venue_id, start_time);
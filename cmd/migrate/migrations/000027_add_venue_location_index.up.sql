@@ -0,0 +1,2 @@
+-- Bounding-box prefilter of nearby event searches
+CREATE INDEX IF NOT EXISTS idx_venues_location ON venues (latitude, longitude);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS series_tags;
+DROP INDEX IF EXISTS idx_event_tags_tag;
+DROP TABLE IF EXISTS event_tags;
+DROP TABLE IF EXISTS tags;
+DROP INDEX IF EXISTS idx_events_category;
+ALTER TABLE event_series DROP COLUMN category_id;
+ALTER TABLE events DROP COLUMN category_id;
+DROP TABLE IF EXISTS categories;
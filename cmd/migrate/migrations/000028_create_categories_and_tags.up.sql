@@ -0,0 +1,40 @@
+-- Topics events are grouped by. Admins manage the list; clients filter by slug.
+CREATE TABLE IF NOT EXISTS categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE events ADD COLUMN category_id INTEGER REFERENCES categories(id);
+ALTER TABLE event_series ADD COLUMN category_id INTEGER REFERENCES categories(id);
+
+CREATE INDEX IF NOT EXISTS idx_events_category ON events (category_id);
+
+-- Free-form tags, created on first use. Recurring events keep their tags in
+-- series_tags and copy them to each occurrence.
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS event_tags (
+    event_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (event_id, tag_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Finds the events with a tag
+CREATE INDEX IF NOT EXISTS idx_event_tags_tag ON event_tags (tag_id, event_id);
+
+CREATE TABLE IF NOT EXISTS series_tags (
+    series_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (series_id, tag_id),
+    FOREIGN KEY (series_id) REFERENCES event_series(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_event_status_history_event;
+DROP TABLE IF EXISTS event_status_history;
+DROP INDEX IF EXISTS idx_events_status_end;
+ALTER TABLE event_series DROP COLUMN status;
+ALTER TABLE events DROP COLUMN status;
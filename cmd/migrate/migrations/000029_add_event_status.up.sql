@@ -0,0 +1,25 @@
+-- Event lifecycle: draft -> published -> cancelled or completed. Existing
+-- events are published; the scheduler completes those that have ended.
+ALTER TABLE events ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published', 'cancelled', 'completed'));
+ALTER TABLE event_series ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published', 'cancelled'));
+
+-- Finds published events that have ended
+CREATE INDEX IF NOT EXISTS idx_events_status_end ON events (status, end_time);
+
+-- Every event status change. changed_by is NULL for changes made by the
+-- system, such as completing events that have ended.
+CREATE TABLE IF NOT EXISTS event_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_by INTEGER,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_status_history_event ON event_status_history (event_id);
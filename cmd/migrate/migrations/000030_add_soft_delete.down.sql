@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_events_deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE events DROP COLUMN deleted_at;
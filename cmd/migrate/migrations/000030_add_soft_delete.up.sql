@@ -0,0 +1,8 @@
+-- Deleted events and users are kept until the retention period has passed,
+-- so they can be restored. Every other query leaves them out.
+ALTER TABLE events ADD COLUMN deleted_at DATETIME;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+
+-- Finds rows due to be purged
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_event_roles_user;
+DROP TABLE IF EXISTS event_roles;
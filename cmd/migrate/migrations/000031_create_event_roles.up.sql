@@ -0,0 +1,17 @@
+-- The team running an event besides its owner, who stays events.user_id.
+-- Co-organizers edit the event and manage its attendees, check-in staff
+-- see the attendees and viewers see the event even if it is private.
+CREATE TABLE IF NOT EXISTS event_roles (
+    event_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('co_organizer', 'checkin_staff', 'viewer')),
+    granted_by INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (granted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_roles_user ON event_roles (user_id);
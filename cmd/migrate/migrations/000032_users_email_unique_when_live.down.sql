@@ -0,0 +1,26 @@
+-- Fails if an address was registered again after its account was deleted.
+CREATE TABLE users_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    password TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    role TEXT NOT NULL DEFAULT 'user' CHECK(role IN ('user', 'admin')),
+    disabled_at DATETIME,
+    password_reset_required INTEGER NOT NULL DEFAULT 0,
+    token_version INTEGER NOT NULL DEFAULT 0,
+    email_verified_at DATETIME,
+    deleted_at DATETIME
+);
+
+INSERT INTO users_old (id, email, name, password, created_at, updated_at, role, disabled_at, password_reset_required, token_version, email_verified_at, deleted_at)
+SELECT id, email, name, password, created_at, updated_at, role, disabled_at, password_reset_required, token_version, email_verified_at, deleted_at FROM users;
+
+DELETE FROM sqlite_sequence WHERE name = 'users_old';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'users_old', seq FROM sqlite_sequence WHERE name = 'users';
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,30 @@
+-- Deleted accounts give up their email address so it can be registered
+-- again. SQLite cannot drop a column constraint, so the table is rebuilt
+-- with uniqueness moved to a partial index over live accounts.
+CREATE TABLE users_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,
+    name TEXT NOT NULL,
+    password TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    role TEXT NOT NULL DEFAULT 'user' CHECK(role IN ('user', 'admin')),
+    disabled_at DATETIME,
+    password_reset_required INTEGER NOT NULL DEFAULT 0,
+    token_version INTEGER NOT NULL DEFAULT 0,
+    email_verified_at DATETIME,
+    deleted_at DATETIME
+);
+
+INSERT INTO users_new (id, email, name, password, created_at, updated_at, role, disabled_at, password_reset_required, token_version, email_verified_at, deleted_at)
+SELECT id, email, name, password, created_at, updated_at, role, disabled_at, password_reset_required, token_version, email_verified_at, deleted_at FROM users;
+
+-- Keep the ids of purged accounts from being handed out again
+DELETE FROM sqlite_sequence WHERE name = 'users_new';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'users_new', seq FROM sqlite_sequence WHERE name = 'users';
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,8 @@
+-- Marks the events deleted along with their owner's account, which come
+-- back when the account is restored. Events deleted on their own stay
+-- deleted.
+ALTER TABLE events ADD COLUMN deleted_with_user INTEGER NOT NULL DEFAULT 0;
+
+-- Until now those events shared their owner's deletion time
+UPDATE events SET deleted_with_user = 1
+WHERE deleted_at IS NOT NULL AND deleted_at = (SELECT deleted_at FROM users WHERE users.id = events.user_id);
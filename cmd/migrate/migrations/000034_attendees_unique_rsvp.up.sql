@@ -0,0 +1,6 @@
+-- One RSVP per user and event. Concurrent joins by the same user could
+-- insert two rows, both holding a seat; keep the earliest of each.
+DELETE FROM attendees
+WHERE id NOT IN (SELECT MIN(id) FROM attendees GROUP BY event_id, user_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attendees_event_user ON attendees (event_id, user_id);
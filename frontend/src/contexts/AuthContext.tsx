@@ -32,8 +32,9 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     if (storedToken && storedUser) {
       try {
         const decoded: TokenPayload = jwtDecode(storedToken);
-        // Check if token is expired
-        if (decoded.exp * 1000 > Date.now()) {
+        // Check if token is expired; an expired access token is refreshed
+        // on first use as long as a refresh token is stored
+        if (decoded.exp * 1000 > Date.now() || localStorage.getItem('refresh_token')) {
           setToken(storedToken);
           setUser(JSON.parse(storedUser));
         } else {
@@ -64,6 +65,7 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     setToken(response.token);
     setUser(userData);
     localStorage.setItem('token', response.token);
+    localStorage.setItem('refresh_token', response.refresh_token);
     localStorage.setItem('user', JSON.stringify(userData));
   };
 
@@ -74,9 +76,12 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
   };
 
   const logout = () => {
+    // Revoke the session server-side; local state is cleared regardless
+    authAPI.logout().catch(() => {});
     setToken(null);
     setUser(null);
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
   };
 
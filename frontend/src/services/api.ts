@@ -23,12 +23,45 @@ api.interceptors.request.use(
   }
 );
 
+// Access tokens are short-lived; a single in-flight refresh is shared by
+// every request that fails with 401 at the same time.
+let refreshPromise: Promise<string> | null = null;
+
+const refreshAccessToken = async (): Promise<string> => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) {
+    throw new Error('no refresh token');
+  }
+  const response = await axios.post<{ token: string; refresh_token: string }>(
+    `${API_BASE_URL}/auth/refresh`,
+    { refresh_token: refreshToken }
+  );
+  localStorage.setItem('token', response.data.token);
+  localStorage.setItem('refresh_token', response.data.refresh_token);
+  return response.data.token;
+};
+
 // Response interceptor to handle auth errors
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const original = error.config;
+    if (error.response?.status === 401 && original && !original._retry && !original.url?.startsWith('/auth/')) {
+      original._retry = true;
+      try {
+        refreshPromise = refreshPromise || refreshAccessToken();
+        const token = await refreshPromise;
+        original.headers.Authorization = `Bearer ${token}`;
+        return api(original);
+      } catch (refreshError) {
+        // fall through to logout
+      } finally {
+        refreshPromise = null;
+      }
+    }
     if (error.response?.status === 401) {
       localStorage.removeItem('token');
+      localStorage.removeItem('refresh_token');
       localStorage.removeItem('user');
       window.location.href = '/login';
     }
@@ -179,10 +212,14 @@ export const authAPI = {
   },
 
   login: async (email: string, password: string) => {
-    const response = await api.post<{ token: string; user: User }>('/auth/login', { email, password });
+    const response = await api.post<{ token: string; refresh_token: string; user: User }>('/auth/login', { email, password });
     return response.data;
   },
 
+  logout: async () => {
+    await api.post('/auth/logout');
+  },
+
   me: async () => {
     const response = await api.get<User>('/auth/me');
     return response.data;
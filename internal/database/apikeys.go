@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIKeyModel stores personal API keys used by machine clients instead of
+// a password login.
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+// APIKey is a named, scoped credential belonging to a user. The key itself is
+// only known when it is created.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var k APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	return &k, nil
+}
+
+// Insert stores a new key under keyHash, the SHA-256 hash of the key.
+func (m *APIKeyModel) Insert(key *APIKey, keyHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var expiresAt interface{}
+	if key.ExpiresAt != nil {
+		expiresAt = key.ExpiresAt.UTC()
+	}
+
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+			  RETURNING id, created_at`
+	return m.DB.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, keyHash, strings.Join(key.Scopes, " "), expiresAt).Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetActive returns the unrevoked, unexpired key hashed as keyHash, or nil
+// if there is none.
+func (m *APIKeyModel) GetActive(keyHash string) (*APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys
+			  WHERE key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`
+	key, err := scanAPIKey(m.DB.QueryRowContext(ctx, query, keyHash, time.Now().UTC()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListForUser returns every key of userID, newest first, including revoked
+// and expired ones.
+func (m *APIKeyModel) ListForUser(userID int) ([]*APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = ? ORDER BY id DESC`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CountActive returns how many unrevoked, unexpired keys userID has.
+func (m *APIKeyModel) CountActive(userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM api_keys WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`
+	var n int
+	err := m.DB.QueryRowContext(ctx, query, userID, time.Now().UTC()).Scan(&n)
+	return n, err
+}
+
+// Revoke revokes key id of userID. It reports false if the user has no such
+// unrevoked key.
+func (m *APIKeyModel) Revoke(userID, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	res, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// TouchLastUsed records that key id was just used. Updates are coarse, at
+// most once a minute, to keep authenticated requests from always writing.
+func (m *APIKeyModel) TouchLastUsed(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+	_, err := m.DB.ExecContext(ctx, query, now, id, now.Add(-time.Minute))
+	return err
+}
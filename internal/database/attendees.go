@@ -3,41 +3,416 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Attendee statuses. Pending, confirmed and offered attendees hold a seat;
+// offered ones only until OfferExpiresAt unless they confirm it.
+const (
+	AttendeeStatusPending    = "pending"
+	AttendeeStatusConfirmed  = "confirmed"
+	AttendeeStatusDeclined   = "declined"
+	AttendeeStatusRejected   = "rejected"
+	AttendeeStatusWaitlisted = "waitlisted"
+	AttendeeStatusOffered    = "offered"
+)
+
+// ValidAttendeeStatus reports whether status is one of the attendee statuses.
+func ValidAttendeeStatus(status string) bool {
+	switch status {
+	case AttendeeStatusPending, AttendeeStatusConfirmed, AttendeeStatusDeclined,
+		AttendeeStatusRejected, AttendeeStatusWaitlisted, AttendeeStatusOffered:
+		return true
+	}
+	return false
+}
+
+// Seated reports whether an attendee with status takes up a seat.
+func Seated(status string) bool {
+	return status == AttendeeStatusPending || status == AttendeeStatusConfirmed || status == AttendeeStatusOffered
+}
+
+// ErrStatusChanged is returned when an attendee's status changed between
+// reading and updating it.
+var ErrStatusChanged = errors.New("attendee status changed concurrently")
+
+// ErrOfferExpired is returned when an attendee takes up a seat offer after
+// it expired.
+var ErrOfferExpired = errors.New("seat offer expired")
+
+// ErrAlreadyAttending is returned when a user joins an event they already
+// have an RSVP for.
+var ErrAlreadyAttending = errors.New("user already has an RSVP for this event")
+
+// seatedStatuses is an SQL list of the statuses that take up a seat.
+const seatedStatuses = `('pending', 'confirmed', 'offered')`
+
 type Attendee struct {
-	ID        int    `json:"id"`
-	EventID   int    `json:"event_id" binding:"required"`
-	UserID    int    `json:"user_id" binding:"required"`
-	Status    string `json:"status,omitempty"`
-	CreatedAt string `json:"created_at,omitempty"`
-	UpdatedAt string `json:"updated_at,omitempty"`
+	ID      int    `json:"id"`
+	EventID int    `json:"event_id" binding:"required"`
+	UserID  int    `json:"user_id" binding:"required"`
+	Status  string `json:"status,omitempty"`
+	// WaitlistPosition is the 1-based place in the queue of a waitlisted
+	// attendee
+	WaitlistPosition int        `json:"waitlist_position,omitempty"`
+	OfferExpiresAt   *time.Time `json:"offer_expires_at,omitempty"`
+	CreatedAt        string     `json:"created_at,omitempty"`
+	UpdatedAt        string     `json:"updated_at,omitempty"`
 }
 
+// Insert adds attendee to an event, returning ErrEventFull if the event has
+// reached its capacity, ErrEventClosed if it is not published,
+// ErrEventNotFound if it has been deleted and ErrAlreadyAttending if the
+// user already has an RSVP for it.
 func (m *AttendeeModel) Insert(attendee *Attendee) (int, error) {
+	return m.insert(attendee, false)
+}
+
+// Join adds attendee to an event like Insert, but puts them on the waitlist
+// instead of failing when the event is full. attendee.Status and
+// attendee.WaitlistPosition report the outcome.
+func (m *AttendeeModel) Join(attendee *Attendee) (int, error) {
+	return m.insert(attendee, true)
+}
+
+func (m *AttendeeModel) insert(attendee *Attendee, waitlist bool) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO attendees (event_id, user_id, status) VALUES (?, ?, ?)`
-	res, err := m.DB.ExecContext(ctx, query, attendee.EventID, attendee.UserID, "pending")
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
+	defer tx.Rollback()
+
+	if err := joinTx(ctx, tx, attendee, waitlist, 0, ""); err != nil {
 		return 0, err
 	}
-	return int(id), nil
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return attendee.ID, nil
+}
+
+// joinTx adds attendee to an event within tx, recording changedBy and
+// reason in the status history. Unless it is the first write of tx the
+// caller must already hold SQLite's write lock, or concurrent joins could
+// both take the last seat.
+func joinTx(ctx context.Context, tx *sql.Tx, attendee *Attendee, waitlist bool, changedBy int, reason string) error {
+	// The seat count is part of the INSERT, the transaction's first
+	// statement, so SQLite takes the write lock before counting and
+	// concurrent RSVPs cannot both see the last free seat. A seat is only
+	// free if nobody is queued for it. Seats of deleted accounts keep
+	// counting until the account is purged, so restoring it cannot
+	// overbook the event; their place in the queue does not hold anyone up.
+	hasSeat := `(e.capacity IS NULL OR e.capacity > (SELECT COUNT(*) FROM attendees WHERE event_id = e.id AND status IN ` + seatedStatuses + `))
+			  AND NOT EXISTS (SELECT 1 FROM attendees w JOIN users u ON u.id = w.user_id WHERE w.event_id = e.id AND w.status = 'waitlisted' AND u.deleted_at IS NULL)`
+	query := `INSERT INTO attendees (event_id, user_id, status)
+			  SELECT e.id, ?, CASE WHEN ` + hasSeat + ` THEN 'pending' ELSE 'waitlisted' END FROM events e
+			  WHERE e.id = ? AND e.deleted_at IS NULL AND e.status = 'published' AND (? OR ` + hasSeat + `)
+			  RETURNING id, status`
+	err := tx.QueryRowContext(ctx, query, attendee.UserID, attendee.EventID, waitlist).Scan(&attendee.ID, &attendee.Status)
+	if err == sql.ErrNoRows {
+		var status string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM events WHERE id = ? AND deleted_at IS NULL`, attendee.EventID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return ErrEventNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status != EventStatusPublished {
+			return ErrEventClosed
+		}
+		return ErrEventFull
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrAlreadyAttending
+		}
+		return err
+	}
+
+	if err := recordStatusChange(ctx, tx, attendee.EventID, attendee.UserID, "", attendee.Status, changedBy, reason); err != nil {
+		return err
+	}
+
+	if attendee.Status == AttendeeStatusWaitlisted {
+		query := `SELECT COUNT(*) FROM attendees w JOIN users u ON u.id = w.user_id
+				  WHERE w.event_id = ? AND w.status = 'waitlisted' AND w.id <= ? AND u.deleted_at IS NULL`
+		if err := tx.QueryRowContext(ctx, query, attendee.EventID, attendee.ID).Scan(&attendee.WaitlistPosition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PromoteWaitlist fills an event's free seats from its waitlist in FIFO
+// order, after releasing seats whose offers have expired. Only published
+// events that are not deleted promote anyone, and deleted accounts are
+// passed over. With a positive
+// offerTTL promoted attendees get an offer they must confirm within it;
+// otherwise they take the seat straight away. It returns the promoted
+// attendees.
+func (m *AttendeeModel) PromoteWaitlist(eventID int, offerTTL time.Duration) ([]*Attendee, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	expired, err := tx.QueryContext(ctx, `DELETE FROM attendees WHERE event_id = ? AND status = 'offered' AND offer_expires_at <= ? RETURNING user_id`, eventID, now)
+	if err != nil {
+		return nil, err
+	}
+	var expiredUsers []int
+	for expired.Next() {
+		var userID int
+		if err := expired.Scan(&userID); err != nil {
+			expired.Close()
+			return nil, err
+		}
+		expiredUsers = append(expiredUsers, userID)
+	}
+	expired.Close()
+	if err := expired.Err(); err != nil {
+		return nil, err
+	}
+	for _, userID := range expiredUsers {
+		if err := recordStatusChange(ctx, tx, eventID, userID, AttendeeStatusOffered, "expired", 0, "offer not confirmed in time"); err != nil {
+			return nil, err
+		}
+	}
+
+	status, expiresAt := AttendeeStatusPending, interface{}(nil)
+	if offerTTL > 0 {
+		status, expiresAt = AttendeeStatusOffered, now.Add(offerTTL)
+	}
+
+	// LIMIT -1 means no limit, for events without a capacity
+	query := `UPDATE attendees SET status = ?, offer_expires_at = ?, updated_at = datetime('now')
+			  WHERE id IN (
+				SELECT w.id FROM attendees w JOIN users u ON u.id = w.user_id
+				WHERE w.event_id = ? AND w.status = 'waitlisted' AND u.deleted_at IS NULL ORDER BY w.id
+				LIMIT (SELECT CASE WHEN e.status != 'published' OR e.deleted_at IS NOT NULL THEN 0 WHEN e.capacity IS NULL THEN -1
+					ELSE MAX(e.capacity - (SELECT COUNT(*) FROM attendees WHERE event_id = e.id AND status IN ` + seatedStatuses + `), 0) END
+					FROM events e WHERE e.id = ?)
+			  )
+			  RETURNING id, event_id, user_id, status, offer_expires_at`
+	rows, err := tx.QueryContext(ctx, query, status, expiresAt, eventID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promoted []*Attendee
+	for rows.Next() {
+		var a Attendee
+		if err := rows.Scan(&a.ID, &a.EventID, &a.UserID, &a.Status, &a.OfferExpiresAt); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, a := range promoted {
+		if err := recordStatusChange(ctx, tx, eventID, a.UserID, AttendeeStatusWaitlisted, a.Status, 0, "promoted from waitlist"); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	sort.Slice(promoted, func(i, j int) bool { return promoted[i].ID < promoted[j].ID })
+	return promoted, nil
+}
+
+// EventsWithExpiredOffers returns the events holding seats for waitlist
+// offers that were not confirmed in time.
+func (m *AttendeeModel) EventsWithExpiredOffers() ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT DISTINCT event_id FROM attendees WHERE status = 'offered' AND offer_expires_at <= ?`
+	rows, err := m.DB.QueryContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ConfirmOffer accepts the seat offered to userID. It reports false if the
+// user has no unexpired offer for the event.
+func (m *AttendeeModel) ConfirmOffer(eventID, userID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE attendees SET status = 'confirmed', offer_expires_at = NULL, updated_at = datetime('now')
+			  WHERE event_id = ? AND user_id = ? AND status = 'offered' AND offer_expires_at > ?`
+	res, err := tx.ExecContext(ctx, query, eventID, userID, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+	if err := recordStatusChange(ctx, tx, eventID, userID, AttendeeStatusOffered, AttendeeStatusConfirmed, userID, ""); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SetStatus moves an attendee from status from to status to, recording who
+// made the change and why. Moving into a seated status from one that is not
+// needs a free seat, otherwise ErrEventFull is returned. An offered seat can
+// only be taken up before the offer expires, otherwise ErrOfferExpired is
+// returned. ErrStatusChanged is returned if the attendee's status is no
+// longer from.
+func (m *AttendeeModel) SetStatus(eventID, userID int, from, to string, changedBy int, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE attendees SET status = ?, offer_expires_at = NULL, updated_at = datetime('now')
+			  WHERE event_id = ? AND user_id = ? AND status = ?`
+	args := []interface{}{to, eventID, userID, from}
+	needsSeat := Seated(to) && !Seated(from)
+	if needsSeat {
+		query += ` AND (SELECT capacity IS NULL OR capacity > (SELECT COUNT(*) FROM attendees WHERE event_id = events.id AND status IN ` + seatedStatuses + `)
+				  FROM events WHERE id = ?)`
+		args = append(args, eventID)
+	}
+	claimsOffer := from == AttendeeStatusOffered && Seated(to)
+	if claimsOffer {
+		query += ` AND offer_expires_at > ?`
+		args = append(args, time.Now().UTC())
+	}
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var current string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM attendees WHERE event_id = ? AND user_id = ?`, eventID, userID).Scan(&current)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if current == from && needsSeat {
+			return ErrEventFull
+		}
+		if current == from && claimsOffer {
+			return ErrOfferExpired
+		}
+		return ErrStatusChanged
+	}
+
+	if err := recordStatusChange(ctx, tx, eventID, userID, from, to, changedBy, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AttendeeStatusChange is an entry in an attendee's status history.
+type AttendeeStatusChange struct {
+	ID         int    `json:"id"`
+	FromStatus string `json:"from_status,omitempty"`
+	ToStatus   string `json:"to_status"`
+	// ChangedBy is the user who made the change; nil for changes made by
+	// the system, such as waitlist promotion and joining
+	ChangedBy *int   `json:"changed_by"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// recordStatusChange appends to an attendee's status history. An empty from
+// marks the user joining and a zero changedBy a change made by the system.
+func recordStatusChange(ctx context.Context, tx *sql.Tx, eventID, userID int, from, to string, changedBy int, reason string) error {
+	var fromArg, byArg interface{}
+	if from != "" {
+		fromArg = from
+	}
+	if changedBy != 0 {
+		byArg = changedBy
+	}
+	query := `INSERT INTO attendee_status_history (event_id, user_id, from_status, to_status, changed_by, reason) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, eventID, userID, fromArg, to, byArg, reason)
+	return err
+}
+
+// StatusHistory returns the status changes of userID's RSVP to an event,
+// oldest first.
+func (m *AttendeeModel) StatusHistory(eventID, userID int) ([]*AttendeeStatusChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, COALESCE(from_status, ''), to_status, changed_by, reason, created_at FROM attendee_status_history
+			  WHERE event_id = ? AND user_id = ? ORDER BY id`
+	rows, err := m.DB.QueryContext(ctx, query, eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*AttendeeStatusChange
+	for rows.Next() {
+		var ch AttendeeStatusChange
+		if err := rows.Scan(&ch.ID, &ch.FromStatus, &ch.ToStatus, &ch.ChangedBy, &ch.Reason, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
 }
 
 func (m *AttendeeModel) Get(eventID, userID int) (*Attendee, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, event_id, user_id FROM attendees WHERE event_id = ? AND user_id = ?`
+	query := `SELECT id, event_id, user_id, status, offer_expires_at, created_at, updated_at FROM attendees WHERE event_id = ? AND user_id = ?`
 	var a Attendee
-	err := m.DB.QueryRowContext(ctx, query, eventID, userID).Scan(&a.ID, &a.EventID, &a.UserID)
+	err := m.DB.QueryRowContext(ctx, query, eventID, userID).Scan(&a.ID, &a.EventID, &a.UserID, &a.Status, &a.OfferExpiresAt, &a.CreatedAt, &a.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -47,29 +422,90 @@ func (m *AttendeeModel) Get(eventID, userID int) (*Attendee, error) {
 	return &a, nil
 }
 
-func (m *AttendeeModel) GetEventAttendees(eventID int) ([]*User, error) {
+// EventAttendee is a user attending an event, with their RSVP status.
+type EventAttendee struct {
+	User
+	Status string `json:"status"`
+}
+
+// attendeeStatusFilter returns the condition selecting attendees a with
+// status, or everyone but the waitlist if status is empty.
+func attendeeStatusFilter(status string) (string, []interface{}) {
+	if status == "" {
+		return "a.status != 'waitlisted'", nil
+	}
+	return "a.status = ?", []interface{}{status}
+}
+
+// GetEventAttendees returns the attendees of an event with the given status,
+// or all but the waitlisted ones if status is empty. Deleted accounts are
+// left out.
+func (m *AttendeeModel) GetEventAttendees(eventID int, status string) ([]*EventAttendee, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	cond, condArgs := attendeeStatusFilter(status)
+	query := `SELECT u.id, u.email, u.name, a.status FROM users u JOIN attendees a ON u.id = a.user_id
+			  WHERE a.event_id = ? AND u.deleted_at IS NULL AND ` + cond
+	rows, err := m.DB.QueryContext(ctx, query, append([]interface{}{eventID}, condArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attendees []*EventAttendee
+	for rows.Next() {
+		var a EventAttendee
+		if err := rows.Scan(&a.ID, &a.Email, &a.Name, &a.Status); err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attendees, nil
+}
+
+// GetEventAttendeesPage returns up to limit attendees of an event with the
+// given status (see GetEventAttendees) ordered by user id, starting from
+// cursor (nil for the first page).
+func (m *AttendeeModel) GetEventAttendeesPage(eventID int, status string, cursor *Cursor, limit int) ([]*EventAttendee, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT u.id, u.email, u.name FROM users u JOIN attendees a ON u.id = a.user_id WHERE a.event_id = ?`
-	rows, err := m.DB.QueryContext(ctx, query, eventID)
+	cond, condArgs := attendeeStatusFilter(status)
+	query := `SELECT u.id, u.email, u.name, a.status FROM users u JOIN attendees a ON u.id = a.user_id
+			  WHERE a.event_id = ? AND u.deleted_at IS NULL AND ` + cond
+	args := append([]interface{}{eventID}, condArgs...)
+	cond, cursorArgs, dir := keysetClause("", "u.id", cursor)
+	if cond != "" {
+		query += " AND " + cond
+		args = append(args, cursorArgs...)
+	}
+	query += " ORDER BY u.id " + dir + " LIMIT ?"
+
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var users []*User
+	var attendees []*EventAttendee
 	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.ID, &u.Email, &u.Name); err != nil {
+		var a EventAttendee
+		if err := rows.Scan(&a.ID, &a.Email, &a.Name, &a.Status); err != nil {
 			return nil, err
 		}
-		users = append(users, &u)
+		attendees = append(attendees, &a)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return users, nil
+	if cursor != nil && cursor.Before {
+		reverseRows(attendees)
+	}
+	return attendees, nil
 }
 
 func (m *AttendeeModel) Delete(eventID, userID int) (bool, error) {
@@ -88,28 +524,96 @@ func (m *AttendeeModel) Delete(eventID, userID int) (bool, error) {
 	return ra > 0, nil
 }
 
-func (m *AttendeeModel) GetEventsForUser(userID int) ([]*Event, error) {
+// UserEvent is an event together with a user's place in it.
+type UserEvent struct {
+	Event
+	Status           string     `json:"status"`
+	WaitlistPosition int        `json:"waitlist_position,omitempty"`
+	OfferExpiresAt   *time.Time `json:"offer_expires_at,omitempty"`
+}
+
+// userEventColumns selects an event joined with attendees a, followed by the
+// attendee's status, waitlist position (0 unless waitlisted) and offer expiry.
+const userEventColumns = eventColumns + `,
+			  a.status,
+			  CASE WHEN a.status = 'waitlisted' THEN (SELECT COUNT(*) FROM attendees w JOIN users wu ON wu.id = w.user_id WHERE w.event_id = a.event_id AND w.status = 'waitlisted' AND w.id <= a.id AND wu.deleted_at IS NULL) ELSE 0 END,
+			  a.offer_expires_at`
+
+func scanUserEvent(row rowScanner) (*UserEvent, error) {
+	var ev UserEvent
+	err := row.Scan(append(eventFields(&ev.Event), &ev.Status, &ev.WaitlistPosition, &ev.OfferExpiresAt)...)
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// GetEventsForUser returns the events a user is attending or waitlisted
+// for, leaving out those not listed to v.
+func (m *AttendeeModel) GetEventsForUser(userID int, v Viewer) ([]*UserEvent, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT e.id, e.user_id, e.title, e.description, e.start_time, e.end_time, e.created_at, e.updated_at FROM events e
-			  JOIN attendees a ON e.id = a.event_id WHERE a.user_id = ?`
-	rows, err := m.DB.QueryContext(ctx, query, userID)
+	listed, args := listedFor(v)
+	query := `SELECT ` + userEventColumns + ` FROM events e
+			  JOIN attendees a ON e.id = a.event_id WHERE a.user_id = ? AND e.deleted_at IS NULL AND ` + listed
+	rows, err := m.DB.QueryContext(ctx, query, append([]interface{}{userID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var events []*Event
+	var events []*UserEvent
 	for rows.Next() {
-		var ev Event
-		if err := rows.Scan(&ev.ID, &ev.User_id, &ev.Title, &ev.Description, &ev.StartTime, &ev.EndTime, &ev.CreatedAt, &ev.UpdatedAt); err != nil {
+		ev, err := scanUserEvent(rows)
+		if err != nil {
 			return nil, err
 		}
-		events = append(events, &ev)
+		events = append(events, ev)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return events, nil
 }
+
+// GetEventsForUserPage returns up to limit events a user is attending ordered
+// by (start_time, id), starting from cursor (nil for the first page). Events
+// not listed to v are left out.
+func (m *AttendeeModel) GetEventsForUserPage(userID int, v Viewer, cursor *Cursor, limit int) ([]*UserEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	listed, listedArgs := listedFor(v)
+	query := `SELECT ` + userEventColumns + ` FROM events e
+			  JOIN attendees a ON e.id = a.event_id WHERE a.user_id = ? AND e.deleted_at IS NULL AND ` + listed
+	args := append([]interface{}{userID}, listedArgs...)
+	cond, cursorArgs, dir := keysetClause("e.start_time", "e.id", cursor)
+	if cond != "" {
+		query += " AND " + cond
+		args = append(args, cursorArgs...)
+	}
+	query += " ORDER BY e.start_time " + dir + ", e.id " + dir + " LIMIT ?"
+
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*UserEvent
+	for rows.Next() {
+		ev, err := scanUserEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if cursor != nil && cursor.Before {
+		reverseRows(events)
+	}
+	return events, nil
+}
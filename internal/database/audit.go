@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// AuditEntry records an administrative action and who performed it.
+type AuditEntry struct {
+	ID           int             `json:"id"`
+	AdminID      int             `json:"admin_id"`
+	Action       string          `json:"action"`
+	TargetUserID *int            `json:"target_user_id,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	CreatedAt    string          `json:"created_at"`
+}
+
+// Insert records an audit entry. details is marshalled to JSON and may be nil.
+func (m *AuditModel) Insert(adminID int, action string, targetUserID *int, details interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var detailsJSON sql.NullString
+	if details != nil {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = sql.NullString{String: string(b), Valid: true}
+	}
+
+	query := `INSERT INTO admin_audit_log (admin_id, action, target_user_id, details) VALUES (?, ?, ?, ?)`
+	_, err := m.DB.ExecContext(ctx, query, adminID, action, targetUserID, detailsJSON)
+	return err
+}
+
+// List returns the most recent audit entries first, optionally restricted to
+// entries about targetUserID (0 for all).
+func (m *AuditModel) List(targetUserID, limit, offset int) ([]*AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, admin_id, action, target_user_id, details, created_at FROM admin_audit_log`
+	var args []interface{}
+	if targetUserID > 0 {
+		query += ` WHERE target_user_id = ?`
+		args = append(args, targetUserID)
+	}
+	query += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var details sql.NullString
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.TargetUserID, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if details.Valid {
+			e.Details = json.RawMessage(details.String)
+		}
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
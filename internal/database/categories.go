@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// CategoryModel stores the topics events are grouped by.
+type CategoryModel struct {
+	DB *sql.DB
+}
+
+// Category is a topic managed by admins. Events name it by ID, listings
+// filter by slug.
+type Category struct {
+	ID          int    `json:"id"`
+	Slug        string `json:"slug" binding:"required,min=2,max=50" example:"workshops"`
+	Name        string `json:"name" binding:"required,min=2,max=100" example:"Workshops"`
+	Description string `json:"description" binding:"max=500"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// CategoryFacet is the number of events in a category.
+type CategoryFacet struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TagFacet is the number of events with a tag.
+type TagFacet struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// EventFacets counts the events matching a filter by category and tag.
+type EventFacets struct {
+	Categories []*CategoryFacet `json:"categories"`
+	Tags       []*TagFacet      `json:"tags"`
+}
+
+// maxTagFacets bounds the number of tags counted in EventFacets.
+const maxTagFacets = 50
+
+const categoryColumns = `id, slug, name, description, created_at, updated_at`
+
+func scanCategory(row rowScanner) (*Category, error) {
+	var c Category
+	if err := row.Scan(&c.ID, &c.Slug, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (m *CategoryModel) Insert(c *Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO categories (slug, name, description) VALUES (?, ?, ?) RETURNING id, created_at, updated_at`
+	return m.DB.QueryRowContext(ctx, query, c.Slug, c.Name, c.Description).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+}
+
+func (m *CategoryModel) Get(id int) (*Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	c, err := scanCategory(m.DB.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// List returns every category ordered by name.
+func (m *CategoryModel) List() ([]*Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*Category
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (m *CategoryModel) Update(c *Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE categories SET slug = ?, name = ?, description = ?, updated_at = datetime('now')
+			  WHERE id = ? RETURNING created_at, updated_at`
+	return m.DB.QueryRowContext(ctx, query, c.Slug, c.Name, c.Description, c.ID).Scan(&c.CreatedAt, &c.UpdatedAt)
+}
+
+// Delete removes a category. Its events and recurring events are left
+// without one.
+func (m *CategoryModel) Delete(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM categories WHERE id = ?`,
+		`UPDATE events SET category_id = NULL WHERE category_id = ?`,
+		`UPDATE event_series SET category_id = NULL WHERE category_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// tagList scans the comma-separated tags selected by eventColumns.
+type tagList []string
+
+func (l *tagList) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	}
+	*l = []string{}
+	if s != "" {
+		*l = strings.Split(s, ",")
+	}
+	return nil
+}
+
+// setTags replaces the tags linked to id in table, event_tags or
+// series_tags, creating tags that do not exist yet.
+func setTags(ctx context.Context, tx *sql.Tx, table string, id int, tags []string) error {
+	column := "event_id"
+	if table == "series_tags" {
+		column = "series_id"
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE `+column+` = ?`, id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, tag); err != nil {
+			return err
+		}
+		query := `INSERT OR IGNORE INTO ` + table + ` (` + column + `, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := tx.ExecContext(ctx, query, id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Facets counts the events matching f by category and by tag, most common
+// first. f's pagination, sort and cursor are ignored.
+func (m *EventModel) Facets(f EventFilter) (*EventFacets, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where, args := f.where()
+	facets := &EventFacets{Categories: []*CategoryFacet{}, Tags: []*TagFacet{}}
+
+	query := `SELECT c.slug, c.name, COUNT(*) FROM events e JOIN categories c ON c.id = e.category_id` +
+		where + ` GROUP BY c.id ORDER BY COUNT(*) DESC, c.name`
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var cf CategoryFacet
+		if err := rows.Scan(&cf.Slug, &cf.Name, &cf.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facets.Categories = append(facets.Categories, &cf)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT t.name, COUNT(*) FROM events e JOIN event_tags et ON et.event_id = e.id JOIN tags t ON t.id = et.tag_id` +
+		where + ` GROUP BY t.id ORDER BY COUNT(*) DESC, t.name LIMIT ?`
+	rows, err = m.DB.QueryContext(ctx, query, append(args, maxTagFacets)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tf TagFacet
+		if err := rows.Scan(&tf.Tag, &tf.Count); err != nil {
+			return nil, err
+		}
+		facets.Tags = append(facets.Tags, &tf)
+	}
+	return facets, rows.Err()
+}
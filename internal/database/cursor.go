@@ -0,0 +1,37 @@
+package database
+
+// Cursor is a keyset pagination position. Listings that support cursors are
+// ordered by (Key, ID) ascending; a cursor selects the rows strictly after
+// that position, or strictly before it when Before is set.
+type Cursor struct {
+	Key    string
+	ID     int
+	Before bool
+}
+
+// keysetClause returns the WHERE condition, its arguments and the ORDER BY
+// direction for paging from c over (keyCol, idCol). An empty keyCol pages
+// by idCol alone. Backward pages are read in descending order and must be
+// reversed by the caller with reverseRows.
+func keysetClause(keyCol, idCol string, c *Cursor) (cond string, args []interface{}, dir string) {
+	if c == nil {
+		return "", nil, "ASC"
+	}
+
+	op, dir := ">", "ASC"
+	if c.Before {
+		op, dir = "<", "DESC"
+	}
+
+	if keyCol == "" {
+		return idCol + " " + op + " ?", []interface{}{c.ID}, dir
+	}
+	return "(" + keyCol + ", " + idCol + ") " + op + " (?, ?)", []interface{}{c.Key, c.ID}, dir
+}
+
+// reverseRows restores ascending order after a backward keyset query.
+func reverseRows[T any](rows []T) {
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrEventOwnerChanged is returned when transferring an event that changed
+// owner in the meantime.
+var ErrEventOwnerChanged = errors.New("event owner changed concurrently")
+
+// Roles on an event's team. The owner is the event's user_id; everyone
+// else on the team has a row in event_roles.
+const (
+	EventRoleOwner        = "owner"
+	EventRoleCoOrganizer  = "co_organizer"
+	EventRoleCheckInStaff = "checkin_staff"
+	EventRoleViewer       = "viewer"
+)
+
+// teamMember is an SQL condition on events e that holds if the given user
+// is on e's team besides its owner.
+const teamMember = `EXISTS (SELECT 1 FROM event_roles r WHERE r.event_id = e.id AND r.user_id = ?)`
+
+// EventRoleModel stores who helps run each event.
+type EventRoleModel struct {
+	DB *sql.DB
+}
+
+// EventTeamMember is a user's role on an event.
+type EventTeamMember struct {
+	EventID   int    `json:"event_id"`
+	UserID    int    `json:"user_id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	GrantedBy *int   `json:"granted_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// Role returns the role userID holds on the team of event eventID, or ""
+// if they are not on it. The owner is not looked up here.
+func (m *EventRoleModel) Role(eventID, userID int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var role string
+	query := `SELECT role FROM event_roles WHERE event_id = ? AND user_id = ?`
+	err := m.DB.QueryRowContext(ctx, query, eventID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// List returns the team of ev: its owner first, then co-organizers,
+// check-in staff and viewers in the order they joined.
+func (m *EventRoleModel) List(ev *Event) ([]*EventTeamMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT member.* FROM (
+			  SELECT e.id AS event_id, u.id AS user_id, COALESCE(u.name, ''), u.email, 'owner' AS role, NULL, e.created_at AS created_at, e.updated_at, 0 AS rank
+			  FROM events e JOIN users u ON u.id = e.user_id WHERE e.id = ?
+			  UNION ALL
+			  SELECT r.event_id, u.id, COALESCE(u.name, ''), u.email, r.role, r.granted_by, r.created_at, r.updated_at,
+			  CASE r.role WHEN 'co_organizer' THEN 1 WHEN 'checkin_staff' THEN 2 ELSE 3 END
+			  FROM event_roles r JOIN users u ON u.id = r.user_id WHERE r.event_id = ? AND u.deleted_at IS NULL) member
+			  ORDER BY rank, created_at, user_id`
+	rows, err := m.DB.QueryContext(ctx, query, ev.ID, ev.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var team []*EventTeamMember
+	for rows.Next() {
+		var t EventTeamMember
+		var rank int
+		if err := rows.Scan(&t.EventID, &t.UserID, &t.Name, &t.Email, &t.Role, &t.GrantedBy, &t.CreatedAt, &t.UpdatedAt, &rank); err != nil {
+			return nil, err
+		}
+		team = append(team, &t)
+	}
+	return team, rows.Err()
+}
+
+// Set gives t.UserID the role t.Role on the team of event t.EventID,
+// replacing any role they held before.
+func (m *EventRoleModel) Set(t *EventTeamMember) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO event_roles (event_id, user_id, role, granted_by) VALUES (?, ?, ?, ?)
+			  ON CONFLICT (event_id, user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, updated_at = datetime('now')
+			  RETURNING created_at, updated_at`
+	return m.DB.QueryRowContext(ctx, query, t.EventID, t.UserID, t.Role, t.GrantedBy).Scan(&t.CreatedAt, &t.UpdatedAt)
+}
+
+// Remove takes userID off the team of event eventID. It reports false if
+// they were not on it.
+func (m *EventRoleModel) Remove(eventID, userID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, `DELETE FROM event_roles WHERE event_id = ? AND user_id = ?`, eventID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// TransferOwnership makes userID the owner of ev. The previous owner stays
+// on the team as a co-organizer, granted by grantedBy. It returns
+// ErrEventOwnerChanged if ev is no longer owned by ev.User_id.
+func (m *EventRoleModel) TransferOwnership(ev *Event, userID, grantedBy int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET user_id = ?, updated_at = datetime('now') WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, userID, ev.ID, ev.User_id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEventOwnerChanged
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM event_roles WHERE event_id = ? AND user_id = ?`, ev.ID, userID); err != nil {
+		return err
+	}
+	query = `INSERT INTO event_roles (event_id, user_id, role, granted_by) VALUES (?, ?, 'co_organizer', ?)
+			 ON CONFLICT (event_id, user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, updated_at = datetime('now')`
+	if _, err := tx.ExecContext(ctx, query, ev.ID, ev.User_id, grantedBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
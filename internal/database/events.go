@@ -3,9 +3,50 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrEventFull is returned when an event has no seats left.
+	ErrEventFull = errors.New("event full")
+	// ErrCapacityBelowAttendance is returned when an event's capacity would
+	// drop below its current number of attendees.
+	ErrCapacityBelowAttendance = errors.New("capacity is below the number of attendees")
+	// ErrEventClosed is returned when joining an event that is not
+	// published.
+	ErrEventClosed = errors.New("event is not open for registration")
+	// ErrEventStatusChanged is returned when an event's status changed
+	// between reading and updating it.
+	ErrEventStatusChanged = errors.New("event status changed concurrently")
+	// ErrEventNotFound is returned when an event has been deleted or
+	// purged in the meantime.
+	ErrEventNotFound = errors.New("event not found")
+)
+
+// Event visibilities. Unlisted events are left out of listings but can be
+// opened by anyone with their ID; private events are only shown to their
+// owner, admins, attendees and invitees.
+const (
+	EventVisibilityPublic   = "public"
+	EventVisibilityUnlisted = "unlisted"
+	EventVisibilityPrivate  = "private"
+)
+
+// Event statuses. Events start as drafts, which only their owner sees, or
+// published; published events end up cancelled or completed.
+const (
+	EventStatusDraft     = "draft"
+	EventStatusPublished = "published"
+	EventStatusCancelled = "cancelled"
+	EventStatusCompleted = "completed"
+)
+
+// editableStatuses is an SQL list of the statuses of events that can still
+// be edited.
+const editableStatuses = `('draft', 'published')`
+
 type EventModel struct {
 	DB *sql.DB
 }
@@ -15,34 +56,182 @@ type Event struct {
 	User_id     int    `json:"user_id"`
 	Title       string `json:"title" binding:"required,min=3,max=100"`
 	Description string `json:"description" binding:"required,min=10,max=500"`
-	StartTime   string `json:"start_time" binding:"required" example:"2024-12-31T23:59:59Z"`
-	EndTime     string `json:"end_time" binding:"required" example:"2024-12-31T23:59:59Z"`
-	CreatedAt   string `json:"created_at,omitempty"`
-	UpdatedAt   string `json:"updated_at,omitempty"`
+	// StartTime and EndTime are stored in UTC.
+	StartTime string `json:"start_time" binding:"required" example:"2024-12-31T23:59:59Z"`
+	EndTime   string `json:"end_time" binding:"required" example:"2024-12-31T23:59:59Z"`
+	// Timezone is the IANA time zone the event is planned in.
+	Timezone string `json:"timezone" binding:"omitempty,max=64" example:"Europe/Berlin"`
+	// Capacity is the maximum number of attendees; nil means unlimited.
+	Capacity   *int   `json:"capacity" binding:"omitempty,min=1,max=100000" example:"50"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public unlisted private" example:"public"`
+	// Status is set when creating an event, as draft or published, and
+	// changed through its own endpoint afterwards.
+	Status string `json:"status" binding:"omitempty,oneof=draft published" example:"published"`
+	// VenueID links the event to a venue, which it books for its duration.
+	// Location describes the venue and is read-only.
+	VenueID  *int   `json:"venue_id" example:"3"`
+	Location string `json:"location,omitempty"`
+	// CategoryID links the event to a category; Category is its slug and
+	// read-only. Tags are free-form and stored lower-case.
+	CategoryID *int     `json:"category_id" example:"2"`
+	Category   string   `json:"category,omitempty"`
+	Tags       []string `json:"tags" binding:"omitempty,max=10,dive,min=1,max=32" example:"go,docker"`
+	// Recurrence is the RRULE of the event's series. Setting it when
+	// creating an event creates a series of occurrences instead.
+	Recurrence string `json:"recurrence,omitempty" binding:"omitempty,max=255" example:"FREQ=WEEKLY;BYDAY=TU;COUNT=10"`
+	// SeriesID and OccurrenceStart identify an occurrence of a recurring
+	// event; OccurrenceStart is the start its rule gave it.
+	SeriesID        *int    `json:"series_id,omitempty"`
+	OccurrenceStart *string `json:"occurrence_start,omitempty"`
+	CreatedAt       string  `json:"created_at,omitempty"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
+}
+
+// eventColumns is the column list scanned by scanEvent, for queries that
+// alias events as e.
+const eventColumns = `e.id, e.user_id, e.title, e.description, e.start_time, e.end_time, e.timezone, e.capacity, e.visibility, e.status, e.venue_id,
+			  COALESCE((SELECT v.name || CASE WHEN v.address != '' THEN ', ' || v.address ELSE '' END FROM venues v WHERE v.id = e.venue_id), ''),
+			  e.category_id, COALESCE((SELECT c.slug FROM categories c WHERE c.id = e.category_id), ''),
+			  COALESCE((SELECT group_concat(t.name, ',' ORDER BY t.name) FROM event_tags et JOIN tags t ON t.id = et.tag_id WHERE et.event_id = e.id), ''),
+			  COALESCE((SELECT rrule FROM event_series s WHERE s.id = e.series_id), ''), e.series_id, e.occurrence_start, e.created_at, e.updated_at`
+
+// eventFields returns the scan destinations for eventColumns, so queries
+// selecting extra columns can append their own.
+func eventFields(ev *Event) []interface{} {
+	return []interface{}{&ev.ID, &ev.User_id, &ev.Title, &ev.Description, &ev.StartTime, &ev.EndTime, &ev.Timezone, &ev.Capacity, &ev.Visibility, &ev.Status, &ev.VenueID, &ev.Location,
+		&ev.CategoryID, &ev.Category, (*tagList)(&ev.Tags), &ev.Recurrence, &ev.SeriesID, &ev.OccurrenceStart, &ev.CreatedAt, &ev.UpdatedAt}
+}
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var ev Event
+	if err := row.Scan(eventFields(&ev)...); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// Viewer identifies who is looking at events. The zero value is an
+// anonymous visitor. Email is only set once verified, since invitations
+// sent to an address must not be claimable by an unverified account.
+type Viewer struct {
+	UserID int
+	Email  string
+	Admin  bool
+}
+
+// privateAccess is an SQL condition on events e that holds if v owns the
+// event, is on its team, attends it or holds an open or accepted invitation
+// to it.
+func privateAccess(v Viewer) (string, []interface{}) {
+	cond := `(e.user_id = ? OR ` + teamMember + `
+		OR EXISTS (SELECT 1 FROM attendees a WHERE a.event_id = e.id AND a.user_id = ?)
+		OR EXISTS (SELECT 1 FROM event_invitations i WHERE i.event_id = e.id AND i.status IN ('pending', 'accepted')
+			AND (i.user_id = ? OR (i.user_id IS NULL AND ? != '' AND i.email = ?))))`
+	return cond, []interface{}{v.UserID, v.UserID, v.UserID, v.UserID, v.Email, v.Email}
+}
+
+// draftAccess is an SQL condition on events e that holds if e is not a
+// draft or v owns it or is on its team.
+func draftAccess(v Viewer) (string, []interface{}) {
+	return "(e.status != 'draft' OR e.user_id = ? OR " + teamMember + ")", []interface{}{v.UserID, v.UserID}
+}
+
+// listedFor is an SQL condition on events e selecting the events listed to
+// v: public ones, plus unlisted and private ones v has access to. Admins see
+// everything. Drafts are only listed to their owner and team.
+func listedFor(v Viewer) (string, []interface{}) {
+	if v.UserID == 0 {
+		return "(e.visibility = 'public' AND e.status != 'draft')", nil
+	}
+	drafts, draftArgs := draftAccess(v)
+	if v.Admin {
+		return drafts, draftArgs
+	}
+	cond, args := privateAccess(v)
+	return "((e.visibility = 'public' OR " + cond + ") AND " + drafts + ")", append(args, draftArgs...)
+}
+
+// VisibleTo reports whether v may open ev. Drafts are only visible to
+// their owner and team; otherwise only private events are restricted.
+func (m *EventModel) VisibleTo(ev *Event, v Viewer) (bool, error) {
+	if ev.Status != EventStatusDraft && (ev.Visibility != EventVisibilityPrivate || v.Admin) {
+		return true, nil
+	}
+	if v.UserID == 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	cond, args := privateAccess(v)
+	if ev.Status == EventStatusDraft {
+		cond, args = draftAccess(v)
+	}
+	var visible bool
+	query := `SELECT EXISTS (SELECT 1 FROM events e WHERE e.id = ? AND e.deleted_at IS NULL AND ` + cond + `)`
+	err := m.DB.QueryRowContext(ctx, query, append([]interface{}{ev.ID}, args...)...).Scan(&visible)
+	return visible, err
 }
 
 func (m *EventModel) Insert(event *Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO events (user_id, title, description, start_time, end_time, created_at, updated_at)
-			  VALUES (?, ?, ?, ?, ?, datetime('now'), datetime('now'))`
+	if event.Visibility == "" {
+		event.Visibility = EventVisibilityPublic
+	}
+	if event.Timezone == "" {
+		event.Timezone = "UTC"
+	}
+	if event.Status == "" {
+		event.Status = EventStatusPublished
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The venue check is part of the INSERT so two bookings cannot race
+	query := `INSERT INTO events (user_id, title, description, start_time, end_time, timezone, capacity, visibility, status, venue_id, category_id, created_at, updated_at)
+			  SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now') WHERE NOT ` + venueClash
 
-	res, err := m.DB.ExecContext(ctx, query,
+	args := []interface{}{
 		event.User_id,
 		event.Title,
 		event.Description,
 		event.StartTime,
 		event.EndTime,
-	)
+		event.Timezone,
+		event.Capacity,
+		event.Visibility,
+		event.Status,
+		event.VenueID,
+		event.CategoryID,
+	}
+	res, err := tx.ExecContext(ctx, query, append(args, venueClashArgs(event)...)...)
 	if err != nil {
 		return err
 	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrVenueBooked
+		}
+		return err
+	}
 
 	id, err := res.LastInsertId()
 	if err != nil {
 		return err
 	}
+	if err := setTags(ctx, tx, "event_tags", int(id), event.Tags); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 	event.ID = int(id)
 	return nil
 }
@@ -51,7 +240,7 @@ func (m *EventModel) GetAll() ([]*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, user_id, title, description, start_time, end_time, created_at, updated_at FROM events ORDER BY start_time ASC`
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.deleted_at IS NULL ORDER BY e.start_time ASC`
 	rows, err := m.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -60,12 +249,11 @@ func (m *EventModel) GetAll() ([]*Event, error) {
 
 	var events []*Event
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.ID, &event.User_id, &event.Title, &event.Description, &event.StartTime, &event.EndTime, &event.CreatedAt, &event.UpdatedAt)
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -75,13 +263,171 @@ func (m *EventModel) GetAll() ([]*Event, error) {
 	return events, nil
 }
 
+// EventFilter describes the criteria used by EventModel.List. Zero values
+// mean "no filter" for every field except Limit, which must be positive.
+// When Cursor is set the listing is keyset-paginated on (start_time, id)
+// ascending; SortBy, SortDesc and Offset are ignored. Only events listed to
+// Viewer are returned.
+type EventFilter struct {
+	Viewer    Viewer
+	Search    string
+	StartFrom string
+	StartTo   string
+	OwnerID   int
+	SeriesID  int
+	// Categories are slugs; events in any of them match. Events match
+	// Tags if they have any of them, or all of them with AllTags.
+	Categories []string
+	Tags       []string
+	AllTags    bool
+	SortBy     string
+	SortDesc   bool
+	Limit      int
+	Offset     int
+	Cursor     *Cursor
+}
+
+// eventSortColumns whitelists the columns that may be used in ORDER BY.
+var eventSortColumns = map[string]string{
+	"start_time": "start_time",
+	"end_time":   "end_time",
+	"title":      "title",
+	"created_at": "created_at",
+}
+
+// ValidEventSort reports whether field can be used as EventFilter.SortBy.
+func ValidEventSort(field string) bool {
+	_, ok := eventSortColumns[field]
+	return ok
+}
+
+// where builds the WHERE clause selecting the events matching f's filters,
+// leaving out the cursor. Deleted events never match.
+func (f EventFilter) where() (string, []interface{}) {
+	listed, args := listedFor(f.Viewer)
+	conds := []string{"e.deleted_at IS NULL", listed}
+
+	if f.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(f.Search)) + "%"
+		conds = append(conds, `(LOWER(e.title) LIKE ? ESCAPE '\' OR LOWER(e.description) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if f.StartFrom != "" {
+		conds = append(conds, "e.start_time >= ?")
+		args = append(args, f.StartFrom)
+	}
+	if f.StartTo != "" {
+		conds = append(conds, "e.start_time <= ?")
+		args = append(args, f.StartTo)
+	}
+	if f.OwnerID > 0 {
+		conds = append(conds, "e.user_id = ?")
+		args = append(args, f.OwnerID)
+	}
+	if f.SeriesID > 0 {
+		conds = append(conds, "e.series_id = ?")
+		args = append(args, f.SeriesID)
+	}
+	if len(f.Categories) > 0 {
+		conds = append(conds, "e.category_id IN (SELECT id FROM categories WHERE slug IN ("+placeholders(len(f.Categories))+"))")
+		for _, slug := range f.Categories {
+			args = append(args, slug)
+		}
+	}
+	if len(f.Tags) > 0 {
+		tagged := `(SELECT COUNT(*) FROM event_tags et JOIN tags t ON t.id = et.tag_id
+			WHERE et.event_id = e.id AND t.name IN (` + placeholders(len(f.Tags)) + `))`
+		for _, tag := range f.Tags {
+			args = append(args, tag)
+		}
+		if f.AllTags {
+			conds = append(conds, tagged+" = ?")
+			args = append(args, len(f.Tags))
+		} else {
+			conds = append(conds, tagged+" > 0")
+		}
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// placeholders returns n comma-separated SQL parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// List returns one page of events matching f along with the total number of
+// matching events, so callers can build pagination metadata.
+func (m *EventModel) List(f EventFilter) ([]*Event, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where, args := f.where()
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM events e` + where
+	if err := m.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sortCol, ok := eventSortColumns[f.SortBy]
+	if !ok {
+		sortCol = "start_time"
+	}
+	dir := "ASC"
+	if f.SortDesc {
+		dir = "DESC"
+	}
+	offset := f.Offset
+
+	if f.Cursor != nil {
+		var cond string
+		var cursorArgs []interface{}
+		cond, cursorArgs, dir = keysetClause("e.start_time", "e.id", f.Cursor)
+		where += " AND " + cond
+		args = append(args, cursorArgs...)
+		sortCol, offset = "start_time", 0
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events e` +
+		where + ` ORDER BY e.` + sortCol + ` ` + dir + `, e.id ` + dir + ` LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, query, append(args, f.Limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if f.Cursor != nil && f.Cursor.Before {
+		reverseRows(events)
+	}
+
+	return events, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (m *EventModel) Get(id int) (*Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, user_id, title, description, start_time, end_time, created_at, updated_at FROM events WHERE id = ?`
-	var event Event
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&event.ID, &event.User_id, &event.Title, &event.Description, &event.StartTime, &event.EndTime, &event.CreatedAt, &event.UpdatedAt)
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.id = ? AND e.deleted_at IS NULL`
+	event, err := scanEvent(m.DB.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -89,23 +435,167 @@ func (m *EventModel) Get(id int) (*Event, error) {
 		return nil, err
 	}
 
-	return &event, nil
+	return event, nil
 }
 
+// Update saves event. It returns ErrEventNotFound if the event has been
+// deleted in the meantime, ErrVenueBooked if its venue is taken at its time
+// and ErrCapacityBelowAttendance if its capacity is below its attendance.
 func (m *EventModel) Update(event *Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `UPDATE events SET user_id = ?, title = ?, description = ?, start_time = ?, end_time = ?, updated_at = datetime('now') WHERE id = ?`
-	_, err := m.DB.ExecContext(ctx, query, event.User_id, event.Title, event.Description, event.StartTime, event.EndTime, event.ID)
-	return err
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The capacity and venue checks are part of the UPDATE so they cannot
+	// race with RSVPs and other bookings
+	query := `UPDATE events SET user_id = ?, title = ?, description = ?, start_time = ?, end_time = ?, timezone = ?, capacity = ?, visibility = ?,
+			  venue_id = ?, category_id = ?, updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NULL
+			  AND (? IS NULL OR ? >= (SELECT COUNT(*) FROM attendees WHERE event_id = events.id AND status IN ` + seatedStatuses + `))
+			  AND NOT ` + venueClash
+	args := []interface{}{event.User_id, event.Title, event.Description, event.StartTime, event.EndTime,
+		event.Timezone, event.Capacity, event.Visibility, event.VenueID, event.CategoryID, event.ID, event.Capacity, event.Capacity}
+	res, err := tx.ExecContext(ctx, query, append(args, venueClashArgs(event)...)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if err := setTags(ctx, tx, "event_tags", event.ID, event.Tags); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var live bool
+	query = `SELECT EXISTS (SELECT 1 FROM events WHERE id = ? AND deleted_at IS NULL)`
+	if err := tx.QueryRowContext(ctx, query, event.ID).Scan(&live); err != nil {
+		return err
+	}
+	if !live {
+		return ErrEventNotFound
+	}
+	var booked bool
+	query = `SELECT ` + venueClash
+	if err := tx.QueryRowContext(ctx, query, venueClashArgs(event)...).Scan(&booked); err != nil {
+		return err
+	}
+	if booked {
+		return ErrVenueBooked
+	}
+	if event.Capacity != nil {
+		return ErrCapacityBelowAttendance
+	}
+	return nil
 }
 
+// Delete marks an event deleted. It is left out of every query from then
+// on, until it is restored or purged.
 func (m *EventModel) Delete(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM events WHERE id = ?`
+	query := `UPDATE events SET deleted_at = datetime('now') WHERE id = ? AND deleted_at IS NULL`
 	_, err := m.DB.ExecContext(ctx, query, id)
 	return err
 }
+
+// GetDeleted returns a deleted event that has not been purged yet, or nil.
+func (m *EventModel) GetDeleted(id int) (*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.id = ? AND e.deleted_at IS NOT NULL`
+	event, err := scanEvent(m.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return event, err
+}
+
+// Restore undoes the deletion of ev. It returns ErrVenueBooked if ev's
+// venue has been booked for its time since, and ErrEventNotFound if ev is
+// no longer deleted because it was restored or purged in the meantime.
+func (m *EventModel) Restore(ev *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET deleted_at = NULL, deleted_with_user = 0, updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NOT NULL AND (status = 'cancelled' OR NOT ` + venueClash + `)`
+	res, err := tx.ExecContext(ctx, query, append([]interface{}{ev.ID}, venueClashArgs(ev)...)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var deleted bool
+		query = `SELECT EXISTS (SELECT 1 FROM events WHERE id = ? AND deleted_at IS NOT NULL)`
+		if err := tx.QueryRowContext(ctx, query, ev.ID).Scan(&deleted); err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrEventNotFound
+		}
+		return ErrVenueBooked
+	}
+	return tx.Commit()
+}
+
+// PurgeDeleted permanently removes the events deleted before cutoff, with
+// their RSVPs, invitations, roles, tags and history, and returns how many
+// there were. Series left without events go with them.
+func (m *EventModel) PurgeDeleted(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The connection does not enforce foreign keys, so the rows that would
+	// cascade from the events are removed here
+	purged := `SELECT id FROM events WHERE deleted_at <= datetime(?, 'unixepoch')`
+	for _, table := range []string{"attendees", "attendee_status_history", "event_status_history",
+		"event_invitations", "event_invite_links", "event_roles", "event_tags"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE event_id IN (`+purged+`)`, cutoff.Unix()); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id IN (`+purged+`)`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	orphaned := `SELECT id FROM event_series WHERE NOT EXISTS (SELECT 1 FROM events WHERE series_id = event_series.id)`
+	for _, query := range []string{
+		`DELETE FROM series_tags WHERE series_id IN (` + orphaned + `)`,
+		`DELETE FROM event_series WHERE id IN (` + orphaned + `)`,
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrLoginStateInvalid is returned for unknown, expired or already used
+// OpenID Connect login states.
+var ErrLoginStateInvalid = errors.New("invalid or expired login state")
+
+// IdentityModel links users to accounts at external identity providers and
+// tracks logins in progress with them.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// Identity is an external identity provider account linked to a user.
+type Identity struct {
+	ID          int     `json:"id"`
+	UserID      int     `json:"user_id"`
+	Provider    string  `json:"provider"`
+	Subject     string  `json:"subject"`
+	Email       *string `json:"email,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	LastLoginAt *string `json:"last_login_at,omitempty"`
+}
+
+// LoginState is a pending login with an identity provider.
+type LoginState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+}
+
+// GetUser returns the user linked to subject at provider, or nil if the
+// identity is not linked.
+func (m *IdentityModel) GetUser(provider, subject string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users
+			  WHERE id = (SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?) AND deleted_at IS NULL`
+	user, err := scanUser(m.DB.QueryRowContext(ctx, query, provider, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// Link records a login of userID through subject at provider, linking the
+// identity if it is new. An identity still linked to a deleted account
+// moves to userID, the account that signed in with it since.
+func (m *IdentityModel) Link(userID int, provider, subject, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO user_identities (user_id, provider, subject, email, last_login_at) VALUES (?, ?, ?, NULLIF(?, ''), ?)
+			  ON CONFLICT(provider, subject) DO UPDATE SET email = excluded.email, last_login_at = excluded.last_login_at,
+			  user_id = CASE WHEN EXISTS (SELECT 1 FROM users WHERE id = user_identities.user_id AND deleted_at IS NULL)
+			  THEN user_identities.user_id ELSE excluded.user_id END`
+	_, err := m.DB.ExecContext(ctx, query, userID, provider, subject, email, time.Now().UTC())
+	return err
+}
+
+// ListForUser returns the identities linked to userID.
+func (m *IdentityModel) ListForUser(userID int) ([]*Identity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, user_id, provider, subject, email, created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY id`
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []*Identity
+	for rows.Next() {
+		var i Identity
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &i.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// CreateLoginState stores a pending login under the hash of its state
+// parameter.
+func (m *IdentityModel) CreateLoginState(stateHash string, state *LoginState, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, expires_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := m.DB.ExecContext(ctx, query, stateHash, state.Provider, state.Nonce, state.CodeVerifier, expiresAt.UTC())
+	return err
+}
+
+// ConsumeLoginState removes and returns the unexpired pending login for
+// provider stored under stateHash. It returns ErrLoginStateInvalid otherwise.
+func (m *IdentityModel) ConsumeLoginState(stateHash, provider string) (*LoginState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM oidc_login_states WHERE state_hash = ? AND provider = ? AND expires_at > ?
+			  RETURNING provider, nonce, code_verifier`
+	var s LoginState
+	err := m.DB.QueryRowContext(ctx, query, stateHash, provider, time.Now().UTC()).Scan(&s.Provider, &s.Nonce, &s.CodeVerifier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLoginStateInvalid
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PurgeExpiredStates deletes pending logins that were never completed.
+func (m *IdentityModel) PurgeExpiredStates() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at < ?`, time.Now().UTC())
+	return err
+}
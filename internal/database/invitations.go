@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvitationInvalid is returned for invitations and invite links that do
+// not exist, were revoked, declined, used up or expired, or are addressed to
+// someone else.
+var ErrInvitationInvalid = errors.New("invitation is not valid")
+
+// Invitation statuses
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+	InvitationStatusRevoked  = "revoked"
+)
+
+// InvitationModel stores invitations to events, addressed to a person or
+// shared as invite links.
+type InvitationModel struct {
+	DB *sql.DB
+}
+
+// Invitation invites one person to an event. UserID is set when the invitee
+// has an account with a verified address (or was invited by user ID);
+// otherwise the invitation is claimed by whoever verifies Email.
+type Invitation struct {
+	ID          int     `json:"id"`
+	EventID     int     `json:"event_id"`
+	InvitedBy   *int    `json:"invited_by"`
+	UserID      *int    `json:"user_id"`
+	Email       string  `json:"email"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	RespondedAt *string `json:"responded_at"`
+}
+
+// ReceivedInvitation is an invitation together with the event it is for.
+type ReceivedInvitation struct {
+	Invitation
+	Event Event `json:"event"`
+}
+
+// InviteLink adds whoever opens it to an event, up to MaxUses times (nil
+// means unlimited) until ExpiresAt.
+type InviteLink struct {
+	ID        int        `json:"id"`
+	EventID   int        `json:"event_id"`
+	CreatedBy *int       `json:"created_by"`
+	MaxUses   *int       `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt string     `json:"created_at"`
+}
+
+const invitationColumns = `i.id, i.event_id, i.invited_by, i.user_id, i.email, i.status, i.created_at, i.responded_at`
+
+func invitationFields(inv *Invitation) []interface{} {
+	return []interface{}{&inv.ID, &inv.EventID, &inv.InvitedBy, &inv.UserID, &inv.Email, &inv.Status, &inv.CreatedAt, &inv.RespondedAt}
+}
+
+// Insert stores a pending invitation. Inviting an address that already has
+// a pending invitation to the event fails with a UNIQUE constraint error.
+func (m *InvitationModel) Insert(inv *Invitation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO event_invitations (event_id, invited_by, user_id, email) VALUES (?, ?, ?, ?)
+			  RETURNING id, status, created_at`
+	return m.DB.QueryRowContext(ctx, query, inv.EventID, inv.InvitedBy, inv.UserID, inv.Email).Scan(&inv.ID, &inv.Status, &inv.CreatedAt)
+}
+
+// ForEvent returns all invitations to an event, newest first.
+func (m *InvitationModel) ForEvent(eventID int) ([]*Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + invitationColumns + ` FROM event_invitations i WHERE i.event_id = ? ORDER BY i.id DESC`
+	rows, err := m.DB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []*Invitation
+	for rows.Next() {
+		var inv Invitation
+		if err := rows.Scan(invitationFields(&inv)...); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &inv)
+	}
+	return invitations, rows.Err()
+}
+
+// addressedTo is an SQL condition on invitations i that holds if the
+// invitation is for userID or, while unclaimed, for the verified email.
+const addressedTo = `(i.user_id = ? OR (i.user_id IS NULL AND ? != '' AND i.email = ?))`
+
+// Pending returns the pending invitations addressed to userID or to their
+// verified email address, oldest first.
+func (m *InvitationModel) Pending(userID int, email string) ([]*ReceivedInvitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + invitationColumns + `, ` + eventColumns + ` FROM event_invitations i
+			  JOIN events e ON e.id = i.event_id
+			  WHERE i.status = 'pending' AND e.deleted_at IS NULL AND ` + addressedTo + ` ORDER BY i.id`
+	rows, err := m.DB.QueryContext(ctx, query, userID, email, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []*ReceivedInvitation
+	for rows.Next() {
+		var inv ReceivedInvitation
+		if err := rows.Scan(append(invitationFields(&inv.Invitation), eventFields(&inv.Event)...)...); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &inv)
+	}
+	return invitations, rows.Err()
+}
+
+// Revoke withdraws a pending invitation to an event. It reports false if
+// there is no such pending invitation.
+func (m *InvitationModel) Revoke(eventID, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE event_invitations SET status = 'revoked', responded_at = datetime('now')
+			  WHERE id = ? AND event_id = ? AND status = 'pending'`
+	res, err := m.DB.ExecContext(ctx, query, id, eventID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Accept accepts a pending invitation addressed to userID or their verified
+// email, claiming it for userID, and adds them to the event, on its
+// waitlist if it is full. A user who already attends keeps their RSVP.
+func (m *InvitationModel) Accept(id, userID int, email string) (*Attendee, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE event_invitations AS i SET status = 'accepted', user_id = ?, responded_at = datetime('now')
+			  WHERE i.id = ? AND i.status = 'pending' AND ` + addressedTo + `
+			  RETURNING event_id`
+	var eventID int
+	err = tx.QueryRowContext(ctx, query, userID, id, userID, email, email).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attendee, _, err := joinOnceTx(ctx, tx, eventID, userID, "accepted invitation")
+	if err != nil {
+		return nil, err
+	}
+	return attendee, tx.Commit()
+}
+
+// Decline declines a pending invitation addressed to userID or their
+// verified email. It reports false if there is no such invitation.
+func (m *InvitationModel) Decline(id, userID int, email string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE event_invitations AS i SET status = 'declined', user_id = ?, responded_at = datetime('now')
+			  WHERE i.id = ? AND i.status = 'pending' AND ` + addressedTo
+	res, err := m.DB.ExecContext(ctx, query, userID, id, userID, email, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// InsertLink stores a new invite link.
+func (m *InvitationModel) InsertLink(link *InviteLink) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var expiresAt interface{}
+	if link.ExpiresAt != nil {
+		expiresAt = link.ExpiresAt.UTC()
+	}
+
+	query := `INSERT INTO event_invite_links (event_id, created_by, max_uses, expires_at) VALUES (?, ?, ?, ?)
+			  RETURNING id, created_at`
+	return m.DB.QueryRowContext(ctx, query, link.EventID, link.CreatedBy, link.MaxUses, expiresAt).Scan(&link.ID, &link.CreatedAt)
+}
+
+// LinksForEvent returns all invite links of an event, newest first.
+func (m *InvitationModel) LinksForEvent(eventID int) ([]*InviteLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, event_id, created_by, max_uses, uses, expires_at, revoked_at, created_at
+			  FROM event_invite_links WHERE event_id = ? ORDER BY id DESC`
+	rows, err := m.DB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*InviteLink
+	for rows.Next() {
+		var l InviteLink
+		if err := rows.Scan(&l.ID, &l.EventID, &l.CreatedBy, &l.MaxUses, &l.Uses, &l.ExpiresAt, &l.RevokedAt, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, &l)
+	}
+	return links, rows.Err()
+}
+
+// RevokeLink disables an invite link of an event. It reports false if there
+// is no such active link.
+func (m *InvitationModel) RevokeLink(eventID, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE event_invite_links SET revoked_at = ? WHERE id = ? AND event_id = ? AND revoked_at IS NULL`
+	res, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), id, eventID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UseLink adds userID to the event of invite link id, on its waitlist if it
+// is full, and counts the use. Users who already attend keep their RSVP
+// without using up the link.
+func (m *InvitationModel) UseLink(id, userID int) (*Attendee, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Counting the use first takes the write lock, so a single-use link
+	// cannot be redeemed twice.
+	query := `UPDATE event_invite_links SET uses = uses + 1
+			  WHERE id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses IS NULL OR uses < max_uses)
+			  RETURNING event_id`
+	var eventID int
+	err = tx.QueryRowContext(ctx, query, id, time.Now().UTC()).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attendee, joined, err := joinOnceTx(ctx, tx, eventID, userID, "used invite link")
+	if err != nil || !joined {
+		return attendee, err
+	}
+	return attendee, tx.Commit()
+}
+
+// joinOnceTx adds userID to an event within tx unless they already attend
+// it, in which case their existing RSVP is returned and joined is false.
+func joinOnceTx(ctx context.Context, tx *sql.Tx, eventID, userID int, reason string) (attendee *Attendee, joined bool, err error) {
+	attendee = &Attendee{EventID: eventID, UserID: userID}
+	query := `SELECT id, status FROM attendees WHERE event_id = ? AND user_id = ?`
+	err = tx.QueryRowContext(ctx, query, eventID, userID).Scan(&attendee.ID, &attendee.Status)
+	if err == nil {
+		return attendee, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	if err := joinTx(ctx, tx, attendee, true, userID, reason); err != nil {
+		return nil, false, err
+	}
+	return attendee, true, nil
+}
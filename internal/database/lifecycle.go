@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// EventStatusChange is an entry in an event's status history.
+type EventStatusChange struct {
+	ID         int    `json:"id"`
+	EventID    int    `json:"event_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	// ChangedBy is the user who made the change; nil for changes made by
+	// the system, such as completing events that have ended
+	ChangedBy *int   `json:"changed_by"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func recordEventStatusChange(ctx context.Context, tx *sql.Tx, eventID int, from, to string, changedBy int, reason string) error {
+	var byArg interface{}
+	if changedBy != 0 {
+		byArg = changedBy
+	}
+	query := `INSERT INTO event_status_history (event_id, from_status, to_status, changed_by, reason) VALUES (?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, eventID, from, to, byArg, reason)
+	return err
+}
+
+// SetStatus moves ev from its current status to status to, recording who
+// made the change and why. With scope all the other occurrences of ev's
+// series in the same status move too, and so does the series, so
+// occurrences materialized later follow; a cancelled series is not
+// materialized any further. It returns the IDs of the events that moved,
+// or ErrEventStatusChanged if ev's status is no longer ev.Status.
+func (m *EventModel) SetStatus(ev *Event, to, scope string, changedBy int, reason string) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The status check is part of the UPDATE so concurrent changes cannot
+	// both apply
+	query := `UPDATE events SET status = ?, updated_at = datetime('now') WHERE id = ? AND status = ? AND deleted_at IS NULL RETURNING id`
+	args := []interface{}{to, ev.ID, ev.Status}
+	if scope == SeriesScopeAll && ev.SeriesID != nil {
+		query = `UPDATE events SET status = ?, updated_at = datetime('now')
+				 WHERE (id = ? OR series_id = ?) AND status = ? AND deleted_at IS NULL RETURNING id`
+		args = []interface{}{to, ev.ID, *ev.SeriesID, ev.Status}
+	}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	moved := false
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		moved = moved || id == ev.ID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, ErrEventStatusChanged
+	}
+
+	for _, id := range ids {
+		if err := recordEventStatusChange(ctx, tx, id, ev.Status, to, changedBy, reason); err != nil {
+			return nil, err
+		}
+	}
+	if scope == SeriesScopeAll && ev.SeriesID != nil {
+		query := `UPDATE event_series SET status = ?, updated_at = datetime('now') WHERE id = ?`
+		if to == EventStatusCancelled {
+			query = `UPDATE event_series SET status = ?, materialized_until = '` + seriesFinished + `', updated_at = datetime('now') WHERE id = ?`
+		}
+		if _, err := tx.ExecContext(ctx, query, to, *ev.SeriesID); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// CompleteEnded moves the published events that ended by now to completed
+// and returns their IDs.
+func (m *EventModel) CompleteEnded(now time.Time) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET status = 'completed', updated_at = datetime('now')
+			  WHERE status = 'published' AND end_time <= ? AND deleted_at IS NULL RETURNING id`
+	rows, err := tx.QueryContext(ctx, query, formatTime(now))
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := recordEventStatusChange(ctx, tx, id, EventStatusPublished, EventStatusCompleted, 0, "event ended"); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// StatusHistory returns the status changes of an event, oldest first.
+func (m *EventModel) StatusHistory(eventID int) ([]*EventStatusChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, event_id, from_status, to_status, changed_by, reason, created_at FROM event_status_history
+			  WHERE event_id = ? ORDER BY id`
+	rows, err := m.DB.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*EventStatusChange
+	for rows.Next() {
+		var ch EventStatusChange
+		if err := rows.Scan(&ch.ID, &ch.EventID, &ch.FromStatus, &ch.ToStatus, &ch.ChangedBy, &ch.Reason, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &ch)
+	}
+	return changes, rows.Err()
+}
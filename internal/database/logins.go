@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Kinds of login lockout
+const (
+	LockoutKindAccount = "account"
+	LockoutKindIP      = "ip"
+)
+
+// LoginThrottleModel tracks failed login attempts per account and per IP.
+type LoginThrottleModel struct {
+	DB *sql.DB
+}
+
+// LockoutEvent records a login lockout being triggered.
+type LockoutEvent struct {
+	ID          int       `json:"id"`
+	Kind        string    `json:"kind"`
+	Subject     string    `json:"subject"`
+	UserID      *int      `json:"user_id,omitempty"`
+	IP          string    `json:"ip"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+// LockedUntil returns the latest lock expiry among keys, or the zero time if
+// none of them is locked.
+func (m *LoginThrottleModel) LockedUntil(keys ...string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var until time.Time
+	if len(keys) == 0 {
+		return until, nil
+	}
+
+	args := make([]interface{}, 0, len(keys)+1)
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	args = append(args, time.Now().UTC())
+
+	query := `SELECT locked_until FROM login_throttle
+			  WHERE key IN (?` + strings.Repeat(", ?", len(keys)-1) + `) AND locked_until > ?`
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return until, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return until, err
+		}
+		if t.After(until) {
+			until = t
+		}
+	}
+	return until, rows.Err()
+}
+
+// RecordFailure counts a failed attempt against key and returns the number of
+// failures so far. The count restarts when the previous failure is older than
+// window.
+func (m *LoginThrottleModel) RecordFailure(key string, window time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `INSERT INTO login_throttle (key, failures, last_failure_at) VALUES (?, 1, ?)
+			  ON CONFLICT(key) DO UPDATE SET
+				  failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+				  last_failure_at = excluded.last_failure_at
+			  RETURNING failures`
+	var failures int
+	err := m.DB.QueryRowContext(ctx, query, key, now, now.Add(-window)).Scan(&failures)
+	return failures, err
+}
+
+// Lock blocks logins for key until the given time.
+func (m *LoginThrottleModel) Lock(key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE login_throttle SET locked_until = ? WHERE key = ?`, until.UTC(), key)
+	return err
+}
+
+// Clear forgets all failures and any lock for keys.
+func (m *LoginThrottleModel) Clear(keys ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM login_throttle WHERE key IN (?`+strings.Repeat(", ?", len(keys)-1)+`)`, args...)
+	return err
+}
+
+// LockoutIPs returns the addresses that triggered lockouts of userID's
+// account since the given time.
+func (m *LoginThrottleModel) LockoutIPs(userID int, since time.Time) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT DISTINCT ip FROM login_lockouts WHERE user_id = ? AND created_at >= datetime(?, 'unixepoch')`
+	rows, err := m.DB.QueryContext(ctx, query, userID, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
+
+// RecordLockout stores a lockout event.
+func (m *LoginThrottleModel) RecordLockout(e *LockoutEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO login_lockouts (kind, subject, user_id, ip, failures, locked_until) VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := m.DB.ExecContext(ctx, query, e.Kind, e.Subject, e.UserID, e.IP, e.Failures, e.LockedUntil.UTC())
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(id)
+	return nil
+}
+
+// ListLockouts returns the most recent lockout events first, optionally
+// restricted to lockouts of userID's account (0 for all).
+func (m *LoginThrottleModel) ListLockouts(userID, limit, offset int) ([]*LockoutEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, kind, subject, user_id, ip, failures, locked_until, created_at FROM login_lockouts`
+	var args []interface{}
+	if userID > 0 {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*LockoutEvent
+	for rows.Next() {
+		var e LockoutEvent
+		if err := rows.Scan(&e.ID, &e.Kind, &e.Subject, &e.UserID, &e.IP, &e.Failures, &e.LockedUntil, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// PurgeStale deletes throttle entries whose last failure is older than
+// before and that are not currently locked.
+func (m *LoginThrottleModel) PurgeStale(before time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM login_throttle WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)`
+	_, err := m.DB.ExecContext(ctx, query, before.UTC(), time.Now().UTC())
+	return err
+}
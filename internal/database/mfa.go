@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MFAModel stores TOTP enrollments, recovery codes and the roles that must
+// use two-factor authentication.
+type MFAModel struct {
+	DB *sql.DB
+}
+
+// TOTPEnrollment is a user's TOTP secret. It only protects logins once
+// confirmed.
+type TOTPEnrollment struct {
+	UserID       int
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+}
+
+// Confirmed reports whether the user has proven their authenticator works.
+func (e *TOTPEnrollment) Confirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// GetTOTP returns the TOTP enrollment of userID, or nil if there is none.
+func (m *MFAModel) GetTOTP(userID int) (*TOTPEnrollment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT user_id, secret, confirmed_at, last_used_step FROM user_totp WHERE user_id = ?`
+	var e TOTPEnrollment
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&e.UserID, &e.Secret, &e.ConfirmedAt, &e.LastUsedStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// StartTOTPEnrollment stores secret as the user's pending TOTP secret,
+// replacing an earlier unconfirmed one. It reports false without changing
+// anything if the user already has confirmed TOTP.
+func (m *MFAModel) StartTOTPEnrollment(userID int, secret string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO user_totp (user_id, secret) VALUES (?, ?)
+			  ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+			  WHERE confirmed_at IS NULL`
+	res, err := m.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ConfirmTOTP activates the pending enrollment of userID, records step as
+// used and replaces the user's recovery codes with codeHashes.
+func (m *MFAModel) ConfirmTOTP(userID int, step int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE user_totp SET confirmed_at = ?, last_used_step = ? WHERE user_id = ? AND confirmed_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, time.Now().UTC(), step, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return sql.ErrNoRows
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as the last accepted time step for userID. It
+// reports false if that step or a later one was already used, so each code
+// works only once.
+func (m *MFAModel) UseTOTPStep(userID int, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`
+	res, err := m.DB.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteTOTP removes the TOTP enrollment and recovery codes of userID.
+func (m *MFAModel) DeleteTOTP(userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards the recovery codes of userID and stores
+// codeHashes instead.
+func (m *MFAModel) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code hashed as codeHash as used.
+// It reports false if userID has no such code.
+func (m *MFAModel) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE mfa_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	res, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RemainingRecoveryCodes returns how many unused recovery codes userID has.
+func (m *MFAModel) RemainingRecoveryCodes(userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var n int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+// RoleRequiresMFA reports whether members of role must use two-factor
+// authentication.
+func (m *MFAModel) RoleRequiresMFA(role string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var required bool
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM mfa_required_roles WHERE role = ?)`, role).Scan(&required)
+	return required, err
+}
+
+// SetRoleRequiresMFA adds role to or removes it from the roles that must use
+// two-factor authentication.
+func (m *MFAModel) SetRoleRequiresMFA(role string, required bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM mfa_required_roles WHERE role = ?`
+	if required {
+		query = `INSERT OR IGNORE INTO mfa_required_roles (role) VALUES (?)`
+	}
+	_, err := m.DB.ExecContext(ctx, query, role)
+	return err
+}
+
+// RequiredRoles returns the roles that must use two-factor authentication.
+func (m *MFAModel) RequiredRoles() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
@@ -3,16 +3,38 @@ package database
 import "database/sql"
 
 type Models struct {
-	Users     UserModel
-	Events    EventModel
-	Attendees AttendeeModel
+	Users         UserModel
+	Events        EventModel
+	Attendees     AttendeeModel
+	Audit         AuditModel
+	Tokens        TokenModel
+	LoginThrottle LoginThrottleModel
+	MFA           MFAModel
+	Identities    IdentityModel
+	APIKeys       APIKeyModel
+	Invitations   InvitationModel
+	Series        SeriesModel
+	Venues        VenueModel
+	Categories    CategoryModel
+	EventRoles    EventRoleModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:     UserModel{DB: db},
-		Events:    EventModel{DB: db},
-		Attendees: AttendeeModel{DB: db},
+		Users:         UserModel{DB: db},
+		Events:        EventModel{DB: db},
+		Attendees:     AttendeeModel{DB: db},
+		Audit:         AuditModel{DB: db},
+		Tokens:        TokenModel{DB: db},
+		LoginThrottle: LoginThrottleModel{DB: db},
+		MFA:           MFAModel{DB: db},
+		Identities:    IdentityModel{DB: db},
+		APIKeys:       APIKeyModel{DB: db},
+		Invitations:   InvitationModel{DB: db},
+		Series:        SeriesModel{DB: db},
+		Venues:        VenueModel{DB: db},
+		Categories:    CategoryModel{DB: db},
+		EventRoles:    EventRoleModel{DB: db},
 	}
 }
 
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// EventNearbyResult is an event found by a nearby search together with the
+// distance from the search point to its venue.
+type EventNearbyResult struct {
+	Event
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// SortKey is r's position among nearby results, which are ordered by
+// (SortKey, ID): nearest first, then by start time. Distances are
+// zero-padded so the keys compare as strings, like cursor keys do.
+func (r *EventNearbyResult) SortKey() string {
+	return fmt.Sprintf("%011.3f %s", r.DistanceKm, r.StartTime)
+}
+
+// Nearby returns one page of the events matching f whose venue lies within
+// radiusKm of (lat, lng), nearest first, along with the total number of
+// such events. Venues are prefiltered on the indexed coordinates with a
+// bounding box around the circle; the exact great-circle distance is then
+// checked in Go. Pages start at f.Cursor, a position given by SortKey, or
+// at f.Offset without one; f's sort is ignored.
+func (m *EventModel) Nearby(f EventFilter, lat, lng, radiusKm float64) ([]*EventNearbyResult, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where, args := f.where()
+	box, boxArgs := boundingBox(lat, lng, radiusKm)
+
+	query := `SELECT ` + eventColumns + `, v.latitude, v.longitude
+			  FROM events e JOIN venues v ON v.id = e.venue_id` +
+		where + ` AND ` + box
+	rows, err := m.DB.QueryContext(ctx, query, append(args, boxArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*EventNearbyResult
+	for rows.Next() {
+		var r EventNearbyResult
+		var vLat, vLng float64
+		if err := rows.Scan(append(eventFields(&r.Event), &vLat, &vLng)...); err != nil {
+			return nil, 0, err
+		}
+		d := haversineKm(lat, lng, vLat, vLng)
+		if d > radiusKm {
+			continue
+		}
+		r.DistanceKm = math.Round(d*1000) / 1000
+		results = append(results, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return nearbyBefore(results[i], results[j].SortKey(), results[j].ID)
+	})
+
+	total := len(results)
+	if c := f.Cursor; c != nil {
+		// Binary search for the first result after the cursor
+		i := sort.Search(total, func(i int) bool { return !nearbyBefore(results[i], c.Key, c.ID) })
+		if c.Before {
+			// Backward pages end right before the cursor
+			return results[max(i-f.Limit, 0):i], total, nil
+		}
+		if i < total && results[i].SortKey() == c.Key && results[i].ID == c.ID {
+			i++
+		}
+		return results[i:min(i+f.Limit, total)], total, nil
+	}
+
+	if f.Offset >= total {
+		return nil, total, nil
+	}
+	return results[f.Offset:min(f.Offset+f.Limit, total)], total, nil
+}
+
+// nearbyBefore reports whether r comes before the position (key, id) in
+// nearby results.
+func nearbyBefore(r *EventNearbyResult, key string, id int) bool {
+	if k := r.SortKey(); k != key {
+		return k < key
+	}
+	return r.ID < id
+}
+
+// boundingBox is an SQL condition on venues v selecting the coordinates in
+// the smallest latitude/longitude box around the circle of radiusKm around
+// (lat, lng). Boxes reaching a pole cover every longitude; boxes crossing
+// the antimeridian wrap around it.
+func boundingBox(lat, lng, radiusKm float64) (string, []interface{}) {
+	dLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	minLat, maxLat := lat-dLat, lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return `v.latitude BETWEEN ? AND ? AND v.longitude IS NOT NULL`, []interface{}{math.Max(minLat, -90), math.Min(maxLat, 90)}
+	}
+
+	// The widest point of the circle is at asin(sin(lat)/cos(r)), not at
+	// lat; this offset covers it.
+	dLng := math.Asin(math.Sin(radiusKm/earthRadiusKm)/math.Cos(lat*math.Pi/180)) * 180 / math.Pi
+	minLng, maxLng := lng-dLng, lng+dLng
+	switch {
+	case minLng < -180:
+		return `v.latitude BETWEEN ? AND ? AND (v.longitude >= ? OR v.longitude <= ?)`,
+			[]interface{}{minLat, maxLat, minLng + 360, maxLng}
+	case maxLng > 180:
+		return `v.latitude BETWEEN ? AND ? AND (v.longitude >= ? OR v.longitude <= ?)`,
+			[]interface{}{minLat, maxLat, minLng, maxLng - 360}
+	}
+	return `v.latitude BETWEEN ? AND ? AND v.longitude BETWEEN ? AND ?`, []interface{}{minLat, maxLat, minLng, maxLng}
+}
+
+// haversineKm returns the great-circle distance in kilometres between two
+// points given in degrees.
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
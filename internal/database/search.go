@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EventSearchResult is an event matched by a full-text search together with
+// highlighted fragments and its relevance score (higher is more relevant).
+// The fragments are HTML: the event's text is escaped and matches are
+// wrapped in <mark>.
+type EventSearchResult struct {
+	Event
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+	Score          float64 `json:"score"`
+}
+
+// Search runs a relevance-ranked full-text query against the events_fts index.
+// The query supports bare terms, prefix terms ("conf*") and quoted phrases
+// ("go workshop"); all terms must match. Only events listed to v are
+// searched. It returns one page of results and the total number of matches.
+func (m *EventModel) Search(q string, v Viewer, limit, offset int) ([]*EventSearchResult, int, error) {
+	match := BuildFTSQuery(q)
+	if match == "" {
+		return nil, 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	listed, listedArgs := listedFor(v)
+	args := append([]interface{}{match}, listedArgs...)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM events_fts JOIN events e ON e.id = events_fts.rowid WHERE events_fts MATCH ? AND e.deleted_at IS NULL AND ` + listed
+	if err := m.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// bm25 returns lower values for better matches; title hits weigh more.
+	// Matches are delimited with control characters rather than <mark> so
+	// that the text around them can be escaped first; see markMatches.
+	query := `SELECT ` + eventColumns + `,
+			  highlight(events_fts, 0, char(2), char(3)),
+			  snippet(events_fts, 1, char(2), char(3), '…', 16),
+			  bm25(events_fts, 10.0, 1.0) AS rank
+			  FROM events_fts JOIN events e ON e.id = events_fts.rowid
+			  WHERE events_fts MATCH ? AND e.deleted_at IS NULL AND ` + listed + `
+			  ORDER BY rank, e.id
+			  LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*EventSearchResult
+	for rows.Next() {
+		var r EventSearchResult
+		var rank float64
+		err := rows.Scan(append(eventFields(&r.Event), &r.TitleHighlight, &r.Snippet, &rank)...)
+		if err != nil {
+			return nil, 0, err
+		}
+		r.TitleHighlight = markMatches(r.TitleHighlight)
+		r.Snippet = markMatches(r.Snippet)
+		r.Score = -rank
+		results = append(results, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// markMatches HTML-escapes a fragment returned by highlight() or snippet()
+// and turns the control characters delimiting its matches into <mark>
+// tags. A stray delimiter in the event's own text can at worst unbalance
+// the tags; it cannot inject markup.
+func markMatches(fragment string) string {
+	return strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>").Replace(html.EscapeString(fragment))
+}
+
+// BuildFTSQuery turns free-form user input into a safe FTS5 MATCH expression.
+// Every term is quoted so FTS5 operators in the input are treated as text;
+// a trailing '*' on a word is kept as a prefix query and double-quoted runs
+// of words become phrase queries. It returns "" if no searchable terms remain.
+func BuildFTSQuery(input string) string {
+	var terms []string
+
+	for len(input) > 0 {
+		input = strings.TrimLeftFunc(input, unicode.IsSpace)
+		if input == "" {
+			break
+		}
+
+		if input[0] == '"' {
+			end := strings.IndexByte(input[1:], '"')
+			var phrase string
+			if end < 0 {
+				phrase, input = input[1:], ""
+			} else {
+				phrase, input = input[1:end+1], input[end+2:]
+			}
+			if words := ftsWords(phrase); len(words) > 0 {
+				terms = append(terms, `"`+strings.Join(words, " ")+`"`)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(input, unicode.IsSpace)
+		var word string
+		if end < 0 {
+			word, input = input, ""
+		} else {
+			word, input = input[:end], input[end:]
+		}
+		words := ftsWords(word)
+		if len(words) == 0 {
+			continue
+		}
+		for _, w := range words {
+			terms = append(terms, `"`+w+`"`)
+		}
+		if strings.HasSuffix(word, "*") {
+			terms[len(terms)-1] += "*"
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// ftsWords splits s into the letter/digit runs the unicode61 tokenizer would
+// index, dropping punctuation and FTS5 syntax characters.
+func ftsWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,555 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rest-api-in-gin/internal/rrule"
+	"time"
+)
+
+// Scopes of a change made through one occurrence of a series
+const (
+	SeriesScopeThis      = "this"
+	SeriesScopeFollowing = "following"
+	SeriesScopeAll       = "all"
+)
+
+// ErrNoOccurrences is returned when a recurrence rule yields no occurrence.
+var ErrNoOccurrences = errors.New("recurrence rule has no occurrences")
+
+// seriesFinished is the materialized_until of series whose rule has ended.
+const seriesFinished = "9999-12-31T23:59:59Z"
+
+// SeriesModel stores recurring events. Occurrences are materialized as
+// events up to a horizon that the caller moves forward over time.
+type SeriesModel struct {
+	DB *sql.DB
+}
+
+// Series is the rule and template of a recurring event. StartTime and
+// EndTime are those of the first occurrence the rule is expanded from, in
+// Timezone so occurrences keep their local time across DST changes.
+type Series struct {
+	ID          int
+	UserID      int
+	Recurrence  string
+	Title       string
+	Description string
+	StartTime   string
+	EndTime     string
+	Timezone    string
+	Capacity    *int
+	Visibility  string
+	// Status is that of the occurrences materialized from now on
+	Status            string
+	VenueID           *int
+	CategoryID        *int
+	MaterializedUntil string
+}
+
+// formatTime formats t the way event times are stored.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseInZone parses a stored time into the IANA time zone tz.
+func parseInZone(value, tz string) (time.Time, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// shiftTime moves the stored time value by d, leaving the finished marker
+// alone.
+func shiftTime(value string, d time.Duration) (string, error) {
+	if value == seriesFinished || d == 0 {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	return formatTime(t.Add(d)), nil
+}
+
+// Insert creates a series from ev, the template of its occurrences, and
+// materializes them up to horizon, always including the first. ev is
+// replaced by the first occurrence. If any of them overlaps another booking
+// of ev's venue it returns ErrVenueBooked.
+func (m *SeriesModel) Insert(ev *Event, rule *rrule.Rule, horizon time.Time) error {
+	if ev.Timezone == "" {
+		ev.Timezone = "UTC"
+	}
+	start, err := parseInZone(ev.StartTime, ev.Timezone)
+	if err != nil {
+		return err
+	}
+	first, ok := rule.Iterator(start).Next()
+	if !ok {
+		return ErrNoOccurrences
+	}
+	if !first.Before(horizon) {
+		horizon = first.Add(time.Second)
+	}
+	if ev.Visibility == "" {
+		ev.Visibility = EventVisibilityPublic
+	}
+	if ev.Status == "" {
+		ev.Status = EventStatusPublished
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s := &Series{
+		UserID:      ev.User_id,
+		Recurrence:  rule.String(),
+		Title:       ev.Title,
+		Description: ev.Description,
+		StartTime:   formatTime(start),
+		EndTime:     ev.EndTime,
+		Timezone:    ev.Timezone,
+		Capacity:    ev.Capacity,
+		Visibility:  ev.Visibility,
+		Status:      ev.Status,
+		VenueID:     ev.VenueID,
+		CategoryID:  ev.CategoryID,
+	}
+	query := `INSERT INTO event_series (user_id, rrule, title, description, start_time, end_time, timezone, capacity, visibility, status, venue_id, category_id,
+			  materialized_until)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '') RETURNING id`
+	err = tx.QueryRowContext(ctx, query, s.UserID, s.Recurrence, s.Title, s.Description, s.StartTime, s.EndTime, s.Timezone, s.Capacity, s.Visibility,
+		s.Status, s.VenueID, s.CategoryID).Scan(&s.ID)
+	if err != nil {
+		return err
+	}
+	if err := setTags(ctx, tx, "series_tags", s.ID, ev.Tags); err != nil {
+		return err
+	}
+	if err := materialize(ctx, tx, s, horizon, true); err != nil {
+		return err
+	}
+
+	query = `SELECT ` + eventColumns + ` FROM events e WHERE e.series_id = ? ORDER BY e.occurrence_start LIMIT 1`
+	firstEvent, err := scanEvent(tx.QueryRowContext(ctx, query, s.ID))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*ev = *firstEvent
+	return nil
+}
+
+// materialize stores the occurrences of s from its materialized_until up
+// to horizon as events, with the series' status and tags. Occurrences that
+// would overlap another booking of the series' venue are skipped, or fail
+// with ErrVenueBooked if strict.
+func materialize(ctx context.Context, tx *sql.Tx, s *Series, horizon time.Time, strict bool) error {
+	rule, err := rrule.Parse(s.Recurrence)
+	if err != nil {
+		return err
+	}
+	start, err := parseInZone(s.StartTime, s.Timezone)
+	if err != nil {
+		return err
+	}
+	end, err := time.Parse(time.RFC3339, s.EndTime)
+	if err != nil {
+		return err
+	}
+	var from time.Time
+	if s.MaterializedUntil != "" {
+		if from, err = time.Parse(time.RFC3339, s.MaterializedUntil); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT OR IGNORE INTO events (user_id, title, description, start_time, end_time, timezone, capacity, visibility, status, venue_id, category_id,
+			  series_id, occurrence_start, created_at, updated_at)
+			  SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now') WHERE NOT ` + venueClash
+	tagQuery := `INSERT INTO event_tags (event_id, tag_id) SELECT ?, tag_id FROM series_tags WHERE series_id = ?`
+	until := seriesFinished
+	it := rule.Iterator(start)
+	for {
+		t, ok := it.Next()
+		if !ok {
+			break
+		}
+		if !t.Before(horizon) {
+			until = formatTime(horizon)
+			break
+		}
+		if t.Before(from) {
+			continue
+		}
+		occStart, occEnd := formatTime(t), formatTime(t.Add(end.Sub(start)))
+		res, err := tx.ExecContext(ctx, query, s.UserID, s.Title, s.Description, occStart, occEnd,
+			s.Timezone, s.Capacity, s.Visibility, s.Status, s.VenueID, s.CategoryID, s.ID, occStart, s.VenueID, 0, occEnd, occStart)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil || (strict && n == 0) {
+			if err == nil {
+				err = ErrVenueBooked
+			}
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, tagQuery, id, s.ID); err != nil {
+			return err
+		}
+	}
+
+	s.MaterializedUntil = until
+	_, err = tx.ExecContext(ctx, `UPDATE event_series SET materialized_until = ? WHERE id = ?`, until, s.ID)
+	return err
+}
+
+const seriesColumns = `id, user_id, rrule, title, description, start_time, end_time, timezone, capacity, visibility, status, venue_id, category_id,
+	materialized_until`
+
+func scanSeries(row rowScanner) (*Series, error) {
+	var s Series
+	err := row.Scan(&s.ID, &s.UserID, &s.Recurrence, &s.Title, &s.Description, &s.StartTime, &s.EndTime, &s.Timezone, &s.Capacity, &s.Visibility, &s.Status, &s.VenueID, &s.CategoryID,
+		&s.MaterializedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// MaterializeUntil stores the occurrences of every series up to horizon.
+func (m *SeriesModel) MaterializeUntil(horizon time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var ids []int
+	rows, err := m.DB.QueryContext(ctx, `SELECT id FROM event_series WHERE materialized_until < ?`, formatTime(horizon))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := m.materializeSeries(id, horizon); err != nil {
+			return fmt.Errorf("series %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (m *SeriesModel) materializeSeries(id int, horizon time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Claim the series with a write first so concurrent extensions queue
+	res, err := tx.ExecContext(ctx, `UPDATE event_series SET updated_at = updated_at WHERE id = ? AND materialized_until < ?`, id, formatTime(horizon))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	s, err := scanSeries(tx.QueryRowContext(ctx, `SELECT `+seriesColumns+` FROM event_series WHERE id = ?`, id))
+	if err != nil {
+		return err
+	}
+	if err := materialize(ctx, tx, s, horizon, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitTx ends series s just before occurrence key and returns the rule
+// the rest of the series continues with, which keeps what is left of a
+// COUNT. The caller must hold the write lock.
+func splitTx(ctx context.Context, tx *sql.Tx, s *Series, key time.Time) (*rrule.Rule, error) {
+	rule, err := rrule.Parse(s.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	start, err := parseInZone(s.StartTime, s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	before := 0
+	it := rule.Iterator(start)
+	for t, ok := it.Next(); ok && t.Before(key); t, ok = it.Next() {
+		before++
+	}
+
+	rest := *rule
+	if rule.Count > 0 {
+		rest.Count = rule.Count - before
+	}
+	ended := *rule
+	ended.Count, ended.Until = 0, key.Add(-time.Second)
+
+	query := `UPDATE event_series SET rrule = ?, materialized_until = ?, updated_at = datetime('now') WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, ended.String(), seriesFinished, s.ID); err != nil {
+		return nil, err
+	}
+	return &rest, nil
+}
+
+// seriesForOccurrence loads the series of occurrence occ within tx and
+// reports whether occ is its first remaining occurrence, in which case
+// "following" means the whole series.
+func seriesForOccurrence(ctx context.Context, tx *sql.Tx, occ *Event) (s *Series, first bool, err error) {
+	if occ.SeriesID == nil || occ.OccurrenceStart == nil {
+		return nil, false, fmt.Errorf("event %d is not an occurrence of a series", occ.ID)
+	}
+	s, err = scanSeries(tx.QueryRowContext(ctx, `SELECT `+seriesColumns+` FROM event_series WHERE id = ?`, *occ.SeriesID))
+	if err != nil {
+		return nil, false, err
+	}
+	var earlier bool
+	query := `SELECT EXISTS (SELECT 1 FROM events WHERE series_id = ? AND occurrence_start < ? AND deleted_at IS NULL)`
+	if err := tx.QueryRowContext(ctx, query, s.ID, *occ.OccurrenceStart).Scan(&earlier); err != nil {
+		return nil, false, err
+	}
+	return s, !earlier, nil
+}
+
+// UpdateOccurrences applies the changes made to occurrence occ, given as
+// the occurrence's new values in changed, to it and the following or all
+// occurrences of its series. A change of start time moves every affected
+// occurrence by the same amount and must stay on the same day; a new
+// duration applies to all of them. "Following" splits the series in two.
+// It returns the IDs of the updated events, or ErrCapacityBelowAttendance
+// if the new capacity is below the attendance of one of them, or
+// ErrVenueBooked if one would overlap another booking of its venue.
+func (m *SeriesModel) UpdateOccurrences(occ, changed *Event, scope string) ([]int, error) {
+	oldStart, err := time.Parse(time.RFC3339, occ.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	newStart, err := time.Parse(time.RFC3339, changed.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	newEnd, err := time.Parse(time.RFC3339, changed.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	delta, duration := newStart.Sub(oldStart), newEnd.Sub(newStart)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Take the write lock before reading the series
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET updated_at = updated_at WHERE id = ?`, occ.ID); err != nil {
+		return nil, err
+	}
+	s, first, err := seriesForOccurrence(ctx, tx, occ)
+	if err != nil {
+		return nil, err
+	}
+	key, err := time.Parse(time.RFC3339, *occ.OccurrenceStart)
+	if err != nil {
+		return nil, err
+	}
+
+	targetID := s.ID
+	if scope == SeriesScopeFollowing && !first {
+		rest, err := splitTx(ctx, tx, s, key)
+		if err != nil {
+			return nil, err
+		}
+		until, err := shiftTime(s.MaterializedUntil, delta)
+		if err != nil {
+			return nil, err
+		}
+		templateStart := key.Add(delta)
+		query := `INSERT INTO event_series (user_id, rrule, title, description, start_time, end_time, timezone, capacity, visibility, status, venue_id,
+				  category_id, materialized_until)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+		err = tx.QueryRowContext(ctx, query, s.UserID, rest.String(), changed.Title, changed.Description, formatTime(templateStart),
+			formatTime(templateStart.Add(duration)), changed.Timezone, changed.Capacity, changed.Visibility, s.Status, changed.VenueID,
+			changed.CategoryID, until).Scan(&targetID)
+		if err != nil {
+			return nil, err
+		}
+		query = `UPDATE events SET series_id = ? WHERE series_id = ? AND occurrence_start >= ?`
+		if _, err := tx.ExecContext(ctx, query, targetID, s.ID, *occ.OccurrenceStart); err != nil {
+			return nil, err
+		}
+	} else {
+		seriesStart, err := time.Parse(time.RFC3339, s.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		until, err := shiftTime(s.MaterializedUntil, delta)
+		if err != nil {
+			return nil, err
+		}
+		query := `UPDATE event_series SET title = ?, description = ?, start_time = ?, end_time = ?, timezone = ?, capacity = ?, visibility = ?,
+				  venue_id = ?, category_id = ?, materialized_until = ?, updated_at = datetime('now') WHERE id = ?`
+		_, err = tx.ExecContext(ctx, query, changed.Title, changed.Description, formatTime(seriesStart.Add(delta)),
+			formatTime(seriesStart.Add(delta+duration)), changed.Timezone, changed.Capacity, changed.Visibility, changed.VenueID, changed.CategoryID,
+			until, s.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := setTags(ctx, tx, "series_tags", targetID, changed.Tags); err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	// Cancelled, completed and deleted occurrences are left as they are
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM events WHERE series_id = ? AND status IN `+editableStatuses+` AND deleted_at IS NULL`, targetID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	shift := fmt.Sprintf("%+d seconds", int64(delta/time.Second))
+	end := fmt.Sprintf("%+d seconds", int64((delta+duration)/time.Second))
+	query := `UPDATE events SET title = ?, description = ?, timezone = ?, capacity = ?, visibility = ?, venue_id = ?, category_id = ?,
+			  start_time = strftime('%Y-%m-%dT%H:%M:%SZ', start_time, ?),
+			  end_time = strftime('%Y-%m-%dT%H:%M:%SZ', start_time, ?),
+			  occurrence_start = strftime('%Y-%m-%dT%H:%M:%SZ', occurrence_start, ?),
+			  updated_at = datetime('now')
+			  WHERE series_id = ? AND status IN ` + editableStatuses + ` AND deleted_at IS NULL
+			  AND (? IS NULL OR ? >= (SELECT COUNT(*) FROM attendees WHERE event_id = events.id AND status IN ` + seatedStatuses + `))`
+	res, err := tx.ExecContext(ctx, query, changed.Title, changed.Description, changed.Timezone, changed.Capacity, changed.Visibility, changed.VenueID,
+		changed.CategoryID, shift, end, shift, targetID, changed.Capacity, changed.Capacity)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if int(n) != len(ids) {
+		return nil, ErrCapacityBelowAttendance
+	}
+	for _, query := range []string{
+		`DELETE FROM event_tags WHERE event_id IN (SELECT id FROM events WHERE series_id = ?)`,
+		`INSERT INTO event_tags (event_id, tag_id) SELECT e.id, st.tag_id FROM events e JOIN series_tags st ON st.series_id = e.series_id WHERE e.series_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, targetID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Moved occurrences must not overlap other bookings of their venue
+	var booked bool
+	query = `SELECT EXISTS (SELECT 1 FROM events e WHERE e.series_id = ? AND e.status != 'cancelled' AND e.deleted_at IS NULL AND EXISTS (SELECT 1 FROM events o
+			 WHERE o.venue_id = e.venue_id AND o.id != e.id AND o.status != 'cancelled' AND o.deleted_at IS NULL
+			 AND o.start_time < e.end_time AND o.end_time > e.start_time))`
+	if err := tx.QueryRowContext(ctx, query, targetID).Scan(&booked); err != nil {
+		return nil, err
+	}
+	if booked {
+		return nil, ErrVenueBooked
+	}
+
+	return ids, tx.Commit()
+}
+
+// CancelOccurrences deletes occurrence occ together with the following or
+// all occurrences of its series, and ends the series there so they are not
+// materialized again. Like EventModel.Delete it only marks them deleted.
+func (m *SeriesModel) CancelOccurrences(occ *Event, scope string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET updated_at = updated_at WHERE id = ?`, occ.ID); err != nil {
+		return err
+	}
+	s, first, err := seriesForOccurrence(ctx, tx, occ)
+	if err != nil {
+		return err
+	}
+
+	if scope == SeriesScopeFollowing && !first {
+		key, err := time.Parse(time.RFC3339, *occ.OccurrenceStart)
+		if err != nil {
+			return err
+		}
+		if _, err := splitTx(ctx, tx, s, key); err != nil {
+			return err
+		}
+		query := `UPDATE events SET deleted_at = datetime('now') WHERE series_id = ? AND occurrence_start >= ? AND deleted_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, s.ID, *occ.OccurrenceStart); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// The series is kept for restoring its occurrences until they are purged
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET deleted_at = datetime('now') WHERE series_id = ? AND deleted_at IS NULL`, s.ID); err != nil {
+		return err
+	}
+	query := `UPDATE event_series SET materialized_until = ?, updated_at = datetime('now') WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, seriesFinished, s.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
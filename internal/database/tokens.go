@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked
+	// refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again. The whole session has been revoked when it occurs.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrUserTokenInvalid is returned for unknown, expired or already used
+	// password reset and email verification tokens.
+	ErrUserTokenInvalid = errors.New("invalid or expired token")
+)
+
+// Purposes of single-use tokens mailed to users
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// Session revocation reasons
+const (
+	RevokeReasonLogout            = "logout"
+	RevokeReasonReuse             = "refresh_token_reuse"
+	RevokeReasonCredentialsChange = "credentials_changed"
+)
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// CreateSession starts a new login session for userID with its first refresh
+// token. tokenHash is the SHA-256 hash of the refresh token.
+func (m *TokenModel) CreateSession(sessionID string, userID int, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO auth_sessions (id, user_id) VALUES (?, ?)`, sessionID, userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, sessionID, tokenHash, expiresAt.UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RotateRefreshToken exchanges the refresh token hashed as oldHash for a new
+// one in the same session and returns the session and its user. Presenting a
+// token that was already rotated revokes the session and returns
+// ErrRefreshTokenReused.
+func (m *TokenModel) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (sessionID string, userID int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+
+	var tokenID int
+	var tokenExpires time.Time
+	var used, revoked bool
+	query := `SELECT rt.id, rt.session_id, s.user_id, rt.expires_at, rt.used_at IS NOT NULL, s.revoked_at IS NOT NULL
+			  FROM refresh_tokens rt JOIN auth_sessions s ON s.id = rt.session_id
+			  WHERE rt.token_hash = ?`
+	err = tx.QueryRowContext(ctx, query, oldHash).Scan(&tokenID, &sessionID, &userID, &tokenExpires, &used, &revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", 0, ErrRefreshTokenInvalid
+		}
+		return "", 0, err
+	}
+
+	if revoked {
+		return "", 0, ErrRefreshTokenInvalid
+	}
+	if used {
+		if err := revokeSession(ctx, tx, sessionID, RevokeReasonReuse); err != nil {
+			return "", 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, ErrRefreshTokenReused
+	}
+	if time.Now().After(tokenExpires) {
+		return "", 0, ErrRefreshTokenInvalid
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, time.Now().UTC(), tokenID)
+	if err != nil {
+		return "", 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return "", 0, ErrRefreshTokenInvalid
+	}
+
+	query = `INSERT INTO refresh_tokens (session_id, token_hash, expires_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, sessionID, newHash, expiresAt.UTC()); err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, err
+	}
+	return sessionID, userID, nil
+}
+
+// RevokeSession revokes a login session, invalidating its refresh tokens and
+// every access token issued within it.
+func (m *TokenModel) RevokeSession(sessionID, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return revokeSession(ctx, m.DB, sessionID, reason)
+}
+
+// RevokeUserSessions revokes every active session of a user.
+func (m *TokenModel) RevokeUserSessions(userID int, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE auth_sessions SET revoked_at = ?, revoked_reason = ? WHERE user_id = ? AND revoked_at IS NULL`
+	_, err := m.DB.ExecContext(ctx, query, time.Now().UTC(), reason, userID)
+	return err
+}
+
+// RevokeAccessToken blocks a single access token until it expires.
+func (m *TokenModel) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`
+	_, err := m.DB.ExecContext(ctx, query, jti, expiresAt.UTC())
+	return err
+}
+
+// IsRevoked reports whether the access token jti or its session has been
+// revoked. Empty values are not checked.
+func (m *TokenModel) IsRevoked(jti, sessionID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)
+			  OR EXISTS (SELECT 1 FROM auth_sessions WHERE id = ? AND revoked_at IS NOT NULL)`
+	var revoked bool
+	err := m.DB.QueryRowContext(ctx, query, jti, sessionID).Scan(&revoked)
+	return revoked, err
+}
+
+// CreateUserToken stores a single-use token for purpose, replacing any
+// outstanding token the user holds for the same purpose so only the most
+// recently mailed link works.
+func (m *TokenModel) CreateUserToken(userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL`, userID, purpose); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES (?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, userID, purpose, tokenHash, expiresAt.UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeUserToken marks the unexpired, unused token hashed as tokenHash as
+// used and returns its user. It returns ErrUserTokenInvalid otherwise.
+func (m *TokenModel) ConsumeUserToken(purpose, tokenHash string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	query := `UPDATE user_tokens SET used_at = ?
+			  WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?
+			  RETURNING user_id`
+	var userID int
+	err := m.DB.QueryRowContext(ctx, query, now, tokenHash, purpose, now).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUserTokenInvalid
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// DeleteUserTokens discards a user's outstanding tokens for purpose, or for
+// every purpose if purpose is empty.
+func (m *TokenModel) DeleteUserTokens(userID int, purpose string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM user_tokens WHERE user_id = ? AND used_at IS NULL AND (? = '' OR purpose = ?)`
+	_, err := m.DB.ExecContext(ctx, query, userID, purpose, purpose)
+	return err
+}
+
+// PurgeExpired deletes revocation entries, refresh tokens and mailed tokens
+// that can no longer be presented.
+func (m *TokenModel) PurgeExpired() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	if _, err := m.DB.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+	// The newest refresh token of a session is kept until PurgeSessions
+	// removes the session, as it records when the session expired.
+	query := `DELETE FROM refresh_tokens WHERE expires_at < ?
+			  AND id NOT IN (SELECT MAX(id) FROM refresh_tokens GROUP BY session_id)`
+	if _, err := m.DB.ExecContext(ctx, query, now); err != nil {
+		return err
+	}
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM user_tokens WHERE expires_at < ?`, now)
+	return err
+}
+
+// PurgeSessions deletes login sessions that were revoked or whose last
+// refresh token expired before cutoff, along with their refresh tokens. It
+// returns the number of sessions deleted. The cutoff must leave time for
+// the access tokens of a revoked session to expire, as IsRevoked no longer
+// sees the session once it is gone.
+func (m *TokenModel) PurgeSessions(cutoff time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM auth_sessions WHERE revoked_at < ?
+			  OR NOT EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.session_id = auth_sessions.id AND rt.expires_at >= ?)`
+	res, err := tx.ExecContext(ctx, query, cutoff.UTC(), cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	query = `DELETE FROM refresh_tokens WHERE NOT EXISTS (SELECT 1 FROM auth_sessions s WHERE s.id = refresh_tokens.session_id)`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func revokeSession(ctx context.Context, db execer, sessionID, reason string) error {
+	query := `UPDATE auth_sessions SET revoked_at = ?, revoked_reason = ? WHERE id = ? AND revoked_at IS NULL`
+	_, err := db.ExecContext(ctx, query, time.Now().UTC(), reason, sessionID)
+	return err
+}
@@ -3,29 +3,85 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 )
 
+// ErrEmailTaken is returned when restoring an account whose email address
+// has been registered by another account since it was deleted.
+var ErrEmailTaken = errors.New("email address taken by another account")
+
+// UserModel stores user accounts. Deleted accounts are kept until they are
+// purged but left out of every query.
 type UserModel struct {
 	DB *sql.DB
 }
 
+// Roles a user can hold. RoleUser is the default for new accounts.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ValidRole reports whether role is one of the known user roles.
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
+
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Password string `json:"-"`
-	Role     string `json:"role"`
+	ID                    int     `json:"id"`
+	Email                 string  `json:"email"`
+	Name                  string  `json:"name"`
+	Password              string  `json:"-"`
+	Role                  string  `json:"role"`
+	DisabledAt            *string `json:"disabled_at,omitempty"`
+	PasswordResetRequired bool    `json:"password_reset_required,omitempty"`
+	TokenVersion          int     `json:"-"`
+	EmailVerifiedAt       *string `json:"email_verified_at"`
+	MFAEnabled            bool    `json:"mfa_enabled"`
+	CreatedAt             string  `json:"created_at,omitempty"`
+}
+
+// userColumns is the column list scanned by scanUser.
+const userColumns = `id, email, name, password, role, disabled_at, password_reset_required, token_version, email_verified_at,
+	EXISTS (SELECT 1 FROM user_totp WHERE user_id = users.id AND confirmed_at IS NOT NULL), created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Role, &user.DisabledAt, &user.PasswordResetRequired, &user.TokenVersion, &user.EmailVerifiedAt, &user.MFAEnabled, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Disabled reports whether an administrator has disabled the account.
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// EmailVerified reports whether the user has confirmed their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 func (m *UserModel) Insert(user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO users (email, name, password)
-			  VALUES (?, ?, ?)`
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+
+	query := `INSERT INTO users (email, name, password, role)
+			  VALUES (?, ?, ?, ?)`
 
-	res, err := m.DB.ExecContext(ctx, query, user.Email, user.Name, user.Password)
+	res, err := m.DB.ExecContext(ctx, query, user.Email, user.Name, user.Password, user.Role)
 	if err != nil {
 		return err
 	}
@@ -42,32 +98,297 @@ func (m *UserModel) Get(id int) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, email, name, password FROM users WHERE id = ?`
-	var user User
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &user.Name, &user.Password)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ? AND deleted_at IS NULL`
+	user, err := scanUser(m.DB.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	user.Role = "user" // Default role
-	return &user, nil
+	return user, nil
 }
 
 func (m *UserModel) GetByEmail(email string) (*User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT id, email, name, password FROM users WHERE email = ?`
-	var user User
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.Name, &user.Password)
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ? AND deleted_at IS NULL`
+	user, err := scanUser(m.DB.QueryRowContext(ctx, query, email))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	user.Role = "user" // Default role
-	return &user, nil
+	return user, nil
+}
+
+// UpdateProfile saves a user's name and email. Changing the email bumps the
+// token version so tokens issued for the old address stop working, and marks
+// the new address unverified.
+func (m *UserModel) UpdateProfile(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET token_version = token_version + (email <> ?),
+			  email_verified_at = CASE WHEN email <> ? THEN NULL ELSE email_verified_at END,
+			  name = ?, email = ?, updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NULL RETURNING token_version, email_verified_at`
+	return m.DB.QueryRowContext(ctx, query, user.Email, user.Email, user.Name, user.Email, user.ID).Scan(&user.TokenVersion, &user.EmailVerifiedAt)
+}
+
+// UpdatePassword stores a new password hash, clears any pending forced
+// reset and bumps the token version so existing tokens stop working. It
+// returns the new token version.
+func (m *UserModel) UpdatePassword(id int, hash string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET password = ?, password_reset_required = 0, token_version = token_version + 1, updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NULL RETURNING token_version`
+	var version int
+	err := m.DB.QueryRowContext(ctx, query, hash, id).Scan(&version)
+	return version, err
+}
+
+// MarkEmailVerified records that the user proved ownership of their current
+// email address. It reports false if no such user exists.
+func (m *UserModel) MarkEmailVerified(id int) (bool, error) {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, datetime('now')), updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NULL`
+	return m.execForUser(query, id)
+}
+
+// UpdateRole sets the role of a user. It reports false if no such user exists.
+func (m *UserModel) UpdateRole(id int, role string) (bool, error) {
+	query := `UPDATE users SET role = ?, updated_at = datetime('now') WHERE id = ? AND deleted_at IS NULL`
+	return m.execForUser(query, role, id)
+}
+
+// List returns one page of users whose name or email contains search
+// (case-insensitive) and, if role is non-empty, who hold that role, together
+// with the total number of matches.
+func (m *UserModel) List(search, role string, limit, offset int) ([]*User, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conds := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	if search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		conds = append(conds, `(LOWER(email) LIKE ? ESCAPE '\' OR LOWER(name) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if role != "" {
+		conds = append(conds, "role = ?")
+		args = append(args, role)
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	var total int
+	if err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where + ` ORDER BY id LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// SetDisabled disables or re-enables an account. It reports false if no such
+// user exists.
+func (m *UserModel) SetDisabled(id int, disabled bool) (bool, error) {
+	query := `UPDATE users SET disabled_at = NULL, updated_at = datetime('now') WHERE id = ? AND deleted_at IS NULL`
+	if disabled {
+		query = `UPDATE users SET disabled_at = COALESCE(disabled_at, datetime('now')), updated_at = datetime('now') WHERE id = ? AND deleted_at IS NULL`
+	}
+	return m.execForUser(query, id)
+}
+
+// SetPasswordResetRequired flags an account so it cannot be used until the
+// password has been reset. It reports false if no such user exists.
+func (m *UserModel) SetPasswordResetRequired(id int, required bool) (bool, error) {
+	query := `UPDATE users SET password_reset_required = ?, updated_at = datetime('now') WHERE id = ? AND deleted_at IS NULL`
+	return m.execForUser(query, required, id)
+}
+
+// Delete marks an account and the events it owns deleted, and bumps the
+// token version so outstanding tokens stop working. It reports false if no
+// such user exists.
+func (m *UserModel) Delete(id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET deleted_at = datetime('now'), token_version = token_version + 1, updated_at = datetime('now')
+			  WHERE id = ? AND deleted_at IS NULL`
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	// The events are marked so Restore can tell them from events deleted on
+	// their own
+	query = `UPDATE events SET deleted_at = datetime('now'), deleted_with_user = 1 WHERE user_id = ? AND deleted_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Restore undoes the deletion of an account together with the events
+// deleted with it, except those whose venue has been booked for their time
+// since. It reports false if no such deleted user exists, and returns
+// ErrEmailTaken if its email address belongs to another account now.
+func (m *UserModel) Restore(id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET deleted_at = NULL, deleted_with_user = 0, updated_at = datetime('now')
+			  WHERE user_id = ? AND deleted_with_user = 1 AND deleted_at IS NOT NULL
+			  AND (status = 'cancelled' OR venue_id IS NULL OR NOT EXISTS (SELECT 1 FROM events o WHERE o.venue_id = events.venue_id AND o.id != events.id
+			  AND o.status != 'cancelled' AND o.deleted_at IS NULL AND o.start_time < events.end_time AND o.end_time > events.start_time))`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return false, err
+	}
+	// Those left behind stay deleted like events deleted on their own
+	if _, err := tx.ExecContext(ctx, `UPDATE events SET deleted_with_user = 0 WHERE user_id = ? AND deleted_with_user = 1`, id); err != nil {
+		return false, err
+	}
+	query = `UPDATE users SET deleted_at = NULL, updated_at = datetime('now') WHERE id = ? AND deleted_at IS NOT NULL`
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return false, ErrEmailTaken
+		}
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// PurgeDeleted permanently removes the accounts deleted before cutoff that
+// no longer own any event, together with their RSVPs, sessions, keys,
+// identities and event roles. It returns how many accounts there were and
+// the events whose seats they gave up.
+func (m *UserModel) PurgeDeleted(cutoff time.Time) (int64, []int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	purged := `deleted_at <= datetime(?, 'unixepoch') AND NOT EXISTS (SELECT 1 FROM events WHERE user_id = users.id)`
+	query := `DELETE FROM attendees WHERE user_id IN (SELECT id FROM users WHERE ` + purged + `)
+			  RETURNING event_id, status IN ` + seatedStatuses
+	rows, err := tx.QueryContext(ctx, query, cutoff.Unix())
+	if err != nil {
+		return 0, nil, err
+	}
+	var freed []int
+	seen := map[int]bool{}
+	for rows.Next() {
+		var eventID int
+		var seated bool
+		if err := rows.Scan(&eventID, &seated); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if seated && !seen[eventID] {
+			seen[eventID] = true
+			freed = append(freed, eventID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	// The connection does not enforce foreign keys, so what they would
+	// cascade to or set to NULL is handled here
+	ids := `SELECT id FROM users WHERE ` + purged
+	for _, query := range []string{
+		`DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM auth_sessions WHERE user_id IN (` + ids + `))`,
+		`DELETE FROM auth_sessions WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM api_keys WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM user_tokens WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM user_identities WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM user_totp WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM event_roles WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM event_invitations WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM attendee_status_history WHERE user_id IN (` + ids + `)`,
+		`DELETE FROM series_tags WHERE series_id IN (SELECT id FROM event_series WHERE user_id IN (` + ids + `))`,
+		`DELETE FROM event_series WHERE user_id IN (` + ids + `)`,
+		`UPDATE attendee_status_history SET changed_by = NULL WHERE changed_by IN (` + ids + `)`,
+		`UPDATE event_status_history SET changed_by = NULL WHERE changed_by IN (` + ids + `)`,
+		`UPDATE event_invitations SET invited_by = NULL WHERE invited_by IN (` + ids + `)`,
+		`UPDATE event_invite_links SET created_by = NULL WHERE created_by IN (` + ids + `)`,
+		`UPDATE event_roles SET granted_by = NULL WHERE granted_by IN (` + ids + `)`,
+		`UPDATE venues SET created_by = NULL WHERE created_by IN (` + ids + `)`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, cutoff.Unix()); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE `+purged, cutoff.Unix())
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, freed, tx.Commit()
+}
+
+func (m *UserModel) execForUser(query string, args ...interface{}) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	ra, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return ra > 0, nil
 }
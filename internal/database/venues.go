@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrVenueBooked is returned when an event would overlap another event
+	// at the same venue.
+	ErrVenueBooked = errors.New("venue is already booked at that time")
+
+	// ErrVenueInUse is returned when deleting a venue events still use.
+	ErrVenueInUse = errors.New("venue is used by events")
+)
+
+// Venue kinds
+const (
+	VenueKindPhysical = "physical"
+	VenueKindOnline   = "online"
+	VenueKindHybrid   = "hybrid"
+)
+
+// VenueModel stores the places, physical or online, events take place at.
+type VenueModel struct {
+	DB *sql.DB
+}
+
+// Venue is a place events can be booked at. Online and hybrid venues have
+// a meeting URL; physical and hybrid ones an address.
+type Venue struct {
+	ID         int      `json:"id"`
+	CreatedBy  *int     `json:"created_by"`
+	Name       string   `json:"name" binding:"required,min=2,max=100" example:"Convention Center"`
+	Address    string   `json:"address" binding:"max=255" example:"1 Main Street, Cape Town"`
+	Capacity   *int     `json:"capacity" binding:"omitempty,min=1,max=100000" example:"200"`
+	Latitude   *float64 `json:"latitude" binding:"omitempty,min=-90,max=90" example:"-33.9249"`
+	Longitude  *float64 `json:"longitude" binding:"omitempty,min=-180,max=180" example:"18.4241"`
+	Kind       string   `json:"kind" binding:"omitempty,oneof=physical online hybrid" example:"physical"`
+	MeetingURL string   `json:"meeting_url" binding:"omitempty,url,max=500"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// Location describes where the venue is, as Event.Location does.
+func (v *Venue) Location() string {
+	if v.Address == "" {
+		return v.Name
+	}
+	return v.Name + ", " + v.Address
+}
+
+// VenueBooking is a time an event occupies a venue. EventID and Title are
+// only set for events the viewer may see listed.
+type VenueBooking struct {
+	EventID   *int   `json:"event_id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// venueClash is an SQL condition that holds if an event other than the
+// given one is booked at the venue between the given end and start.
+// Cancelled and deleted events free their venue.
+const venueClash = `EXISTS (SELECT 1 FROM events o WHERE o.venue_id = ? AND o.id != ? AND o.status != 'cancelled' AND o.deleted_at IS NULL
+	AND o.start_time < ? AND o.end_time > ?)`
+
+func venueClashArgs(ev *Event) []interface{} {
+	return []interface{}{ev.VenueID, ev.ID, ev.EndTime, ev.StartTime}
+}
+
+const venueColumns = `id, created_by, name, address, capacity, latitude, longitude, kind, meeting_url, created_at, updated_at`
+
+func scanVenue(row rowScanner) (*Venue, error) {
+	var v Venue
+	err := row.Scan(&v.ID, &v.CreatedBy, &v.Name, &v.Address, &v.Capacity, &v.Latitude, &v.Longitude, &v.Kind, &v.MeetingURL, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (m *VenueModel) Insert(v *Venue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if v.Kind == "" {
+		v.Kind = VenueKindPhysical
+	}
+
+	query := `INSERT INTO venues (created_by, name, address, capacity, latitude, longitude, kind, meeting_url)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, created_at, updated_at`
+	return m.DB.QueryRowContext(ctx, query, v.CreatedBy, v.Name, v.Address, v.Capacity, v.Latitude, v.Longitude, v.Kind, v.MeetingURL).
+		Scan(&v.ID, &v.CreatedAt, &v.UpdatedAt)
+}
+
+func (m *VenueModel) Get(id int) (*Venue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	v, err := scanVenue(m.DB.QueryRowContext(ctx, `SELECT `+venueColumns+` FROM venues WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+// List returns one page of venues ordered by name, optionally only those
+// whose name or address contains search, along with the total count.
+func (m *VenueModel) List(search string, limit, offset int) ([]*Venue, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where := ""
+	var args []interface{}
+	if search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		where = ` WHERE LOWER(name) LIKE ? ESCAPE '\' OR LOWER(address) LIKE ? ESCAPE '\'`
+		args = append(args, pattern, pattern)
+	}
+
+	var total int
+	if err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM venues`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + venueColumns + ` FROM venues` + where + ` ORDER BY name, id LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var venues []*Venue
+	for rows.Next() {
+		v, err := scanVenue(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		venues = append(venues, v)
+	}
+	return venues, total, rows.Err()
+}
+
+func (m *VenueModel) Update(v *Venue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if v.Kind == "" {
+		v.Kind = VenueKindPhysical
+	}
+
+	query := `UPDATE venues SET name = ?, address = ?, capacity = ?, latitude = ?, longitude = ?, kind = ?, meeting_url = ?,
+			  updated_at = datetime('now') WHERE id = ? RETURNING created_by, created_at, updated_at`
+	return m.DB.QueryRowContext(ctx, query, v.Name, v.Address, v.Capacity, v.Latitude, v.Longitude, v.Kind, v.MeetingURL, v.ID).
+		Scan(&v.CreatedBy, &v.CreatedAt, &v.UpdatedAt)
+}
+
+// Delete removes a venue no event or recurring event uses; otherwise it
+// returns ErrVenueInUse.
+func (m *VenueModel) Delete(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM venues WHERE id = ?
+			  AND NOT EXISTS (SELECT 1 FROM events WHERE venue_id = venues.id)
+			  AND NOT EXISTS (SELECT 1 FROM event_series WHERE venue_id = venues.id)`
+	res, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVenueInUse
+	}
+	return nil
+}
+
+// Bookings returns the events at a venue that overlap [from, to), in order,
+// leaving out cancelled and deleted ones.
+func (m *VenueModel) Bookings(venueID int, from, to string, v Viewer) ([]*VenueBooking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	listed, args := listedFor(v)
+	query := `SELECT e.id, e.title, e.start_time, e.end_time, ` + listed + ` FROM events e
+			  WHERE e.venue_id = ? AND e.status != 'cancelled' AND e.deleted_at IS NULL AND e.start_time < ? AND e.end_time > ? ORDER BY e.start_time, e.id`
+	rows, err := m.DB.QueryContext(ctx, query, append(args, venueID, to, from)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []*VenueBooking
+	for rows.Next() {
+		var b VenueBooking
+		var id int
+		var visible bool
+		if err := rows.Scan(&id, &b.Title, &b.StartTime, &b.EndTime, &visible); err != nil {
+			return nil, err
+		}
+		if visible {
+			b.EventID = &id
+		} else {
+			b.Title = ""
+		}
+		bookings = append(bookings, &b)
+	}
+	return bookings, rows.Err()
+}
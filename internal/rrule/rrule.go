@@ -0,0 +1,300 @@
+// Package rrule implements the subset of iCalendar (RFC 5545) recurrence
+// rules EventHub supports: FREQ=DAILY, WEEKLY or MONTHLY with INTERVAL,
+// BYDAY (with ordinals such as 2TU or -1FR for monthly rules), COUNT and
+// UNTIL.
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// maxEmptyPeriods stops iterating rules that can no longer produce an
+// occurrence, such as the 31st of every other February.
+const maxEmptyPeriods = 1000
+
+// Weekday is a BYDAY entry: a day of the week and, for monthly rules, its
+// ordinal within the month (1 is the first, -1 the last, 0 every).
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule. Count and Until are mutually
+// exclusive; zero values mean the rule does not end.
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []Weekday
+	Count    int
+	Until    time.Time
+}
+
+var dayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Parse parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". An
+// "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("rrule: empty rule")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule: malformed part %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rrule: %s given twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly {
+				return nil, fmt.Errorf("rrule: unsupported FREQ %q, use DAILY, WEEKLY or MONTHLY", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, fmt.Errorf("rrule: INTERVAL must be between 1 and 1000")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("rrule: COUNT must be a positive number")
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = t
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				wd, err := parseWeekday(code)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, fmt.Errorf("rrule: only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("rrule: unsupported rule part %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("rrule: COUNT and UNTIL cannot both be set")
+	}
+	if r.Freq != Monthly {
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				return nil, fmt.Errorf("rrule: BYDAY ordinals are only supported with FREQ=MONTHLY")
+			}
+		}
+	}
+	return r, nil
+}
+
+// parseUntil accepts a UTC date-time (20240131T090000Z) or a date, which
+// includes the whole day.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("rrule: UNTIL must look like 20240131T090000Z or 20240131")
+}
+
+func parseWeekday(code string) (Weekday, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %q", code)
+	}
+	day, ok := dayCodes[code[len(code)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %q", code)
+	}
+	wd := Weekday{Day: day}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, fmt.Errorf("rrule: invalid BYDAY %q", code)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// String formats r in canonical iCalendar form.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			code := strings.ToUpper(wd.Day.String()[:2])
+			if wd.N != 0 {
+				code = strconv.Itoa(wd.N) + code
+			}
+			codes[i] = code
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Iterator yields the occurrences of a rule in chronological order.
+type Iterator struct {
+	r       *Rule
+	dtstart time.Time
+	period  int
+	buf     []time.Time
+	n       int
+	done    bool
+}
+
+// Iterator returns an iterator over the occurrences of r starting at or
+// after dtstart. Occurrences take dtstart's time of day and are computed in
+// its location, so they keep their wall clock time across DST changes.
+func (r *Rule) Iterator(dtstart time.Time) *Iterator {
+	return &Iterator{r: r, dtstart: dtstart}
+}
+
+// Next returns the next occurrence, or false once the rule has ended.
+func (it *Iterator) Next() (time.Time, bool) {
+	for empty := 0; len(it.buf) == 0; empty++ {
+		if it.done || empty >= maxEmptyPeriods {
+			it.done = true
+			return time.Time{}, false
+		}
+		it.buf = it.candidates(it.period)
+		it.period++
+		// Drop candidates of the first period that precede dtstart
+		for len(it.buf) > 0 && it.buf[0].Before(it.dtstart) {
+			it.buf = it.buf[1:]
+		}
+	}
+
+	t := it.buf[0]
+	it.buf = it.buf[1:]
+	if (!it.r.Until.IsZero() && t.After(it.r.Until)) || (it.r.Count > 0 && it.n >= it.r.Count) {
+		it.done = true
+		it.buf = nil
+		return time.Time{}, false
+	}
+	it.n++
+	return t, true
+}
+
+// candidates returns the sorted occurrences the rule's BYDAY entries select
+// in the k-th period after dtstart's.
+func (it *Iterator) candidates(k int) []time.Time {
+	start := it.dtstart
+	loc := start.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	}
+	step := k * it.r.Interval
+
+	var out []time.Time
+	switch it.r.Freq {
+	case Daily:
+		t := at(start.Year(), start.Month(), start.Day()+step)
+		if len(it.r.ByDay) == 0 || it.hasDay(t.Weekday()) {
+			out = append(out, t)
+		}
+	case Weekly:
+		monday := start.Day() - (int(start.Weekday())+6)%7 + 7*step
+		if len(it.r.ByDay) == 0 {
+			out = append(out, at(start.Year(), start.Month(), start.Day()+7*step))
+			break
+		}
+		for _, wd := range it.r.ByDay {
+			out = append(out, at(start.Year(), start.Month(), monday+(int(wd.Day)+6)%7))
+		}
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, loc)
+		y, m := first.Year(), first.Month()
+		daysIn := time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day()
+		if len(it.r.ByDay) == 0 {
+			if start.Day() <= daysIn {
+				out = append(out, at(y, m, start.Day()))
+			}
+			break
+		}
+		for _, wd := range it.r.ByDay {
+			firstDay := 1 + (int(wd.Day)-int(first.Weekday())+7)%7
+			var days []int
+			for d := firstDay; d <= daysIn; d += 7 {
+				days = append(days, d)
+			}
+			switch {
+			case wd.N == 0:
+				for _, d := range days {
+					out = append(out, at(y, m, d))
+				}
+			case wd.N > 0 && wd.N <= len(days):
+				out = append(out, at(y, m, days[wd.N-1]))
+			case wd.N < 0 && -wd.N <= len(days):
+				out = append(out, at(y, m, days[len(days)+wd.N]))
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	// BYDAY may list a day twice
+	uniq := out[:0]
+	for i, t := range out {
+		if i == 0 || !t.Equal(out[i-1]) {
+			uniq = append(uniq, t)
+		}
+	}
+	return uniq
+}
+
+func (it *Iterator) hasDay(d time.Weekday) bool {
+	for _, wd := range it.r.ByDay {
+		if wd.Day == d {
+			return true
+		}
+	}
+	return false
+}